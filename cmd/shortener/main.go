@@ -2,8 +2,9 @@ package main
 
 import (
 	"context"
-	"flag"
+	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -11,41 +12,79 @@ import (
 	"time"
 
 	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/configs"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/delivery/grpchandlers"
 	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/delivery/handlers"
 	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/domain"
 	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/logger"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/metrics"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/ratelimit"
 	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/service"
 	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/storage/hashmapstorage"
 	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/storage/postgresstorage"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/tlscert"
 	"github.com/jmoiron/sqlx"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
+const clickFileSuffix = ".clicks"
+
 func main() {
-	initFlag()
-	flag.Parse()
-	configs.InitConfig(flagServAddr, flagBaseShortURL)
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if err := configs.InitConfig(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+	cfg := configs.AppConfig
 
-	if err := logger.Initialize(flagLogLevel); err != nil {
+	if err := logger.Initialize(cfg.LogLevel); err != nil {
 		log.Fatal(err)
 	}
 	var userStorage service.UserStorage
 	var linkStorage service.LinkStorage
+	var clickStorage service.ClickStorage
+	var jobStorage service.DeleteJobStorage
+	var keyStorage service.APIKeyStorage
 	var db *sqlx.DB
 	var err error
-	if flagConfigDB == "" {
-		linkStorage, err = hashmapstorage.NewLinkStorage(make(map[string]domain.Link), flagFileStoragePath)
+	if cfg.DatabaseDSN == "" {
+		// Links, users, delete jobs and API keys share one storage so a single
+		// writer owns the file.
+		fileStorage, err := hashmapstorage.NewLinkStorage(make(map[string]domain.Link), cfg.FileStoragePath)
 		if err != nil {
 			logger.Log().Fatal(err.Error())
 		}
-		userStorage, err = hashmapstorage.NewLinkStorage(make(map[string]domain.Link), flagFileStoragePath)
+		linkStorage, userStorage, jobStorage, keyStorage = fileStorage, fileStorage, fileStorage, fileStorage
+		clickFilePath := ""
+		if cfg.FileStoragePath != "" {
+			clickFilePath = cfg.FileStoragePath + clickFileSuffix
+		}
+		fileClicks, err := hashmapstorage.NewClickStorage(clickFilePath)
 		if err != nil {
 			logger.Log().Fatal(err.Error())
 		}
+		fileStorage.SetClickStorage(fileClicks)
+		clickStorage = fileClicks
 	} else {
-		db, err = postgresstorage.NewPostgresDB(flagConfigDB)
+		db, err = postgresstorage.NewPostgresDB(cfg.DatabaseDSN)
 		if err != nil {
 			logger.Log().Fatal(err.Error())
 		}
+		if cfg.MigrateOnStart {
+			applied, err := postgresstorage.MigrateUp(context.Background(), db)
+			if err != nil {
+				logger.Log().Fatal(err.Error())
+			}
+			for _, m := range applied {
+				logger.Log().Info(fmt.Sprintf("applied migration %04d_%s", m.Version, m.Name))
+			}
+		} else if err := postgresstorage.CheckMigrations(context.Background(), db); err != nil {
+			logger.Log().Fatal(err.Error() + ": run shortener migrate up")
+		}
 		linkStorage, err = postgresstorage.NewLinkStorage(db)
 		if err != nil {
 			logger.Log().Fatal(err.Error())
@@ -54,9 +93,52 @@ func main() {
 		if err != nil {
 			logger.Log().Fatal(err.Error())
 		}
+		clickStorage, err = postgresstorage.NewClickStorage(db)
+		if err != nil {
+			logger.Log().Fatal(err.Error())
+		}
+		jobStorage, err = postgresstorage.NewDeleteJobStorage(db)
+		if err != nil {
+			logger.Log().Fatal(err.Error())
+		}
+		keyStorage, err = postgresstorage.NewAPIKeyStorage(db)
+		if err != nil {
+			logger.Log().Fatal(err.Error())
+		}
+	}
+	signingKeys, activeKID, err := cfg.SigningKeys()
+	if err != nil {
+		logger.Log().Fatal(err.Error())
 	}
-	servises := handlers.NewServices(linkStorage, userStorage)
-	handler := handlers.NewHandler(servises, flagBaseShortURL)
+	if len(signingKeys) == 0 {
+		logger.Log().Warn("no JWT signing keys configured, using an ephemeral key")
+	}
+	identGenerator, err := service.NewIdentGenerator(cfg.IdentStrategy, cfg.IdentLength, cfg.IdentAlphabet, linkStorage)
+	if err != nil {
+		logger.Log().Fatal(err.Error())
+	}
+	servises := handlers.NewServices(linkStorage, userStorage, clickStorage, jobStorage, keyStorage, service.AuthConfig{
+		SigningKeys:   signingKeys,
+		ActiveKID:     activeKID,
+		TokenExp:      time.Duration(cfg.TokenTTL),
+		RefreshBefore: time.Duration(cfg.TokenRefresh),
+	}, service.LinkConfig{
+		GlobalDedupe:   cfg.DedupeScope == configs.DedupeGlobal,
+		IdentGenerator: identGenerator,
+		RestoreWindow:  time.Duration(cfg.RestoreWindow),
+		PurgeAfter:     time.Duration(cfg.PurgeAfter),
+		LinkQuota:      cfg.LinkQuota,
+		MaxBatchSize:   cfg.MaxBatchSize,
+		AllowedSchemes: cfg.AllowedSchemes(),
+		KeepFragment:   cfg.KeepURLFragment,
+	})
+	handler := handlers.NewHandler(servises, cfg.BaseShortURL)
+	if err := handler.SetTrustedSubnet(cfg.TrustedSubnet); err != nil {
+		logger.Log().Fatal(err.Error())
+	}
+	handler.SetSecureCookie(cfg.EnableHTTPS)
+	handler.SetRateLimits(ratelimit.PerMinute(cfg.RateLimit, cfg.RateBurst), ratelimit.PerMinute(cfg.RedirectRateLimit, cfg.RedirectRateBurst))
+	handler.Start()
 	router := handler.InitRouter()
 	router.Get("/ping", http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
 		if db == nil {
@@ -71,16 +153,71 @@ func main() {
 	}))
 
 	srv := &http.Server{
-		Addr:    configs.AppConfig.ServAddr,
+		Addr:    cfg.ServAddr,
 		Handler: router,
 	}
 
+	certFile, keyFile := cfg.TLSCertFile, cfg.TLSKeyFile
+	if cfg.EnableHTTPS && certFile == "" {
+		certFile, keyFile, err = configs.DefaultTLSFiles()
+		if err != nil {
+			logger.Log().Fatal(err.Error())
+		}
+		host, _, _ := net.SplitHostPort(cfg.ServAddr)
+		if err := tlscert.EnsureSelfSigned(certFile, keyFile, host, "localhost", "127.0.0.1"); err != nil {
+			logger.Log().Fatal(err.Error())
+		}
+	}
+
 	go func() {
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if cfg.EnableHTTPS {
+			err = srv.ListenAndServeTLS(certFile, keyFile)
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			log.Fatalf("listen and serve: %v", err)
 		}
 	}()
 
+	// gRPC shares the HTTPS certificate, so tokens in metadata are not sent in
+	// the clear.
+	var grpcOpts []grpc.ServerOption
+	if cfg.EnableHTTPS {
+		creds, err := credentials.NewServerTLSFromFile(certFile, keyFile)
+		if err != nil {
+			logger.Log().Fatal(err.Error())
+		}
+		grpcOpts = append(grpcOpts, grpc.Creds(creds))
+	}
+	grpcServer := grpchandlers.NewServer(servises, cfg.BaseShortURL)
+	grpcServer.SetRateLimit(ratelimit.PerMinute(cfg.RateLimit, cfg.RateBurst))
+	grpcSrv := grpcServer.InitServer(grpcOpts...)
+	if cfg.GRPCAddr != "" {
+		grpcListener, err := net.Listen("tcp", cfg.GRPCAddr)
+		if err != nil {
+			logger.Log().Fatal(err.Error())
+		}
+		go func() {
+			if err := grpcSrv.Serve(grpcListener); err != nil {
+				log.Fatalf("grpc serve: %v", err)
+			}
+		}()
+	}
+
+	metricsSrv := &http.Server{
+		Addr:    cfg.MetricsAddr,
+		Handler: metrics.InitRouter(),
+	}
+	if cfg.MetricsAddr != "" {
+		go func() {
+			if err := metricsSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Fatalf("metrics listen and serve: %v", err)
+			}
+		}()
+	}
+
 	s := make(chan os.Signal, 1)
 	signal.Notify(s, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
 	<-s
@@ -93,9 +230,16 @@ func main() {
 	if err := srv.Shutdown(context); err != nil {
 		logger.Log().Error(err.Error())
 	}
+	if err := metricsSrv.Shutdown(context); err != nil {
+		logger.Log().Error(err.Error())
+	}
+	grpcSrv.GracefulStop()
 
-	handler.FlushMessagesDeleteNow()
+	handler.Close()
 
+	if err := clickStorage.Close(); err != nil {
+		logger.Log().Error(err.Error())
+	}
 	if err := linkStorage.Close(); err != nil {
 		logger.Log().Error(err.Error())
 	}
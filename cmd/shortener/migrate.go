@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/configs"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/storage/postgresstorage"
+)
+
+const migrateUsage = "usage: shortener migrate up|down|status [flags]"
+
+// runMigrate implements the migrate subcommand. Flags and environment
+// variables after the action are read as for the server.
+func runMigrate(args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+	action := args[0]
+	cfg, err := configs.LoadConfig(args[1:])
+	if err != nil {
+		return err
+	}
+	if cfg.DatabaseDSN == "" {
+		return errors.New("migrate requires a database DSN (-d or DATABASE_DSN)")
+	}
+	db, err := postgresstorage.NewPostgresDB(cfg.DatabaseDSN)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	switch action {
+	case "up":
+		applied, err := postgresstorage.MigrateUp(ctx, db)
+		for _, m := range applied {
+			fmt.Fprintf(os.Stdout, "applied %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Fprintln(os.Stdout, "schema is up to date")
+		}
+	case "down":
+		m, ok, err := postgresstorage.MigrateDown(ctx, db)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			fmt.Fprintln(os.Stdout, "no migrations to revert")
+			return nil
+		}
+		fmt.Fprintf(os.Stdout, "reverted %04d_%s\n", m.Version, m.Name)
+	case "status":
+		statuses, err := postgresstorage.MigrationsStatus(ctx, db)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.AppliedAt != nil {
+				state = "applied " + s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(os.Stdout, "%04d_%s\t%s\n", s.Version, s.Name, state)
+		}
+	default:
+		return errors.New(migrateUsage)
+	}
+	return nil
+}
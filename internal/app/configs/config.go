@@ -1,15 +1,446 @@
 package configs
 
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	defaultServAddr        = "localhost:8080"
+	defaultLogLevel        = "info"
+	defaultFileStoragePath = "/tmp/short-url-db.json"
+	defaultGRPCAddr        = "localhost:3200"
+	defaultMetricsAddr     = "localhost:8081"
+	tlsCacheDir            = "go-yandex-shortener"
+	defaultTokenTTL        = 3 * time.Hour
+	defaultTokenRefresh    = 30 * time.Minute
+	defaultIdentStrategy   = "random"
+	defaultIdentLength     = 8
+	defaultIdentAlphabet   = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	defaultRestoreWindow   = 7 * 24 * time.Hour
+	defaultPurgeAfter      = 30 * 24 * time.Hour
+	defaultRateLimit       = 60
+	defaultRateBurst       = 20
+	defaultRedirectLimit   = 600
+	defaultRedirectBurst   = 100
+	defaultLinkQuota       = 10000
+	defaultMaxBatchSize    = 1000
+	defaultURLSchemes      = "http,https"
+)
+
+var identStrategies = map[string]bool{"sequence": true, "random": true, "hash": true}
+
+// Dedupe scopes: whether shortening an already shortened URL returns the
+// caller's own link or anyone's.
+const (
+	DedupeUser   = "user"
+	DedupeGlobal = "global"
+)
+
 var AppConfig *Config
 
 type Config struct {
-	ServAddr     string
-	BaseShortURL string
+	ConfigPath      string   `json:"-"`
+	ServAddr        string   `json:"server_address"`
+	BaseShortURL    string   `json:"base_url"`
+	FileStoragePath string   `json:"file_storage_path"`
+	DatabaseDSN     string   `json:"database_dsn"`
+	LogLevel        string   `json:"log_level"`
+	GRPCAddr        string   `json:"grpc_address"`
+	TrustedSubnet   string   `json:"trusted_subnet"`
+	EnableHTTPS     bool     `json:"enable_https"`
+	TLSCertFile     string   `json:"tls_cert_file"`
+	TLSKeyFile      string   `json:"tls_key_file"`
+	JWTKeys         string   `json:"jwt_keys"`
+	JWTKeyFile      string   `json:"jwt_key_file"`
+	JWTActiveKID    string   `json:"jwt_active_kid"`
+	TokenTTL        Duration `json:"token_ttl"`
+	TokenRefresh    Duration `json:"token_refresh"`
+	MetricsAddr     string   `json:"metrics_address"`
+	MigrateOnStart  bool     `json:"migrate_on_start"`
+	DedupeScope     string   `json:"dedupe_scope"`
+	IdentStrategy   string   `json:"ident_strategy"`
+	IdentLength     int      `json:"ident_length"`
+	IdentAlphabet   string   `json:"ident_alphabet"`
+	RestoreWindow   Duration `json:"restore_window"`
+	PurgeAfter      Duration `json:"purge_after"`
+	// Rate limits are requests a minute per user and per client IP; 0
+	// disables a limit.
+	RateLimit         int `json:"rate_limit"`
+	RateBurst         int `json:"rate_burst"`
+	RedirectRateLimit int `json:"redirect_rate_limit"`
+	RedirectRateBurst int `json:"redirect_rate_burst"`
+	// LinkQuota caps the active links of a user and MaxBatchSize the links of
+	// a batch; 0 lifts a cap.
+	LinkQuota    int `json:"link_quota"`
+	MaxBatchSize int `json:"max_batch_size"`
+	// URLSchemes lists the schemes allowed in destination URLs, separated by
+	// commas. Fragments are dropped from them unless KeepURLFragment is set.
+	URLSchemes      string `json:"url_schemes"`
+	KeepURLFragment bool   `json:"keep_url_fragment"`
+}
+
+// envNames maps flag names to the environment variables overriding them.
+var envNames = map[string]string{
+	"c":              "CONFIG",
+	"a":              "SERVER_ADDRESS",
+	"b":              "BASE_URL",
+	"f":              "FILE_STORAGE_PATH",
+	"d":              "DATABASE_DSN",
+	"l":              "LOG_LEVEL",
+	"g":              "GRPC_ADDRESS",
+	"t":              "TRUSTED_SUBNET",
+	"s":              "ENABLE_HTTPS",
+	"tls-cert":       "TLS_CERT_FILE",
+	"tls-key":        "TLS_KEY_FILE",
+	"jwt-keys":       "JWT_KEYS",
+	"jwt-key-file":   "JWT_KEY_FILE",
+	"jwt-kid":        "JWT_ACTIVE_KID",
+	"token-ttl":      "TOKEN_TTL",
+	"token-refresh":  "TOKEN_REFRESH",
+	"metrics-addr":   "METRICS_ADDRESS",
+	"migrate":        "MIGRATE_ON_START",
+	"dedupe":         "DEDUPE_SCOPE",
+	"ident-strategy": "IDENT_STRATEGY",
+	"ident-length":   "IDENT_LENGTH",
+	"ident-alphabet": "IDENT_ALPHABET",
+	"restore-window": "RESTORE_WINDOW",
+	"purge-after":    "PURGE_AFTER",
+	"rate-limit":     "RATE_LIMIT",
+	"rate-burst":     "RATE_BURST",
+	"redirect-limit": "REDIRECT_RATE_LIMIT",
+	"redirect-burst": "REDIRECT_RATE_BURST",
+	"link-quota":     "LINK_QUOTA",
+	"max-batch-size": "MAX_BATCH_SIZE",
+	"url-schemes":    "URL_SCHEMES",
+	"keep-fragment":  "KEEP_URL_FRAGMENT",
+}
+
+func defaultConfig() Config {
+	return Config{
+		ServAddr:          defaultServAddr,
+		FileStoragePath:   defaultFileStoragePath,
+		LogLevel:          defaultLogLevel,
+		GRPCAddr:          defaultGRPCAddr,
+		TokenTTL:          Duration(defaultTokenTTL),
+		TokenRefresh:      Duration(defaultTokenRefresh),
+		MetricsAddr:       defaultMetricsAddr,
+		MigrateOnStart:    true,
+		DedupeScope:       DedupeUser,
+		IdentStrategy:     defaultIdentStrategy,
+		IdentLength:       defaultIdentLength,
+		IdentAlphabet:     defaultIdentAlphabet,
+		RestoreWindow:     Duration(defaultRestoreWindow),
+		PurgeAfter:        Duration(defaultPurgeAfter),
+		RateLimit:         defaultRateLimit,
+		RateBurst:         defaultRateBurst,
+		RedirectRateLimit: defaultRedirectLimit,
+		RedirectRateBurst: defaultRedirectBurst,
+		LinkQuota:         defaultLinkQuota,
+		MaxBatchSize:      defaultMaxBatchSize,
+		URLSchemes:        defaultURLSchemes,
+	}
+}
+
+func (c *Config) flagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("shortener", flag.ContinueOnError)
+	fs.StringVar(&c.ConfigPath, "c", c.ConfigPath, "JSON config file path")
+	fs.StringVar(&c.ServAddr, "a", c.ServAddr, "base server address")
+	fs.StringVar(&c.BaseShortURL, "b", c.BaseShortURL, "base address short URL")
+	fs.StringVar(&c.FileStoragePath, "f", c.FileStoragePath, "file storage path")
+	fs.StringVar(&c.DatabaseDSN, "d", c.DatabaseDSN, "database DSN")
+	fs.StringVar(&c.LogLevel, "l", c.LogLevel, "log level")
+	fs.StringVar(&c.GRPCAddr, "g", c.GRPCAddr, "gRPC server address")
+	fs.StringVar(&c.TrustedSubnet, "t", c.TrustedSubnet, "trusted subnet (CIDR)")
+	fs.BoolVar(&c.EnableHTTPS, "s", c.EnableHTTPS, "serve HTTPS")
+	fs.StringVar(&c.TLSCertFile, "tls-cert", c.TLSCertFile, "TLS certificate file path")
+	fs.StringVar(&c.TLSKeyFile, "tls-key", c.TLSKeyFile, "TLS key file path")
+	fs.StringVar(&c.JWTKeys, "jwt-keys", c.JWTKeys, "JWT signing keys as kid:secret pairs separated by commas")
+	fs.StringVar(&c.JWTKeyFile, "jwt-key-file", c.JWTKeyFile, "file with JWT signing keys, one kid:secret pair per line")
+	fs.StringVar(&c.JWTActiveKID, "jwt-kid", c.JWTActiveKID, "kid of the key used to sign new tokens")
+	fs.Var(&c.TokenTTL, "token-ttl", "auth token lifetime")
+	fs.Var(&c.TokenRefresh, "token-refresh", "re-issue auth token when it expires within this period")
+	fs.StringVar(&c.MetricsAddr, "metrics-addr", c.MetricsAddr, "admin server address serving /metrics")
+	fs.BoolVar(&c.MigrateOnStart, "migrate", c.MigrateOnStart, "apply pending database migrations at startup")
+	fs.StringVar(&c.DedupeScope, "dedupe", c.DedupeScope, "dedupe scope for original URLs: user or global")
+	fs.StringVar(&c.IdentStrategy, "ident-strategy", c.IdentStrategy, "ident generator: sequence, random or hash")
+	fs.IntVar(&c.IdentLength, "ident-length", c.IdentLength, "generated ident length")
+	fs.StringVar(&c.IdentAlphabet, "ident-alphabet", c.IdentAlphabet, "symbols of generated idents")
+	fs.Var(&c.RestoreWindow, "restore-window", "period during which deleted links can be restored")
+	fs.Var(&c.PurgeAfter, "purge-after", "period after which deleted links are removed permanently")
+	fs.IntVar(&c.RateLimit, "rate-limit", c.RateLimit, "shorten, delete and sign-in requests a minute per user and per IP, 0 for no limit")
+	fs.IntVar(&c.RateBurst, "rate-burst", c.RateBurst, "shorten and delete requests allowed at once")
+	fs.IntVar(&c.RedirectRateLimit, "redirect-limit", c.RedirectRateLimit, "redirects a minute per user and per IP, 0 for no limit")
+	fs.IntVar(&c.RedirectRateBurst, "redirect-burst", c.RedirectRateBurst, "redirects allowed at once")
+	fs.IntVar(&c.LinkQuota, "link-quota", c.LinkQuota, "active links allowed per user, 0 for no limit")
+	fs.IntVar(&c.MaxBatchSize, "max-batch-size", c.MaxBatchSize, "links allowed in a batch, 0 for no limit")
+	fs.StringVar(&c.URLSchemes, "url-schemes", c.URLSchemes, "schemes allowed in destination URLs, separated by commas")
+	fs.BoolVar(&c.KeepURLFragment, "keep-fragment", c.KeepURLFragment, "keep fragments of destination URLs")
+	return fs
+}
+
+// LoadConfig builds the configuration from defaults, the JSON config file,
+// command line flags and environment variables, each overriding the previous.
+func LoadConfig(args []string) (*Config, error) {
+	probe := defaultConfig()
+	probeFlags := probe.flagSet()
+	probeFlags.SetOutput(io.Discard)
+	var configPath string
+	if err := probeFlags.Parse(args); err == nil {
+		configPath = probe.ConfigPath
+	}
+	if envConfigPath := os.Getenv(envNames["c"]); envConfigPath != "" {
+		configPath = envConfigPath
+	}
+
+	cfg := defaultConfig()
+	if configPath != "" {
+		if err := cfg.loadFile(configPath); err != nil {
+			return nil, err
+		}
+	}
+	fs := cfg.flagSet()
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	for name, env := range envNames {
+		if value := os.Getenv(env); value != "" {
+			if err := fs.Set(name, value); err != nil {
+				return nil, fmt.Errorf("invalid %s: %w", env, err)
+			}
+		}
+	}
+	cfg.ConfigPath = configPath
+	if cfg.BaseShortURL == "" {
+		cfg.BaseShortURL = cfg.scheme() + "://" + publicAddr(cfg.ServAddr)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+func InitConfig(args []string) error {
+	cfg, err := LoadConfig(args)
+	if err != nil {
+		return err
+	}
+	AppConfig = cfg
+	return nil
 }
 
-func InitConfig(servAddr, shortURL string) {
-	AppConfig = &Config{
-		ServAddr:     servAddr,
-		BaseShortURL: shortURL,
+func (c *Config) Validate() error {
+	var errs []error
+	if _, _, err := net.SplitHostPort(c.ServAddr); err != nil {
+		errs = append(errs, fmt.Errorf("invalid server_address %q: %w", c.ServAddr, err))
+	}
+	if u, err := url.Parse(c.BaseShortURL); err != nil || u.Hostname() == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		errs = append(errs, fmt.Errorf("invalid base_url %q: must be an absolute http(s) URL", c.BaseShortURL))
+	}
+	if _, err := zapcore.ParseLevel(c.LogLevel); err != nil {
+		errs = append(errs, fmt.Errorf("invalid log_level %q: %w", c.LogLevel, err))
+	}
+	if c.GRPCAddr != "" {
+		if _, _, err := net.SplitHostPort(c.GRPCAddr); err != nil {
+			errs = append(errs, fmt.Errorf("invalid grpc_address %q: %w", c.GRPCAddr, err))
+		}
+	}
+	if c.MetricsAddr != "" {
+		if _, _, err := net.SplitHostPort(c.MetricsAddr); err != nil {
+			errs = append(errs, fmt.Errorf("invalid metrics_address %q: %w", c.MetricsAddr, err))
+		}
+	}
+	if c.TrustedSubnet != "" {
+		if _, _, err := net.ParseCIDR(c.TrustedSubnet); err != nil {
+			errs = append(errs, fmt.Errorf("invalid trusted_subnet %q: %w", c.TrustedSubnet, err))
+		}
+	}
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		errs = append(errs, errors.New("tls_cert_file and tls_key_file must be set together"))
+	}
+	if c.TokenTTL <= 0 {
+		errs = append(errs, fmt.Errorf("invalid token_ttl %s: must be positive", c.TokenTTL))
+	}
+	if c.TokenRefresh < 0 || c.TokenRefresh >= c.TokenTTL {
+		errs = append(errs, fmt.Errorf("invalid token_refresh %s: must be between 0 and token_ttl", c.TokenRefresh))
+	}
+	if c.DedupeScope != DedupeUser && c.DedupeScope != DedupeGlobal {
+		errs = append(errs, fmt.Errorf("invalid dedupe_scope %q: must be %s or %s", c.DedupeScope, DedupeUser, DedupeGlobal))
+	}
+	if !identStrategies[c.IdentStrategy] {
+		errs = append(errs, fmt.Errorf("invalid ident_strategy %q: must be sequence, random or hash", c.IdentStrategy))
+	}
+	if c.IdentLength < 4 || c.IdentLength > 64 {
+		errs = append(errs, fmt.Errorf("invalid ident_length %d: must be between 4 and 64", c.IdentLength))
+	}
+	if c.RestoreWindow <= 0 {
+		errs = append(errs, fmt.Errorf("invalid restore_window %s: must be positive", c.RestoreWindow))
+	}
+	if c.PurgeAfter < c.RestoreWindow {
+		errs = append(errs, fmt.Errorf("invalid purge_after %s: must not be shorter than restore_window", c.PurgeAfter))
+	}
+	errs = append(errs, validateRateLimit("rate_limit", c.RateLimit, c.RateBurst))
+	errs = append(errs, validateRateLimit("redirect_rate_limit", c.RedirectRateLimit, c.RedirectRateBurst))
+	if c.LinkQuota < 0 {
+		errs = append(errs, fmt.Errorf("invalid link_quota %d: must not be negative", c.LinkQuota))
+	}
+	if c.MaxBatchSize < 0 {
+		errs = append(errs, fmt.Errorf("invalid max_batch_size %d: must not be negative", c.MaxBatchSize))
+	}
+	if len(c.AllowedSchemes()) == 0 {
+		errs = append(errs, errors.New("invalid url_schemes: no scheme listed"))
+	}
+	for _, v := range c.AllowedSchemes() {
+		if !validScheme(v) {
+			errs = append(errs, fmt.Errorf("invalid url_schemes: bad scheme %q", v))
+		}
+	}
+	if _, _, err := c.SigningKeys(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+func validateRateLimit(name string, limit, burst int) error {
+	if limit < 0 {
+		return fmt.Errorf("invalid %s %d: must not be negative", name, limit)
+	}
+	if limit > 0 && burst < 1 {
+		return fmt.Errorf("invalid burst %d of %s: must be positive", burst, name)
+	}
+	return nil
+}
+
+// AllowedSchemes returns the lowercased schemes of URLSchemes.
+func (c *Config) AllowedSchemes() []string {
+	var schemes []string
+	for _, v := range strings.Split(c.URLSchemes, ",") {
+		if v = strings.ToLower(strings.TrimSpace(v)); v != "" {
+			schemes = append(schemes, v)
+		}
+	}
+	return schemes
+}
+
+// validScheme checks the scheme syntax of RFC 3986: a letter followed by
+// letters, digits, "+", "-" or ".".
+func validScheme(scheme string) bool {
+	for i, r := range scheme {
+		switch {
+		case r >= 'a' && r <= 'z':
+		case i > 0 && (r >= '0' && r <= '9' || r == '+' || r == '-' || r == '.'):
+		default:
+			return false
+		}
+	}
+	return scheme != ""
+}
+
+// SigningKeys returns the JWT signing keys by kid and the kid used for new
+// tokens, which defaults to the last key listed.
+func (c *Config) SigningKeys() (map[string]string, string, error) {
+	keys := make(map[string]string)
+	var lastKID string
+	add := func(pair string) error {
+		pair = strings.TrimSpace(pair)
+		if pair == "" || strings.HasPrefix(pair, "#") {
+			return nil
+		}
+		kid, secret, ok := strings.Cut(pair, ":")
+		if !ok || kid == "" || secret == "" {
+			return errors.New("invalid jwt key: expected kid:secret")
+		}
+		keys[kid] = secret
+		lastKID = kid
+		return nil
+	}
+
+	if c.JWTKeyFile != "" {
+		data, err := os.ReadFile(c.JWTKeyFile)
+		if err != nil {
+			return nil, "", fmt.Errorf("read jwt_key_file: %w", err)
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			if err := add(line); err != nil {
+				return nil, "", err
+			}
+		}
+	}
+	if c.JWTKeys != "" {
+		for _, pair := range strings.Split(c.JWTKeys, ",") {
+			if err := add(pair); err != nil {
+				return nil, "", err
+			}
+		}
+	}
+
+	activeKID := c.JWTActiveKID
+	if activeKID == "" {
+		activeKID = lastKID
+	}
+	if _, ok := keys[activeKID]; activeKID != "" && !ok {
+		return nil, "", fmt.Errorf("invalid jwt_active_kid %q: no such key", activeKID)
+	}
+	return keys, activeKID, nil
+}
+
+// DefaultTLSFiles returns the paths of the self-signed certificate and key,
+// kept in a private directory of the user cache dir.
+func DefaultTLSFiles() (string, string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", "", err
+	}
+	dir = filepath.Join(dir, tlsCacheDir)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", "", err
+	}
+	return filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem"), nil
+}
+
+// publicAddr replaces an empty or wildcard host of a listen address with
+// localhost, so ":8080" gives a base URL clients can follow.
+func publicAddr(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	if ip := net.ParseIP(host); host == "" || ip != nil && ip.IsUnspecified() {
+		host = "localhost"
+	}
+	return net.JoinHostPort(host, port)
+}
+
+func (c *Config) scheme() string {
+	if c.EnableHTTPS {
+		return "https"
+	}
+	return "http"
+}
+
+func (c *Config) loadFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open config file: %w", err)
+	}
+	defer file.Close()
+
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(c); err != nil {
+		return fmt.Errorf("parse config file %s: %w", path, err)
 	}
+	return nil
 }
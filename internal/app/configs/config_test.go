@@ -0,0 +1,175 @@
+package configs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func expectedConfig(apply func(c *Config)) Config {
+	c := defaultConfig()
+	c.BaseShortURL = "http://localhost:8080"
+	apply(&c)
+	return c
+}
+
+func Test_LoadConfig(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.json")
+	err := os.WriteFile(configPath, []byte(`{
+		"server_address": "localhost:9090",
+		"base_url": "http://file.test:9090",
+		"file_storage_path": "",
+		"log_level": "debug",
+		"token_ttl": "1h"
+	}`), 0666)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		args        []string
+		env         map[string]string
+		expectedErr bool
+		expected    Config
+	}{
+		{
+			name:     "defaults",
+			args:     []string{},
+			expected: expectedConfig(func(c *Config) {}),
+		},
+		{
+			name: "file overrides defaults",
+			args: []string{"-c", configPath},
+			expected: expectedConfig(func(c *Config) {
+				c.ConfigPath = configPath
+				c.ServAddr = "localhost:9090"
+				c.BaseShortURL = "http://file.test:9090"
+				c.FileStoragePath = ""
+				c.LogLevel = "debug"
+				c.TokenTTL = Duration(time.Hour)
+			}),
+		},
+		{
+			name: "flags override file, env overrides flags",
+			args: []string{"-a", "localhost:7070", "-b", "http://flag.test:7070", "-token-ttl", "2h"},
+			env:  map[string]string{"CONFIG": configPath, "BASE_URL": "http://env.test"},
+			expected: expectedConfig(func(c *Config) {
+				c.ConfigPath = configPath
+				c.ServAddr = "localhost:7070"
+				c.BaseShortURL = "http://env.test"
+				c.FileStoragePath = ""
+				c.LogLevel = "debug"
+				c.TokenTTL = Duration(2 * time.Hour)
+			}),
+		},
+		{
+			name: "https switches default base url scheme",
+			args: []string{"-a", "localhost:8443"},
+			env:  map[string]string{"ENABLE_HTTPS": "true"},
+			expected: expectedConfig(func(c *Config) {
+				c.ServAddr = "localhost:8443"
+				c.BaseShortURL = "https://localhost:8443"
+				c.EnableHTTPS = true
+			}),
+		},
+		{
+			name: "base url of a wildcard address",
+			args: []string{"-a", ":8080"},
+			expected: expectedConfig(func(c *Config) {
+				c.ServAddr = ":8080"
+				c.BaseShortURL = "http://localhost:8080"
+			}),
+		},
+		{
+			name:        "invalid base url",
+			args:        []string{"-b", "localhost"},
+			expectedErr: true,
+		},
+		{
+			name:        "base url without host",
+			args:        []string{"-b", "http://:8080"},
+			expectedErr: true,
+		},
+		{
+			name:        "missing config file",
+			args:        []string{"-c", filepath.Join(t.TempDir(), "missing.json")},
+			expectedErr: true,
+		},
+		{
+			name: "rate limits from env",
+			env:  map[string]string{"RATE_LIMIT": "10", "RATE_BURST": "5", "REDIRECT_RATE_LIMIT": "0"},
+			expected: expectedConfig(func(c *Config) {
+				c.RateLimit = 10
+				c.RateBurst = 5
+				c.RedirectRateLimit = 0
+			}),
+		},
+		{
+			name: "url schemes from env",
+			env:  map[string]string{"URL_SCHEMES": " HTTPS, ftp ", "KEEP_URL_FRAGMENT": "true"},
+			expected: expectedConfig(func(c *Config) {
+				c.URLSchemes = " HTTPS, ftp "
+				c.KeepURLFragment = true
+			}),
+		},
+		{
+			name:        "invalid url scheme",
+			args:        []string{"-url-schemes", "https,java script"},
+			expectedErr: true,
+		},
+		{
+			name:        "negative link quota",
+			env:         map[string]string{"LINK_QUOTA": "-1"},
+			expectedErr: true,
+		},
+		{
+			name:        "rate limit without burst",
+			args:        []string{"-rate-limit", "10", "-rate-burst", "0"},
+			expectedErr: true,
+		},
+		{
+			name:        "unknown active kid",
+			args:        []string{"-jwt-keys", "k1:secret1", "-jwt-kid", "k2"},
+			expectedErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			cfg, err := LoadConfig(tt.args)
+			if tt.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, *cfg)
+		})
+	}
+}
+
+func Test_Config_SigningKeys(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "keys")
+	err := os.WriteFile(keyFile, []byte("# rotated keys\nold:secret-old\ncur:secret-cur\n"), 0600)
+	require.NoError(t, err)
+
+	cfg := Config{JWTKeyFile: keyFile, JWTKeys: "next:secret-next"}
+	keys, activeKID, err := cfg.SigningKeys()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"old": "secret-old", "cur": "secret-cur", "next": "secret-next"}, keys)
+	assert.Equal(t, "next", activeKID)
+
+	cfg.JWTActiveKID = "cur"
+	_, activeKID, err = cfg.SigningKeys()
+	require.NoError(t, err)
+	assert.Equal(t, "cur", activeKID)
+
+	cfg.JWTKeys = "broken"
+	_, _, err = cfg.SigningKeys()
+	assert.Error(t, err)
+}
@@ -0,0 +1,75 @@
+package grpchandlers
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	tokenMetadata           = "token"
+	userCTX       YSContext = "YSUserID"
+)
+
+type YSContext string
+
+// UserIdentity creates an anonymous user for calls without a token and sends
+// its token in the header metadata. A token that is near expiry or signed
+// with a retired key is re-issued the same way; an invalid one is refused.
+func (s *Server) UserIdentity(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	userID, token, err := s.userFromMetadata(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	if userID == 0 {
+		userID, err = s.services.CreateUser(ctx)
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+	}
+	if token == "" || s.services.NeedsRefresh(token) {
+		tokenVal, err := s.services.BuildJWTString(userID)
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		if err := grpc.SetHeader(ctx, metadata.Pairs(tokenMetadata, tokenVal)); err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+	}
+	return handler(context.WithValue(ctx, userCTX, userID), req)
+}
+
+// userFromMetadata returns the user and the token sent in the metadata, or
+// 0 if there is no token.
+func (s *Server) userFromMetadata(ctx context.Context) (int32, string, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return 0, "", nil
+	}
+	values := md.Get(tokenMetadata)
+	if len(values) == 0 || values[0] == "" {
+		return 0, "", nil
+	}
+	userID, valid, err := s.services.ParseToken(values[0])
+	if err != nil || !valid || userID <= 0 {
+		return 0, "", errors.New("invalid token")
+	}
+	return userID, values[0], nil
+}
+
+func getUserID(ctx context.Context) (int32, error) {
+	id := ctx.Value(userCTX)
+	if id == nil {
+		return -1, errors.New("not authorization")
+	}
+
+	idInt, ok := id.(int32)
+	if !ok {
+		return -1, errors.New("user id of invalid type")
+	}
+	return idInt, nil
+}
@@ -0,0 +1,72 @@
+package grpchandlers
+
+import (
+	"context"
+	"net"
+	"strconv"
+
+	pb "github.com/Aleksey-Andris/go-yandex-shortener/internal/app/delivery/grpchandlers/proto"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/metrics"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/ratelimit"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const rateClassWrite = "write"
+
+// writeMethods are the calls limited like the HTTP shorten and delete routes.
+var writeMethods = map[string]bool{
+	pb.Shortener_Shorten_FullMethodName:        true,
+	pb.Shortener_ShortenBatch_FullMethodName:   true,
+	pb.Shortener_DeleteUserURLs_FullMethodName: true,
+}
+
+// SetRateLimit sets the limit of shorten and delete calls and of calls that
+// create a user. A limit with no rate turns it off.
+func (s *Server) SetRateLimit(write ratelimit.Limit) {
+	s.limiter = nil
+	if write.Rate > 0 {
+		s.limiter = ratelimit.New(write)
+	}
+}
+
+// RateLimit takes a token from the buckets of the peer IP and of the user. It
+// runs before UserIdentity, so a call without a user counts as a write,
+// because it creates an anonymous user. Calls with an invalid token pass on
+// to UserIdentity, which refuses them.
+func (s *Server) RateLimit(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if s.limiter == nil {
+		return handler(ctx, req)
+	}
+	userID, _, err := s.userFromMetadata(ctx)
+	if err != nil {
+		return handler(ctx, req)
+	}
+	if userID > 0 && !writeMethods[info.FullMethod] {
+		return handler(ctx, req)
+	}
+
+	result := s.limiter.Allow("ip:" + peerIP(ctx))
+	if result.Allowed && userID > 0 {
+		result = s.limiter.Allow("user:" + strconv.Itoa(int(userID)))
+	}
+	if !result.Allowed {
+		metrics.RateLimited.WithLabelValues(rateClassWrite).Inc()
+		return nil, status.Errorf(codes.ResourceExhausted, "too many requests, retry in %s", result.RetryAfter)
+	}
+	return handler(ctx, req)
+}
+
+func peerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
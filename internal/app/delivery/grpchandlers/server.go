@@ -0,0 +1,183 @@
+package grpchandlers
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative proto/shortener.proto
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	pb "github.com/Aleksey-Andris/go-yandex-shortener/internal/app/delivery/grpchandlers/proto"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/delivery/handlers"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/domain"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/dto"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/ratelimit"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type Server struct {
+	pb.UnimplementedShortenerServer
+	services     *handlers.Service
+	baseShortURL string
+	limiter      *ratelimit.Limiter
+}
+
+func NewServer(services *handlers.Service, baseShortURL string) *Server {
+	return &Server{
+		services:     services,
+		baseShortURL: baseShortURL,
+	}
+}
+
+// InitServer builds the gRPC server; opts add to its options, such as
+// transport credentials.
+func (s *Server) InitServer(opts ...grpc.ServerOption) *grpc.Server {
+	server := grpc.NewServer(append(opts, grpc.ChainUnaryInterceptor(s.RateLimit, s.UserIdentity))...)
+	pb.RegisterShortenerServer(server, s)
+	return server
+}
+
+func (s *Server) Shorten(ctx context.Context, in *pb.ShortenRequest) (*pb.ShortenResponse, error) {
+	userID, err := getUserID(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	var conflict bool
+	ident, err := s.services.GetIdent(ctx, dto.LinkReq{
+		URL:        in.GetUrl(),
+		Alias:      in.GetAlias(),
+		TTLSeconds: in.GetTtlSeconds(),
+		ExpiresAt:  timeOrNil(in.GetExpiresAt()),
+	}, userID)
+	if err != nil {
+		if !errors.Is(err, domain.ErrConflict) {
+			return nil, statusFromErr(err)
+		}
+		conflict = true
+	}
+	return &pb.ShortenResponse{Result: s.baseShortURL + "/" + ident, Conflict: conflict}, nil
+}
+
+func (s *Server) ShortenBatch(ctx context.Context, in *pb.ShortenBatchRequest) (*pb.ShortenBatchResponse, error) {
+	userID, err := getUserID(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	if err := s.services.CheckBatchSize(len(in.GetItems())); err != nil {
+		return nil, statusFromErr(err)
+	}
+
+	linkReq := make([]dto.LinkListReq, 0, len(in.GetItems()))
+	for _, v := range in.GetItems() {
+		linkReq = append(linkReq, dto.LinkListReq{
+			CorrelationID: v.GetCorrelationId(),
+			OriginalURL:   v.GetOriginalUrl(),
+			Alias:         v.GetAlias(),
+			TTLSeconds:    v.GetTtlSeconds(),
+			ExpiresAt:     timeOrNil(v.GetExpiresAt()),
+		})
+	}
+	linkRes, err := s.services.GetIdents(ctx, linkReq, userID)
+	if err != nil {
+		return nil, statusFromErr(err)
+	}
+
+	response := &pb.ShortenBatchResponse{Items: make([]*pb.ShortenBatchResult, 0, len(linkRes))}
+	for _, v := range linkRes {
+		response.Items = append(response.Items, &pb.ShortenBatchResult{
+			CorrelationId: v.CorrelationID,
+			ShortUrl:      s.baseShortURL + "/" + v.ShortURL,
+		})
+	}
+	return response, nil
+}
+
+func (s *Server) Expand(ctx context.Context, in *pb.ExpandRequest) (*pb.ExpandResponse, error) {
+	link, err := s.services.GetFulLink(ctx, in.GetIdent())
+	if err != nil {
+		return nil, statusFromErr(err)
+	}
+	if link.DeletedFlag || link.IsExpired(time.Now()) {
+		return nil, status.Error(codes.NotFound, "resurs deleted")
+	}
+	return &pb.ExpandResponse{OriginalUrl: link.FulLink}, nil
+}
+
+func (s *Server) ListUserURLs(ctx context.Context, in *pb.ListUserURLsRequest) (*pb.ListUserURLsResponse, error) {
+	userID, err := getUserID(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	page, err := s.services.GetLinksByUserID(ctx, userID, dto.LinkPageReq{
+		Limit:  int(in.GetLimit()),
+		Cursor: in.GetCursor(),
+		Sort:   in.GetSort(),
+		Order:  in.GetOrder(),
+		Search: in.GetSearch(),
+	})
+	if err != nil {
+		return nil, statusFromErr(err)
+	}
+	response := &pb.ListUserURLsResponse{
+		Urls:       make([]*pb.UserURL, 0, len(page.Links)),
+		NextCursor: page.NextCursor,
+	}
+	for _, v := range page.Links {
+		response.Urls = append(response.Urls, &pb.UserURL{
+			OriginalUrl: v.OriginalURL,
+			ShortUrl:    s.baseShortURL + "/" + v.ShortURL,
+		})
+	}
+	return response, nil
+}
+
+func (s *Server) DeleteUserURLs(ctx context.Context, in *pb.DeleteUserURLsRequest) (*pb.DeleteUserURLsResponse, error) {
+	userID, err := getUserID(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	can, err := s.services.CanDelete(ctx, userID, in.GetIdents()...)
+	if err != nil {
+		return nil, statusFromErr(err)
+	}
+	if !can {
+		return nil, status.Error(codes.PermissionDenied, "forbidden")
+	}
+	job, err := s.services.EnqueueDelete(ctx, userID, in.GetIdents()...)
+	if err != nil {
+		return nil, statusFromErr(err)
+	}
+	return &pb.DeleteUserURLsResponse{JobId: job.ID, Status: job.Status}, nil
+}
+
+func statusFromErr(err error) error {
+	switch {
+	case errors.Is(err, domain.ErrInvalidAlias), errors.Is(err, domain.ErrInvalidExpiry), errors.Is(err, domain.ErrInvalidQuery),
+		errors.Is(err, domain.ErrInvalidURL):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, domain.ErrAliasTaken), errors.Is(err, domain.ErrConflict):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, domain.ErrNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, domain.ErrForbidden):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, domain.ErrQuotaExceeded), errors.Is(err, domain.ErrBatchTooLarge):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+func timeOrNil(ts *timestamppb.Timestamp) *time.Time {
+	if ts == nil {
+		return nil
+	}
+	t := ts.AsTime()
+	return &t
+}
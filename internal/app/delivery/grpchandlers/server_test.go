@@ -0,0 +1,172 @@
+package grpchandlers
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	pb "github.com/Aleksey-Andris/go-yandex-shortener/internal/app/delivery/grpchandlers/proto"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/delivery/handlers"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/domain"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/ratelimit"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/service"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/storage/hashmapstorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func newTestClient(t *testing.T, setup ...func(*Server)) pb.ShortenerClient {
+	linkStorage, _ := hashmapstorage.NewLinkStorage(make(map[string]domain.Link), "")
+	clickStorage, _ := hashmapstorage.NewClickStorage("")
+	servises := handlers.NewServices(linkStorage, linkStorage, clickStorage, linkStorage, linkStorage, service.AuthConfig{}, service.LinkConfig{})
+	srv := NewServer(servises, "http://localhost:8080")
+	for _, f := range setup {
+		f(srv)
+	}
+	server := srv.InitServer()
+
+	listener := bufconn.Listen(1024 * 1024)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return pb.NewShortenerClient(conn)
+}
+
+func Test_Server_ShortenAndExpand(t *testing.T) {
+	client := newTestClient(t)
+
+	var header metadata.MD
+	shortRes, err := client.Shorten(context.Background(), &pb.ShortenRequest{Url: "https://practicum.test.ru/", Alias: "grpc-link"}, grpc.Header(&header))
+	require.NoError(t, err)
+	assert.Equal(t, "http://localhost:8080/grpc-link", shortRes.GetResult())
+	require.NotEmpty(t, header.Get(tokenMetadata))
+
+	expandRes, err := client.Expand(context.Background(), &pb.ExpandRequest{Ident: "grpc-link"})
+	require.NoError(t, err)
+	assert.Equal(t, "https://practicum.test.ru/", expandRes.GetOriginalUrl())
+
+	_, err = client.Expand(context.Background(), &pb.ExpandRequest{Ident: "unknown"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = client.Shorten(context.Background(), &pb.ShortenRequest{Url: "https://practicum.test.ru/", Alias: "api"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func Test_Server_UserURLs(t *testing.T) {
+	var srv *Server
+	client := newTestClient(t, func(s *Server) { srv = s })
+
+	var header metadata.MD
+	_, err := client.ShortenBatch(context.Background(), &pb.ShortenBatchRequest{Items: []*pb.ShortenBatchItem{
+		{CorrelationId: "1", OriginalUrl: "https://practicum.test1.ru/"},
+		{CorrelationId: "2", OriginalUrl: "https://practicum.test2.ru/", Alias: "grpc-batch"},
+	}}, grpc.Header(&header))
+	require.NoError(t, err)
+	ownerCtx := metadata.AppendToOutgoingContext(context.Background(), tokenMetadata, header.Get(tokenMetadata)[0])
+
+	listRes, err := client.ListUserURLs(ownerCtx, &pb.ListUserURLsRequest{})
+	require.NoError(t, err)
+	assert.Len(t, listRes.GetUrls(), 2)
+
+	_, err = client.DeleteUserURLs(context.Background(), &pb.DeleteUserURLsRequest{Idents: []string{"grpc-batch"}})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	deleteRes, err := client.DeleteUserURLs(ownerCtx, &pb.DeleteUserURLsRequest{Idents: []string{"grpc-batch"}})
+	require.NoError(t, err)
+	assert.NotZero(t, deleteRes.GetJobId())
+	assert.Equal(t, domain.DeleteJobPending, deleteRes.GetStatus())
+
+	listRes, err = client.ListUserURLs(ownerCtx, &pb.ListUserURLsRequest{})
+	require.NoError(t, err)
+	assert.Len(t, listRes.GetUrls(), 2, "links are deleted by the delete job worker")
+	jobs, err := srv.services.ProcessDeleteJobs(context.Background())
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+	assert.Equal(t, domain.DeleteJobDone, jobs[0].Status)
+
+	listRes, err = client.ListUserURLs(ownerCtx, &pb.ListUserURLsRequest{})
+	require.NoError(t, err)
+	assert.Len(t, listRes.GetUrls(), 1)
+}
+
+func Test_Server_RateLimit(t *testing.T) {
+	client := newTestClient(t, func(s *Server) {
+		s.SetRateLimit(ratelimit.Limit{Rate: 0.001, Burst: 2})
+	})
+
+	// Calls without a token create users, so they are limited by peer.
+	var header metadata.MD
+	_, err := client.Expand(context.Background(), &pb.ExpandRequest{Ident: "unknown"}, grpc.Header(&header))
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	token := header.Get(tokenMetadata)
+	require.NotEmpty(t, token)
+	_, err = client.Expand(context.Background(), &pb.ExpandRequest{Ident: "unknown"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	_, err = client.Expand(context.Background(), &pb.ExpandRequest{Ident: "unknown"})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	// Reads of a known user are not limited, writes are.
+	ctx := metadata.AppendToOutgoingContext(context.Background(), tokenMetadata, token[0])
+	_, err = client.Expand(ctx, &pb.ExpandRequest{Ident: "unknown"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	_, err = client.Shorten(ctx, &pb.ShortenRequest{Url: "https://practicum.test.ru/"})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+}
+
+func Test_Server_UserIdentity(t *testing.T) {
+	t.Run("invalid token", func(t *testing.T) {
+		client := newTestClient(t)
+		ctx := metadata.AppendToOutgoingContext(context.Background(), tokenMetadata, "not-a-token")
+		_, err := client.ListUserURLs(ctx, &pb.ListUserURLsRequest{})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("refresh near expiry", func(t *testing.T) {
+		client := newTestClient(t, func(s *Server) {
+			linkStorage, _ := hashmapstorage.NewLinkStorage(make(map[string]domain.Link), "")
+			clickStorage, _ := hashmapstorage.NewClickStorage("")
+			authCfg := service.AuthConfig{TokenExp: time.Hour, RefreshBefore: 2 * time.Hour}
+			s.services = handlers.NewServices(linkStorage, linkStorage, clickStorage, linkStorage, linkStorage, authCfg, service.LinkConfig{})
+		})
+
+		var header metadata.MD
+		_, err := client.ListUserURLs(context.Background(), &pb.ListUserURLsRequest{}, grpc.Header(&header))
+		require.NoError(t, err)
+		require.NotEmpty(t, header.Get(tokenMetadata))
+		token := header.Get(tokenMetadata)[0]
+
+		ctx := metadata.AppendToOutgoingContext(context.Background(), tokenMetadata, token)
+		header = nil
+		_, err = client.ListUserURLs(ctx, &pb.ListUserURLsRequest{}, grpc.Header(&header))
+		require.NoError(t, err)
+		require.NotEmpty(t, header.Get(tokenMetadata), "a token near expiry is re-issued")
+	})
+
+	t.Run("fresh token", func(t *testing.T) {
+		client := newTestClient(t)
+		var header metadata.MD
+		_, err := client.ListUserURLs(context.Background(), &pb.ListUserURLsRequest{}, grpc.Header(&header))
+		require.NoError(t, err)
+		require.NotEmpty(t, header.Get(tokenMetadata))
+
+		ctx := metadata.AppendToOutgoingContext(context.Background(), tokenMetadata, header.Get(tokenMetadata)[0])
+		header = nil
+		_, err = client.ListUserURLs(ctx, &pb.ListUserURLsRequest{}, grpc.Header(&header))
+		require.NoError(t, err)
+		assert.Empty(t, header.Get(tokenMetadata))
+	})
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/domain"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/dto"
+	"github.com/go-chi/chi"
+)
+
+// CreateAPIKey issues an API key to a registered user. Keys of anonymous
+// users would be lost with their cookie.
+func (h *Handler) CreateAPIKey(res http.ResponseWriter, req *http.Request) {
+	userID, err := getUserID(req.Context())
+	if err != nil {
+		http.Error(res, "failded getting userID", http.StatusBadRequest)
+		return
+	}
+
+	registered, err := h.services.IsRegistered(req.Context(), userID)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !registered {
+		http.Error(res, "register to create api keys", http.StatusForbidden)
+		return
+	}
+
+	ct := req.Header.Get(сontentType)
+	if !(ct == сontentTypeAppJSON || ct == сontentTypeAppXGZIP) {
+		http.Error(res, "invalid Content-Type", http.StatusBadRequest)
+		return
+	}
+	var keyReq dto.APIKeyReq
+	if err := json.NewDecoder(req.Body).Decode(&keyReq); err != nil {
+		http.Error(res, "invalid format body", http.StatusBadRequest)
+		return
+	}
+
+	keyRes, err := h.services.CreateAPIKey(req.Context(), userID, keyReq)
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidQuery) || errors.Is(err, domain.ErrInvalidExpiry) {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response, err := json.Marshal(&keyRes)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	res.Header().Set(сontentType, сontentTypeAppJSON)
+	res.WriteHeader(http.StatusCreated)
+	res.Write(response)
+}
+
+func (h *Handler) GetAPIKeys(res http.ResponseWriter, req *http.Request) {
+	userID, err := getUserID(req.Context())
+	if err != nil {
+		http.Error(res, "failded getting userID", http.StatusBadRequest)
+		return
+	}
+
+	keys, err := h.services.GetAPIKeys(req.Context(), userID)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(keys) == 0 {
+		res.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	response, err := json.Marshal(&keys)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	res.Header().Set(сontentType, сontentTypeAppJSON)
+	res.WriteHeader(http.StatusOK)
+	res.Write(response)
+}
+
+func (h *Handler) RevokeAPIKey(res http.ResponseWriter, req *http.Request) {
+	userID, err := getUserID(req.Context())
+	if err != nil {
+		http.Error(res, "failded getting userID", http.StatusBadRequest)
+		return
+	}
+
+	id, err := strconv.ParseInt(chi.URLParam(req, "id"), 10, 64)
+	if err != nil {
+		http.Error(res, "invalid key id", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.services.RevokeAPIKey(req.Context(), userID, id); err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			http.Error(res, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	res.WriteHeader(http.StatusNoContent)
+}
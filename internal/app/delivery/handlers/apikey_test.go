@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/domain"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/dto"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/service"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/storage/hashmapstorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Handler_APIKeys(t *testing.T) {
+	linkStorage, _ := hashmapstorage.NewLinkStorage(make(map[string]domain.Link), "")
+	clickStorage, _ := hashmapstorage.NewClickStorage("")
+	servises := NewServices(linkStorage, linkStorage, clickStorage, linkStorage, linkStorage, service.AuthConfig{}, service.LinkConfig{})
+	handler := NewHandler(servises, "http://localhost:8080")
+	testServ := httptest.NewServer(handler.InitRouter())
+	defer testServ.Close()
+
+	jar, err := cookiejar.New(nil)
+	require.NoError(t, err)
+	session := *testServ.Client()
+	session.Jar = jar
+
+	do := func(t *testing.T, client *http.Client, method, path, apiKey, body string) (int, []byte) {
+		req, err := http.NewRequest(method, testServ.URL+path, strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		if apiKey != "" {
+			req.Header.Set("X-API-Key", apiKey)
+		}
+		res, err := client.Do(req)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		resBody, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		return res.StatusCode, resBody
+	}
+	client := testServ.Client()
+
+	status, _ := do(t, &session, http.MethodPost, "/api/user/keys", "", `{"name":"ci"}`)
+	assert.Equal(t, http.StatusForbidden, status)
+	status, _ = do(t, &session, http.MethodPost, "/api/user/keys", "", `{"name":`)
+	assert.Equal(t, http.StatusForbidden, status, "anonymous callers are refused whatever the body")
+	status, _ = do(t, &session, http.MethodPost, "/api/user/register", "", `{"login":"ci-owner","password":"correct horse"}`)
+	require.Equal(t, http.StatusCreated, status)
+
+	status, _ = do(t, &session, http.MethodPost, "/api/user/keys", "", `{"name":"ci","scopes":["write"]}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+	status, body := do(t, &session, http.MethodPost, "/api/user/keys", "", `{"name":"ci","scopes":["shorten","read"]}`)
+	require.Equal(t, http.StatusCreated, status)
+	var key dto.APIKeyRes
+	require.NoError(t, json.Unmarshal(body, &key))
+	require.True(t, strings.HasPrefix(key.Key, key.Prefix))
+	assert.Equal(t, []string{"shorten", "read"}, key.Scopes)
+
+	status, _ = do(t, client, http.MethodPost, "/api/shorten", key.Key, `{"url":"https://release.example"}`)
+	assert.Equal(t, http.StatusCreated, status)
+	status, body = do(t, &session, http.MethodGet, "/api/user/urls", "", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, string(body), "https://release.example")
+	status, _ = do(t, client, http.MethodDelete, "/api/user/urls", key.Key, `["some"]`)
+	assert.Equal(t, http.StatusForbidden, status)
+	status, _ = do(t, client, http.MethodGet, "/api/user/keys", key.Key, "")
+	assert.Equal(t, http.StatusForbidden, status)
+	status, _ = do(t, client, http.MethodPost, "/api/user/register", key.Key, `{"login":"intruder","password":"correct horse"}`)
+	assert.Equal(t, http.StatusForbidden, status)
+	status, _ = do(t, client, http.MethodPost, "/api/user/login", key.Key, `{"login":"ci-owner","password":"correct horse"}`)
+	assert.Equal(t, http.StatusForbidden, status)
+	status, _ = do(t, client, http.MethodGet, "/api/user/urls", "ys_unknown", "")
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	status, body = do(t, &session, http.MethodGet, "/api/user/keys", "", "")
+	require.Equal(t, http.StatusOK, status)
+	var keys []dto.APIKeyRes
+	require.NoError(t, json.Unmarshal(body, &keys))
+	require.Len(t, keys, 1)
+	assert.Empty(t, keys[0].Key)
+
+	status, _ = do(t, &session, http.MethodDelete, "/api/user/keys/"+strconv.FormatInt(key.ID, 10), "", "")
+	assert.Equal(t, http.StatusNoContent, status)
+	status, _ = do(t, &session, http.MethodDelete, "/api/user/keys/"+strconv.FormatInt(key.ID, 10), "", "")
+	assert.Equal(t, http.StatusNotFound, status)
+	status, _ = do(t, client, http.MethodGet, "/api/user/urls", key.Key, "")
+	assert.Equal(t, http.StatusUnauthorized, status)
+}
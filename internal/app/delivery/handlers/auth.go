@@ -4,17 +4,36 @@ import (
 	"context"
 	"errors"
 	"net/http"
+	"strings"
+
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/domain"
 )
 
 const (
 	authorizationHeader           = "Authorization"
+	apiKeyHeader                  = "X-API-Key"
+	bearerPrefix                  = "Bearer "
 	userCTX             YSContext = "YSUserID"
+	tokenCTX            YSContext = "YSToken"
+	apiKeyCTX           YSContext = "YSAPIKey"
 )
 
 type YSContext string
 
+// userIdentity resolves the user from the X-API-Key header, the Authorization
+// bearer token or, when both are absent, from the token cookie. An invalid API
+// key or bearer token is rejected, while an invalid cookie is treated as an
+// anonymous request.
 func (h *Handler) userIdentity(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if apiKey := req.Header.Get(apiKeyHeader); apiKey != "" {
+			h.apiKeyIdentity(next, res, req, apiKey)
+			return
+		}
+		if authHeader := req.Header.Get(authorizationHeader); authHeader != "" {
+			h.bearerIdentity(next, res, req, authHeader)
+			return
+		}
 
 		cookieToken, err := req.Cookie("token")
 		if err != nil {
@@ -30,12 +49,13 @@ func (h *Handler) userIdentity(next http.Handler) http.Handler {
 		userID, valid, err := h.services.ParseToken(tokenString)
 
 		if err != nil {
-			http.Error(res, err.Error(), http.StatusInternalServerError)
+			next.ServeHTTP(res, req)
 			return
 		}
 
 		if userID == 0 {
 			http.Error(res, "not authorization", http.StatusUnauthorized)
+			return
 		}
 
 		if !valid {
@@ -43,12 +63,70 @@ func (h *Handler) userIdentity(next http.Handler) http.Handler {
 			return
 		}
 
-		request := req.WithContext(context.WithValue(req.Context(), userCTX, userID))
+		ctx := context.WithValue(req.Context(), userCTX, userID)
+		request := req.WithContext(context.WithValue(ctx, tokenCTX, tokenString))
 
 		next.ServeHTTP(res, request)
 	})
 }
 
+func (h *Handler) bearerIdentity(next http.Handler, res http.ResponseWriter, req *http.Request, authHeader string) {
+	if !strings.HasPrefix(authHeader, bearerPrefix) {
+		http.Error(res, "invalid authorization header", http.StatusUnauthorized)
+		return
+	}
+	tokenString := strings.TrimSpace(strings.TrimPrefix(authHeader, bearerPrefix))
+	userID, valid, err := h.services.ParseToken(tokenString)
+	if err != nil || !valid || userID <= 0 {
+		http.Error(res, "invalid token", http.StatusUnauthorized)
+		return
+	}
+
+	ctx := context.WithValue(req.Context(), userCTX, userID)
+	next.ServeHTTP(res, req.WithContext(context.WithValue(ctx, tokenCTX, tokenString)))
+}
+
+func (h *Handler) apiKeyIdentity(next http.Handler, res http.ResponseWriter, req *http.Request, secret string) {
+	key, err := h.services.ParseAPIKey(req.Context(), strings.TrimSpace(secret))
+	if errors.Is(err, domain.ErrInvalidAPIKey) {
+		http.Error(res, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	ctx := context.WithValue(req.Context(), userCTX, key.UserID)
+	next.ServeHTTP(res, req.WithContext(context.WithValue(ctx, apiKeyCTX, key)))
+}
+
+// requireScope rejects requests made with an API key that lacks scope. Users
+// signed in by cookie or bearer token have every scope.
+func (h *Handler) requireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			if key, ok := req.Context().Value(apiKeyCTX).(domain.APIKey); ok && !key.HasScope(scope) {
+				http.Error(res, "api key lacks scope "+scope, http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(res, req)
+		})
+	}
+}
+
+// sessionOnly rejects requests made with an API key, so a leaked key cannot
+// be used to issue more keys or to sign in to, or register over, its owner.
+func (h *Handler) sessionOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if _, ok := req.Context().Value(apiKeyCTX).(domain.APIKey); ok {
+			http.Error(res, "api keys cannot be used here, sign in instead", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(res, req)
+	})
+}
+
 func (h *Handler) setTokenID(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
 
@@ -58,6 +136,14 @@ func (h *Handler) setTokenID(next http.Handler) http.Handler {
 			return
 		}
 		if userID > 0 {
+			if token, ok := req.Context().Value(tokenCTX).(string); ok && h.services.NeedsRefresh(token) {
+				tokenVal, err := h.services.BuildJWTString(userID)
+				if err != nil {
+					http.Error(res, err.Error(), http.StatusInternalServerError)
+					return
+				}
+				h.issueToken(res, tokenVal)
+			}
 			next.ServeHTTP(res, req)
 			return
 		}
@@ -71,16 +157,36 @@ func (h *Handler) setTokenID(next http.Handler) http.Handler {
 			http.Error(res, err.Error(), http.StatusInternalServerError)
 			return
 		}
-		http.SetCookie(res, &http.Cookie{
-			Name:     "token",
-			Value:    tokenVal,
-			HttpOnly: true,
-		})
+		h.issueToken(res, tokenVal)
 		request := req.WithContext(context.WithValue(req.Context(), userCTX, userID))
 		next.ServeHTTP(res, request)
 	})
 }
 
+func (h *Handler) SetSecureCookie(secure bool) {
+	h.secureCookie = secure
+}
+
+func (h *Handler) issueToken(res http.ResponseWriter, tokenVal string) {
+	http.SetCookie(res, h.tokenCookie(tokenVal))
+	res.Header().Set(authorizationHeader, bearerPrefix+tokenVal)
+}
+
+func (h *Handler) tokenCookie(tokenVal string) *http.Cookie {
+	cookie := &http.Cookie{
+		Name:     "token",
+		Value:    tokenVal,
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+	if h.secureCookie {
+		cookie.Secure = true
+		cookie.SameSite = http.SameSiteStrictMode
+	}
+	return cookie
+}
+
 func (h *Handler) getNewUserID(ctx context.Context) (int32, error) {
 	return h.services.CreateUser(ctx)
 
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/domain"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/mock/mockservice"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func Test_Handler_userIdentity(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	userStorage := mockservice.NewMockUserStorage(c)
+	linkStorage := mockservice.NewMockLinkStorage(c)
+	clickStorage := mockservice.NewMockClickStorage(c)
+	jobStorage := mockservice.NewMockDeleteJobStorage(c)
+	keyStorage := mockservice.NewMockAPIKeyStorage(c)
+	servises := NewServices(linkStorage, userStorage, clickStorage, jobStorage, keyStorage, service.AuthConfig{}, service.LinkConfig{})
+	handler := NewHandler(servises, "http://localhost:8080")
+	testServ := httptest.NewServer(handler.InitRouter())
+	defer testServ.Close()
+
+	tokenUser5, err := servises.BuildJWTString(5)
+	require.NoError(t, err)
+	tokenUser6, err := servises.BuildJWTString(6)
+	require.NoError(t, err)
+
+	link := domain.Link{ID: 1, FulLink: "some_oriq_url", Ident: "some_ident"}
+
+	type mocBehavior func(sa *mockservice.MockUserStorage, sl *mockservice.MockLinkStorage)
+	tests := []struct {
+		name               string
+		authHeader         string
+		cookie             string
+		expectedStatusCode int
+		expectedNewToken   bool
+		mocBehavior        mocBehavior
+	}{
+		{
+			name:               "anonymous gets token in header and cookie",
+			expectedStatusCode: http.StatusOK,
+			expectedNewToken:   true,
+			mocBehavior: func(sa *mockservice.MockUserStorage, sl *mockservice.MockLinkStorage) {
+				sa.EXPECT().CreateUser(gomock.Any()).Return(int32(1), nil)
+				sl.EXPECT().GetLinksByUserID(gomock.Any(), int32(1), gomock.Any()).Return([]domain.Link{link}, nil)
+			},
+		},
+
+		{
+			name:               "bearer token",
+			authHeader:         "Bearer " + tokenUser5,
+			expectedStatusCode: http.StatusOK,
+			mocBehavior: func(sa *mockservice.MockUserStorage, sl *mockservice.MockLinkStorage) {
+				sl.EXPECT().GetLinksByUserID(gomock.Any(), int32(5), gomock.Any()).Return([]domain.Link{link}, nil)
+			},
+		},
+
+		{
+			name:               "bearer token takes precedence over cookie",
+			authHeader:         "Bearer " + tokenUser5,
+			cookie:             tokenUser6,
+			expectedStatusCode: http.StatusOK,
+			mocBehavior: func(sa *mockservice.MockUserStorage, sl *mockservice.MockLinkStorage) {
+				sl.EXPECT().GetLinksByUserID(gomock.Any(), int32(5), gomock.Any()).Return([]domain.Link{link}, nil)
+			},
+		},
+
+		{
+			name:               "cookie token",
+			cookie:             tokenUser6,
+			expectedStatusCode: http.StatusOK,
+			mocBehavior: func(sa *mockservice.MockUserStorage, sl *mockservice.MockLinkStorage) {
+				sl.EXPECT().GetLinksByUserID(gomock.Any(), int32(6), gomock.Any()).Return([]domain.Link{link}, nil)
+			},
+		},
+
+		{
+			name:               "invalid bearer token",
+			authHeader:         "Bearer broken",
+			expectedStatusCode: http.StatusUnauthorized,
+			mocBehavior:        func(sa *mockservice.MockUserStorage, sl *mockservice.MockLinkStorage) {},
+		},
+
+		{
+			name:               "unsupported authorization scheme",
+			authHeader:         "Basic dXNlcjpwYXNz",
+			expectedStatusCode: http.StatusUnauthorized,
+			mocBehavior:        func(sa *mockservice.MockUserStorage, sl *mockservice.MockLinkStorage) {},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mocBehavior(userStorage, linkStorage)
+
+			req, err := http.NewRequest(http.MethodGet, testServ.URL+"/api/user/urls", nil)
+			require.NoError(t, err)
+			if tt.authHeader != "" {
+				req.Header.Set("Authorization", tt.authHeader)
+			}
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: "token", Value: tt.cookie})
+			}
+
+			res, err := testServ.Client().Do(req)
+			require.NoError(t, err)
+			defer res.Body.Close()
+
+			assert.Equal(t, tt.expectedStatusCode, res.StatusCode)
+			if tt.expectedNewToken {
+				assert.True(t, strings.HasPrefix(res.Header.Get("Authorization"), "Bearer "))
+				assert.NotEmpty(t, res.Cookies())
+			} else {
+				assert.Empty(t, res.Header.Get("Authorization"))
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/domain"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/logger"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/metrics"
+	"github.com/go-chi/chi"
+)
+
+const (
+	clickBufSize       = 1024
+	clickFlushInterval = time.Second
+	// maxPendingClicks bounds the clicks kept while storage fails. Past it the
+	// oldest clickBufSize clicks are dropped.
+	maxPendingClicks = 64 * clickBufSize
+)
+
+func (h *Handler) GetLinkStats(res http.ResponseWriter, req *http.Request) {
+	userID, err := getUserID(req.Context())
+	if err != nil {
+		http.Error(res, "failded getting userID", http.StatusBadRequest)
+		return
+	}
+
+	stats, err := h.services.GetLinkStats(req.Context(), chi.URLParam(req, "ident"), userID)
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			http.Error(res, err.Error(), http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, domain.ErrForbidden) {
+			http.Error(res, err.Error(), http.StatusForbidden)
+			return
+		}
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response, err := json.Marshal(&stats)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	res.Header().Set(сontentType, сontentTypeAppJSON)
+	res.WriteHeader(http.StatusOK)
+	res.Write(response)
+}
+
+func (h *Handler) recordClick(req *http.Request, ident string) {
+	click := domain.Click{
+		Ident:     ident,
+		ClickedAt: time.Now(),
+		Referrer:  req.Referer(),
+		UserAgent: req.UserAgent(),
+		ClientIP:  h.clientIP(req),
+	}
+	select {
+	case h.clickChan <- click:
+	default:
+		metrics.ClicksDropped.Inc()
+		logger.Log().Debug("click buffer is full, click dropped")
+	}
+}
+
+func (h *Handler) flushClicks(stop <-chan bool) {
+	defer close(h.clickDoneChan)
+	ticker := time.NewTicker(clickFlushInterval)
+	defer ticker.Stop()
+	clicksBuf := make([]domain.Click, 0)
+	for {
+		select {
+		case click := <-h.clickChan:
+			if len(clicksBuf) >= maxPendingClicks {
+				clicksBuf = dropOldestClicks(clicksBuf)
+			}
+			clicksBuf = append(clicksBuf, click)
+		case <-ticker.C:
+			if len(clicksBuf) == 0 {
+				continue
+			}
+			if err := h.services.RecordClicks(context.Background(), clicksBuf...); err != nil {
+				logger.Log().Debug("cannot record clicks")
+				continue
+			}
+			clicksBuf = clicksBuf[:0]
+		case <-stop:
+			for len(h.clickChan) > 0 {
+				clicksBuf = append(clicksBuf, <-h.clickChan)
+			}
+			if err := h.services.RecordClicks(context.Background(), clicksBuf...); err != nil {
+				logger.Log().Debug("cannot record clicks when stop")
+			}
+			return
+		}
+	}
+}
+
+// dropOldestClicks drops the oldest clickBufSize clicks of a buffer that
+// storage has not taken.
+func dropOldestClicks(clicks []domain.Click) []domain.Click {
+	dropped := clickBufSize
+	if dropped > len(clicks) {
+		dropped = len(clicks)
+	}
+	metrics.ClicksDropped.Add(float64(dropped))
+	logger.Log().Error("cannot record clicks, dropped the oldest " + strconv.Itoa(dropped))
+	n := copy(clicks, clicks[dropped:])
+	return clicks[:n]
+}
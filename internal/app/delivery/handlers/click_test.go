@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/domain"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/dto"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/mock/mockservice"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/service"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/storage/hashmapstorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func Test_Handler_GetLinkStats(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	userStorage := mockservice.NewMockUserStorage(c)
+	linkStorage := mockservice.NewMockLinkStorage(c)
+	clickStorage := mockservice.NewMockClickStorage(c)
+	jobStorage := mockservice.NewMockDeleteJobStorage(c)
+	keyStorage := mockservice.NewMockAPIKeyStorage(c)
+	servises := NewServices(linkStorage, userStorage, clickStorage, jobStorage, keyStorage, service.AuthConfig{}, service.LinkConfig{})
+	handler := NewHandler(servises, "http://localhost:8080")
+	testServ := httptest.NewServer(handler.InitRouter())
+	defer testServ.Close()
+
+	type mocBehavior func(sa *mockservice.MockUserStorage, sl *mockservice.MockLinkStorage, sc *mockservice.MockClickStorage)
+	tests := []struct {
+		name               string
+		requestURL         string
+		expectedStatusCode int
+		expectedTotal      int64
+		mocBehavior        mocBehavior
+	}{
+		{
+			name:               "stats - simple case",
+			requestURL:         "/api/user/urls/some_ident/stats",
+			expectedStatusCode: http.StatusOK,
+			expectedTotal:      3,
+			mocBehavior: func(sa *mockservice.MockUserStorage, sl *mockservice.MockLinkStorage, sc *mockservice.MockClickStorage) {
+				link := domain.Link{
+					ID:      1,
+					Ident:   "some_ident",
+					FulLink: "some_link",
+					UserID:  1,
+				}
+				stats := dto.LinkStatsRes{
+					TotalClicks:  3,
+					ClicksPerDay: []dto.ClicksPerDay{{Day: "2023-10-01", Clicks: 3}},
+					TopReferrers: []dto.ReferrerClicks{{Referrer: "https://ya.ru/", Clicks: 2}},
+				}
+				sa.EXPECT().CreateUser(gomock.Any()).Return(int32(1), nil)
+				sl.EXPECT().GetOneByIdent(gomock.Any(), "some_ident").Return(link, nil)
+				sc.EXPECT().GetStatsByIdent(gomock.Any(), "some_ident", gomock.Any()).Return(stats, nil)
+			},
+		},
+
+		{
+			name:               "stats - forbidden",
+			requestURL:         "/api/user/urls/some_ident/stats",
+			expectedStatusCode: http.StatusForbidden,
+			mocBehavior: func(sa *mockservice.MockUserStorage, sl *mockservice.MockLinkStorage, sc *mockservice.MockClickStorage) {
+				link := domain.Link{
+					ID:      1,
+					Ident:   "some_ident",
+					FulLink: "some_link",
+					UserID:  1,
+				}
+				sa.EXPECT().CreateUser(gomock.Any()).Return(int32(2), nil)
+				sl.EXPECT().GetOneByIdent(gomock.Any(), "some_ident").Return(link, nil)
+			},
+		},
+
+		{
+			name:               "stats - not found",
+			requestURL:         "/api/user/urls/some_ident/stats",
+			expectedStatusCode: http.StatusNotFound,
+			mocBehavior: func(sa *mockservice.MockUserStorage, sl *mockservice.MockLinkStorage, sc *mockservice.MockClickStorage) {
+				sa.EXPECT().CreateUser(gomock.Any()).Return(int32(1), nil)
+				sl.EXPECT().GetOneByIdent(gomock.Any(), "some_ident").Return(domain.Link{}, domain.ErrNotFound)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mocBehavior(userStorage, linkStorage, clickStorage)
+
+			req, err := http.NewRequest(http.MethodGet, testServ.URL+tt.requestURL, nil)
+			require.NoError(t, err)
+
+			res, err := testServ.Client().Do(req)
+			require.NoError(t, err)
+			defer res.Body.Close()
+
+			assert.Equal(t, tt.expectedStatusCode, res.StatusCode)
+			if tt.expectedStatusCode == http.StatusOK {
+				var stats dto.LinkStatsRes
+				err = json.NewDecoder(res.Body).Decode(&stats)
+				require.NoError(t, err)
+				assert.Equal(t, tt.expectedTotal, stats.TotalClicks)
+			}
+		})
+	}
+}
+
+func Test_Handler_clientIP(t *testing.T) {
+	h := &Handler{}
+	require.NoError(t, h.SetTrustedSubnet("10.0.0.0/8"))
+	tests := []struct {
+		name       string
+		remoteAddr string
+		realIP     string
+		expected   string
+	}{
+		{name: "direct client", remoteAddr: "203.0.113.5:4000", expected: "203.0.113.5"},
+		{name: "forged header", remoteAddr: "203.0.113.5:4000", realIP: "198.51.100.1", expected: "203.0.113.5"},
+		{name: "trusted proxy", remoteAddr: "10.0.0.2:4000", realIP: "198.51.100.1", expected: "198.51.100.1"},
+		{name: "trusted proxy without header", remoteAddr: "10.0.0.2:4000", expected: "10.0.0.2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/some_ident", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.realIP != "" {
+				req.Header.Set("X-Real-IP", tt.realIP)
+				req.Header.Set("X-Forwarded-For", tt.realIP)
+			}
+			assert.Equal(t, tt.expected, h.clientIP(req))
+		})
+	}
+}
+
+func Test_Handler_CloseFlushesClicks(t *testing.T) {
+	ctx := context.Background()
+	linkStorage, _ := hashmapstorage.NewLinkStorage(make(map[string]domain.Link), "")
+	clickStorage, _ := hashmapstorage.NewClickStorage("")
+	servises := NewServices(linkStorage, linkStorage, clickStorage, linkStorage, linkStorage, service.AuthConfig{}, service.LinkConfig{})
+	handler := NewHandler(servises, "http://localhost:8080")
+	handler.Start()
+
+	req := httptest.NewRequest(http.MethodGet, "/some_ident", nil)
+	handler.recordClick(req, "some_ident")
+	handler.Close()
+
+	stats, err := clickStorage.GetStatsByIdent(ctx, "some_ident", 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), stats.TotalClicks)
+}
+
+func Test_dropOldestClicks(t *testing.T) {
+	clicks := make([]domain.Click, maxPendingClicks)
+	for i := range clicks {
+		clicks[i].Ident = strconv.Itoa(i)
+	}
+	clicks = dropOldestClicks(clicks)
+	require.Len(t, clicks, maxPendingClicks-clickBufSize)
+	assert.Equal(t, strconv.Itoa(clickBufSize), clicks[0].Ident)
+
+	assert.Empty(t, dropOldestClicks(make([]domain.Click, 10)))
+}
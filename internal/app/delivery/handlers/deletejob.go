@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/domain"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/logger"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/metrics"
+	"github.com/go-chi/chi"
+)
+
+const (
+	deleteJobInterval = 5 * time.Second
+	deleteJobsPath    = "/api/user/urls/delete-jobs/"
+)
+
+func (h *Handler) GetDeleteJob(res http.ResponseWriter, req *http.Request) {
+	userID, err := getUserID(req.Context())
+	if err != nil {
+		http.Error(res, "failded getting userID", http.StatusBadRequest)
+		return
+	}
+
+	id, err := strconv.ParseInt(chi.URLParam(req, "id"), 10, 64)
+	if err != nil {
+		http.Error(res, "invalid job id", http.StatusBadRequest)
+		return
+	}
+
+	job, err := h.services.GetDeleteJob(req.Context(), id, userID)
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			http.Error(res, err.Error(), http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, domain.ErrForbidden) {
+			http.Error(res, err.Error(), http.StatusForbidden)
+			return
+		}
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	job.StatusURL = h.deleteJobURL(job.ID)
+
+	response, err := json.Marshal(&job)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	res.Header().Set(сontentType, сontentTypeAppJSON)
+	res.WriteHeader(http.StatusOK)
+	res.Write(response)
+}
+
+func (h *Handler) deleteJobURL(id int64) string {
+	return h.baseShortURL + deleteJobsPath + strconv.FormatInt(id, 10)
+}
+
+// processDeleteJobs runs due delete jobs until stopped. Jobs live in storage,
+// so the ones left on stop are run by the next start.
+func (h *Handler) processDeleteJobs(stop <-chan bool) {
+	defer close(h.jobDoneChan)
+	ticker := time.NewTicker(deleteJobInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			jobs, err := h.services.ProcessDeleteJobs(context.Background())
+			for _, job := range jobs {
+				result := "success"
+				switch job.Status {
+				case domain.DeleteJobPending:
+					result = "failure"
+				case domain.DeleteJobDead:
+					result = "dead"
+				}
+				metrics.DeleteBatches.WithLabelValues(result).Inc()
+			}
+			if err != nil {
+				logger.Log().Debug("cannot process delete jobs")
+			}
+			if pending, err := h.services.CountPendingDeleteJobs(context.Background()); err == nil {
+				metrics.PendingDeleteJobs.Set(float64(pending))
+			} else {
+				logger.Log().Debug("cannot count pending delete jobs")
+			}
+		case <-stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/domain"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/dto"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/mock/mockservice"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func Test_Handler_GetDeleteJob(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	userStorage := mockservice.NewMockUserStorage(c)
+	linkStorage := mockservice.NewMockLinkStorage(c)
+	clickStorage := mockservice.NewMockClickStorage(c)
+	jobStorage := mockservice.NewMockDeleteJobStorage(c)
+	keyStorage := mockservice.NewMockAPIKeyStorage(c)
+	servises := NewServices(linkStorage, userStorage, clickStorage, jobStorage, keyStorage, service.AuthConfig{}, service.LinkConfig{})
+	handler := NewHandler(servises, "http://localhost:8080")
+	testServ := httptest.NewServer(handler.InitRouter())
+	defer testServ.Close()
+
+	job := domain.DeleteJob{ID: 7, UserID: 1, Idents: []string{"some_ident"}, Status: domain.DeleteJobDead, Attempts: 10, LastError: "connection refused"}
+
+	type mocBehavior func(sa *mockservice.MockUserStorage, sj *mockservice.MockDeleteJobStorage)
+	tests := []struct {
+		name               string
+		requestURL         string
+		expectedStatusCode int
+		expectedJob        dto.DeleteJobRes
+		mocBehavior        mocBehavior
+	}{
+		{
+			name:               "delete job - simple case",
+			requestURL:         "/api/user/urls/delete-jobs/7",
+			expectedStatusCode: http.StatusOK,
+			expectedJob: dto.DeleteJobRes{
+				ID:        7,
+				Status:    domain.DeleteJobDead,
+				Attempts:  10,
+				LastError: "connection refused",
+				StatusURL: "http://localhost:8080/api/user/urls/delete-jobs/7",
+			},
+			mocBehavior: func(sa *mockservice.MockUserStorage, sj *mockservice.MockDeleteJobStorage) {
+				sa.EXPECT().CreateUser(gomock.Any()).Return(int32(1), nil)
+				sj.EXPECT().GetDeleteJob(gomock.Any(), int64(7)).Return(job, nil)
+			},
+		},
+
+		{
+			name:               "delete job - forbidden",
+			requestURL:         "/api/user/urls/delete-jobs/7",
+			expectedStatusCode: http.StatusForbidden,
+			mocBehavior: func(sa *mockservice.MockUserStorage, sj *mockservice.MockDeleteJobStorage) {
+				sa.EXPECT().CreateUser(gomock.Any()).Return(int32(2), nil)
+				sj.EXPECT().GetDeleteJob(gomock.Any(), int64(7)).Return(job, nil)
+			},
+		},
+
+		{
+			name:               "delete job - not found",
+			requestURL:         "/api/user/urls/delete-jobs/8",
+			expectedStatusCode: http.StatusNotFound,
+			mocBehavior: func(sa *mockservice.MockUserStorage, sj *mockservice.MockDeleteJobStorage) {
+				sa.EXPECT().CreateUser(gomock.Any()).Return(int32(1), nil)
+				sj.EXPECT().GetDeleteJob(gomock.Any(), int64(8)).Return(domain.DeleteJob{}, domain.ErrNotFound)
+			},
+		},
+
+		{
+			name:               "delete job - invalid id",
+			requestURL:         "/api/user/urls/delete-jobs/abc",
+			expectedStatusCode: http.StatusBadRequest,
+			mocBehavior: func(sa *mockservice.MockUserStorage, sj *mockservice.MockDeleteJobStorage) {
+				sa.EXPECT().CreateUser(gomock.Any()).Return(int32(1), nil)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mocBehavior(userStorage, jobStorage)
+
+			req, err := http.NewRequest(http.MethodGet, testServ.URL+tt.requestURL, nil)
+			require.NoError(t, err)
+
+			res, err := testServ.Client().Do(req)
+			require.NoError(t, err)
+			defer res.Body.Close()
+
+			assert.Equal(t, tt.expectedStatusCode, res.StatusCode)
+			if tt.expectedStatusCode == http.StatusOK {
+				var job dto.DeleteJobRes
+				err = json.NewDecoder(res.Body).Decode(&job)
+				require.NoError(t, err)
+				assert.Equal(t, tt.expectedJob, job)
+			}
+		})
+	}
+}
@@ -2,78 +2,200 @@ package handlers
 
 import (
 	"context"
+	"net"
+	"time"
 
 	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/domain"
 	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/dto"
 	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/middlware/gzipmiddleware"
 	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/middlware/logmiddleware"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/middlware/metricsmiddleware"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/ratelimit"
 	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/service"
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
 )
 
-type delMesage struct {
-	idents []string
-}
+const sweepInterval = time.Minute
+
 type Handler struct {
-	services     *Service
-	baseShortURL string
-	delChan      chan delMesage
-	stopChan     chan bool
+	services      *Service
+	baseShortURL  string
+	jobStopChan   chan bool
+	jobDoneChan   chan bool
+	sweepStopChan chan bool
+	sweepDoneChan chan bool
+	clickChan     chan domain.Click
+	clickStopChan chan bool
+	clickDoneChan chan bool
+	trustedSubnet *net.IPNet
+	secureCookie  bool
+	rateLimiters  map[string]*ratelimit.Limiter
 }
 
 func NewHandler(services *Service, baseShortURL string) *Handler {
 	h := &Handler{
-		services:     services,
-		baseShortURL: baseShortURL,
-		delChan:      make(chan delMesage, 1),
-		stopChan:     make(chan bool),
+		services:      services,
+		baseShortURL:  baseShortURL,
+		jobStopChan:   make(chan bool),
+		jobDoneChan:   make(chan bool),
+		sweepStopChan: make(chan bool),
+		sweepDoneChan: make(chan bool),
+		clickChan:     make(chan domain.Click, clickBufSize),
+		clickStopChan: make(chan bool),
+		clickDoneChan: make(chan bool),
 	}
-	go h.flushMessagesDelete(h.stopChan)
 	return h
 
 }
 
+// Start runs the background workers: delete jobs, the sweep of expired and
+// purgeable links and the click flusher. Close stops them.
+func (h *Handler) Start() {
+	go h.processDeleteJobs(h.jobStopChan)
+	go h.sweepExpiredLinks(h.sweepStopChan)
+	go h.flushClicks(h.clickStopChan)
+}
+
+// Close stops the workers run by Start, waits for their running passes and
+// records the buffered clicks.
+func (h *Handler) Close() {
+	close(h.jobStopChan)
+	close(h.sweepStopChan)
+	close(h.clickStopChan)
+	<-h.jobDoneChan
+	<-h.sweepDoneChan
+	<-h.clickDoneChan
+}
+
 func (h *Handler) InitRouter() *chi.Mux {
 	router := chi.NewRouter()
 	router.Use(logmiddleware.WithLogging)
+	router.Use(metricsmiddleware.WithMetrics)
 	router.Use(gzipmiddleware.Decompress)
-	router.Use(h.userIdentity)
-	router.Use(h.setTokenID)
-	router.Use(middleware.Compress(5, "application/json", "text/html"))
-	router.Post("/", h.GetShortLink)
-	router.Post("/api/shorten", h.GetShortLinkByJSON)
-	router.Post("/api/shorten/batch", h.GetShortLinkByListJSON)
-	router.Get("/{ident}", h.GetFulLink)
-	router.Get("/api/user/urls", h.GetLinksByUser)
-	router.Delete("/api/user/urls", h.DeleteLinksByIdents)
+	router.With(h.trustedOnly).Get("/api/internal/stats", h.GetInternalStats)
+	router.With(h.trustedOnly).Put("/api/internal/users/{id}/quota", h.SetUserQuota)
+	router.Group(func(r chi.Router) {
+		r.Use(h.userIdentity)
+		r.Use(h.rateLimit)
+		r.Use(h.sessionOnly)
+		r.Post("/api/user/register", h.Register)
+		r.Post("/api/user/login", h.Login)
+	})
+	router.Group(func(r chi.Router) {
+		r.Use(h.userIdentity)
+		r.Use(h.rateLimit)
+		r.Use(h.setTokenID)
+		r.Use(middleware.Compress(5, "application/json", "text/html"))
+		r.Get("/{ident}", h.GetFulLink)
+
+		shorten := r.With(h.requireScope(domain.ScopeShorten))
+		shorten.Post("/", h.GetShortLink)
+		shorten.Post("/api/shorten", h.GetShortLinkByJSON)
+		shorten.Post("/api/shorten/batch", h.GetShortLinkByListJSON)
+		shorten.Post("/api/user/urls/import", h.ImportLinks)
+		shorten.Patch("/api/user/urls/{ident}", h.UpdateLink)
+
+		read := r.With(h.requireScope(domain.ScopeRead))
+		read.Get("/api/user/quota", h.GetQuota)
+		read.Get("/api/user/urls", h.GetLinksByUser)
+		read.Get("/api/user/urls/export", h.ExportLinks)
+		read.Get("/api/user/urls/{ident}/history", h.GetLinkHistory)
+		read.Get("/api/user/urls/{ident}/stats", h.GetLinkStats)
+		read.Get("/api/user/urls/{ident}/qr", h.GetLinkQR)
+		read.Get("/api/user/urls/delete-jobs/{id}", h.GetDeleteJob)
+
+		del := r.With(h.requireScope(domain.ScopeDelete))
+		del.Delete("/api/user/urls", h.DeleteLinksByIdents)
+		del.Post("/api/user/urls/restore", h.RestoreLinks)
+
+		keys := r.With(h.sessionOnly)
+		keys.Post("/api/user/keys", h.CreateAPIKey)
+		keys.Get("/api/user/keys", h.GetAPIKeys)
+		keys.Delete("/api/user/keys/{id}", h.RevokeAPIKey)
+	})
 	return router
 }
 
 type Service struct {
 	AuthService
 	LinkService
+	ClickService
+	StatsService
+	DeleteJobService
+	APIKeyService
+	QuotaService
 }
 
-func NewServices(linkStorage service.LinkStorage, userStorage service.UserStorage) *Service {
+func NewServices(linkStorage service.LinkStorage, userStorage service.UserStorage, clickStorage service.ClickStorage, jobStorage service.DeleteJobStorage, keyStorage service.APIKeyStorage, authCfg service.AuthConfig, linkCfg service.LinkConfig) *Service {
+	quotaService := service.NewQuotaService(linkStorage, userStorage, linkCfg)
+	linkService := service.NewLinkService(linkStorage, linkCfg)
+	linkService.SetQuotaChecker(quotaService)
 	return &Service{
-		AuthService: service.NewAauthService(userStorage),
-		LinkService: service.NewLinkService(linkStorage),
+		AuthService:      service.NewAauthService(userStorage, authCfg),
+		LinkService:      linkService,
+		ClickService:     service.NewClickService(clickStorage, linkStorage),
+		StatsService:     service.NewStatsService(linkStorage, userStorage),
+		DeleteJobService: service.NewDeleteJobService(jobStorage, linkStorage),
+		APIKeyService:    service.NewAPIKeyService(keyStorage),
+		QuotaService:     quotaService,
 	}
 }
 
 type AuthService interface {
 	ParseToken(accessToken string) (int32, bool, error)
 	BuildJWTString(userID int32) (string, error)
+	NeedsRefresh(accessToken string) bool
 	CreateUser(ctx context.Context) (int32, error)
+	Register(ctx context.Context, creds dto.CredentialsReq) (domain.User, error)
+	Login(ctx context.Context, creds dto.CredentialsReq) (domain.User, error)
+	IsRegistered(ctx context.Context, userID int32) (bool, error)
 }
 
 type LinkService interface {
 	GetFulLink(ctx context.Context, ident string) (domain.Link, error)
-	GetIdent(ctx context.Context, fulLink string, userID int32) (string, error)
+	GetIdent(ctx context.Context, linkReq dto.LinkReq, userID int32) (string, error)
 	GetIdents(ctx context.Context, linkReq []dto.LinkListReq, userID int32) ([]dto.LinkListRes, error)
-	GenerateIdent(url string) string
-	GetLinksByUserID(ctx context.Context, userID int32) ([]dto.LinkListByUserIDRes, error)
+	ValidateAlias(alias string) error
+	GetLinksByUserID(ctx context.Context, userID int32, pageReq dto.LinkPageReq) (dto.LinkPageRes, error)
+	UpdateLink(ctx context.Context, ident string, userID int32, updateReq dto.LinkUpdateReq) (dto.LinkDetailsRes, error)
+	GetLinkHistory(ctx context.Context, ident string, userID int32) (dto.LinkDetailsRes, error)
+	GetUserLink(ctx context.Context, ident string, userID int32) (domain.Link, error)
 	CanDelete(ctx context.Context, userID int32, idents ...string) (bool, error)
-	DeleteLinksByIdent(ctx context.Context, idents ...string) error
+	DeleteExpiredLinks(ctx context.Context) error
+	RestoreLinks(ctx context.Context, userID int32, idents ...string) (dto.LinkRestoreRes, error)
+	PurgeDeletedLinks(ctx context.Context) ([]string, error)
+	ExportLinks(ctx context.Context, userID int32, write func([]dto.LinkExportRow) error) error
+	ImportLinks(ctx context.Context, userID int32, rows []dto.LinkImportRow) (dto.LinkImportRes, error)
+	ClaimLinks(ctx context.Context, fromUserID, toUserID int32) (int, error)
+}
+
+type ClickService interface {
+	RecordClicks(ctx context.Context, clicks ...domain.Click) error
+	GetLinkStats(ctx context.Context, ident string, userID int32) (dto.LinkStatsRes, error)
+}
+
+type StatsService interface {
+	GetInternalStats(ctx context.Context) (dto.InternalStatsRes, error)
+}
+
+type DeleteJobService interface {
+	EnqueueDelete(ctx context.Context, userID int32, idents ...string) (dto.DeleteJobRes, error)
+	GetDeleteJob(ctx context.Context, id int64, userID int32) (dto.DeleteJobRes, error)
+	ProcessDeleteJobs(ctx context.Context) ([]domain.DeleteJob, error)
+	PruneDeleteJobs(ctx context.Context) error
+	CountPendingDeleteJobs(ctx context.Context) (int, error)
+}
+
+type APIKeyService interface {
+	CreateAPIKey(ctx context.Context, userID int32, keyReq dto.APIKeyReq) (dto.APIKeyRes, error)
+	GetAPIKeys(ctx context.Context, userID int32) ([]dto.APIKeyRes, error)
+	RevokeAPIKey(ctx context.Context, userID int32, id int64) error
+	ParseAPIKey(ctx context.Context, secret string) (domain.APIKey, error)
+}
+
+type QuotaService interface {
+	GetQuota(ctx context.Context, userID int32) (dto.QuotaRes, error)
+	CheckBatchSize(n int) error
+	SetLinkQuota(ctx context.Context, userID int32, quota *int) (dto.QuotaRes, error)
 }
@@ -5,14 +5,17 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/domain"
 	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/dto"
 	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/logger"
-	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/storage/postgresstorage"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/metrics"
 	"github.com/go-chi/chi"
 )
 
@@ -21,6 +24,7 @@ const (
 	сontentTypeTextPlain = "text/plain"
 	сontentTypeAppJSON   = "application/json"
 	сontentTypeAppXGZIP  = "application/x-gzip"
+	nextCursorHeader     = "X-Next-Cursor"
 )
 
 func (h *Handler) GetShortLink(res http.ResponseWriter, req *http.Request) {
@@ -43,9 +47,16 @@ func (h *Handler) GetShortLink(res http.ResponseWriter, req *http.Request) {
 	}
 
 	var status int
-	ident, err := h.services.GetIdent(req.Context(), string(body), userID)
+	ident, err := h.services.GetIdent(req.Context(), dto.LinkReq{URL: string(body)}, userID)
 	if err != nil {
-		if !errors.Is(err, postgresstorage.ErrConflict) {
+		if quotaError(res, err) {
+			return
+		}
+		if errors.Is(err, domain.ErrInvalidURL) {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !errors.Is(err, domain.ErrConflict) {
 			http.Error(res, err.Error(), http.StatusInternalServerError)
 			return
 		}
@@ -66,10 +77,12 @@ func (h *Handler) GetFulLink(res http.ResponseWriter, req *http.Request) {
 		http.Error(res, err.Error(), http.StatusBadRequest)
 		return
 	}
-	if link.DeletedFlag {
+	if link.DeletedFlag || link.IsExpired(time.Now()) {
 		http.Error(res, "resurs deleted", http.StatusGone)
 		return
 	}
+	h.recordClick(req, link.Ident)
+	metrics.RedirectHits.Inc()
 	res.Header().Set("Location", link.FulLink)
 	res.WriteHeader(http.StatusTemporaryRedirect)
 }
@@ -95,9 +108,20 @@ func (h *Handler) GetShortLinkByJSON(res http.ResponseWriter, req *http.Request)
 	}
 
 	var status int
-	ident, err := h.services.GetIdent(req.Context(), request.URL, userID)
+	ident, err := h.services.GetIdent(req.Context(), request, userID)
 	if err != nil {
-		if !errors.Is(err, postgresstorage.ErrConflict) {
+		if quotaError(res, err) {
+			return
+		}
+		if errors.Is(err, domain.ErrInvalidAlias) || errors.Is(err, domain.ErrInvalidExpiry) || errors.Is(err, domain.ErrInvalidURL) {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, domain.ErrAliasTaken) {
+			http.Error(res, err.Error(), http.StatusConflict)
+			return
+		}
+		if !errors.Is(err, domain.ErrConflict) {
 			http.Error(res, err.Error(), http.StatusInternalServerError)
 			return
 		}
@@ -141,8 +165,26 @@ func (h *Handler) GetShortLinkByListJSON(res http.ResponseWriter, req *http.Requ
 		return
 	}
 
+	if err := h.services.CheckBatchSize(len(linkReq)); err != nil {
+		if !quotaError(res, err) {
+			http.Error(res, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
 	limkResp, err := h.services.GetIdents(req.Context(), linkReq, userID)
 	if err != nil {
+		if quotaError(res, err) {
+			return
+		}
+		if errors.Is(err, domain.ErrInvalidAlias) || errors.Is(err, domain.ErrInvalidExpiry) || errors.Is(err, domain.ErrInvalidURL) {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, domain.ErrAliasTaken) || errors.Is(err, domain.ErrConflict) {
+			http.Error(res, err.Error(), http.StatusConflict)
+			return
+		}
 		http.Error(res, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -168,13 +210,27 @@ func (h *Handler) GetLinksByUser(res http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	linksResp, err := h.services.GetLinksByUserID(req.Context(), userID)
+	pageReq, err := linkPageReq(req)
 	if err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+	page, err := h.services.GetLinksByUserID(req.Context(), userID, pageReq)
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidQuery) {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
 		http.Error(res, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if page.NextCursor != "" {
+		res.Header().Set(nextCursorHeader, page.NextCursor)
+	}
+	linksResp := page.Links
 	if len(linksResp) == 0 {
 		res.WriteHeader(http.StatusNoContent)
+		return
 	}
 	for i, v := range linksResp {
 		linksResp[i].ShortURL = h.baseShortURL + "/" + v.ShortURL
@@ -189,6 +245,103 @@ func (h *Handler) GetLinksByUser(res http.ResponseWriter, req *http.Request) {
 	res.Write(response)
 }
 
+func (h *Handler) UpdateLink(res http.ResponseWriter, req *http.Request) {
+	userID, err := getUserID(req.Context())
+	if err != nil {
+		http.Error(res, "failded getting userID", http.StatusBadRequest)
+		return
+	}
+
+	ct := req.Header.Get(сontentType)
+	if !(ct == сontentTypeAppJSON || ct == сontentTypeAppXGZIP) {
+		http.Error(res, "invalid Content-Type", http.StatusBadRequest)
+		return
+	}
+
+	var updateReq dto.LinkUpdateReq
+	if err := json.NewDecoder(req.Body).Decode(&updateReq); err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if updateReq.IsEmpty() {
+		http.Error(res, "nothing to update", http.StatusBadRequest)
+		return
+	}
+
+	details, err := h.services.UpdateLink(req.Context(), chi.URLParam(req, "ident"), userID, updateReq)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrInvalidExpiry), errors.Is(err, domain.ErrInvalidURL):
+			http.Error(res, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, domain.ErrNotFound):
+			http.Error(res, err.Error(), http.StatusNotFound)
+		case errors.Is(err, domain.ErrForbidden):
+			http.Error(res, err.Error(), http.StatusForbidden)
+		case errors.Is(err, domain.ErrConflict):
+			http.Error(res, err.Error(), http.StatusConflict)
+		default:
+			http.Error(res, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	h.writeLinkDetails(res, details)
+}
+
+func (h *Handler) GetLinkHistory(res http.ResponseWriter, req *http.Request) {
+	userID, err := getUserID(req.Context())
+	if err != nil {
+		http.Error(res, "failded getting userID", http.StatusBadRequest)
+		return
+	}
+
+	details, err := h.services.GetLinkHistory(req.Context(), chi.URLParam(req, "ident"), userID)
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			http.Error(res, err.Error(), http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, domain.ErrForbidden) {
+			http.Error(res, err.Error(), http.StatusForbidden)
+			return
+		}
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.writeLinkDetails(res, details)
+}
+
+func (h *Handler) writeLinkDetails(res http.ResponseWriter, details dto.LinkDetailsRes) {
+	details.ShortURL = h.baseShortURL + "/" + details.ShortURL
+	response, err := json.Marshal(&details)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	res.Header().Set(сontentType, сontentTypeAppJSON)
+	res.WriteHeader(http.StatusOK)
+	res.Write(response)
+}
+
+// linkPageReq reads the limit, cursor, sort, order and q (search) query
+// parameters of a link listing.
+func linkPageReq(req *http.Request) (dto.LinkPageReq, error) {
+	query := req.URL.Query()
+	pageReq := dto.LinkPageReq{
+		Cursor: query.Get("cursor"),
+		Sort:   query.Get("sort"),
+		Order:  query.Get("order"),
+		Search: query.Get("q"),
+	}
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			return pageReq, fmt.Errorf("%w: limit must be a positive integer", domain.ErrInvalidQuery)
+		}
+		pageReq.Limit = n
+	}
+	return pageReq, nil
+}
+
 func (h *Handler) DeleteLinksByIdents(res http.ResponseWriter, req *http.Request) {
 	userID, err := getUserID(req.Context())
 	if err != nil {
@@ -221,48 +374,93 @@ func (h *Handler) DeleteLinksByIdents(res http.ResponseWriter, req *http.Request
 		return
 	}
 
-	h.delChan <- delMesage{
-		idents: request,
+	job, err := h.services.EnqueueDelete(req.Context(), userID, request...)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
 	}
+	job.StatusURL = h.deleteJobURL(job.ID)
 
+	response, err := json.Marshal(&job)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	res.Header().Set(сontentType, сontentTypeAppJSON)
+	res.Header().Set("Location", job.StatusURL)
 	res.WriteHeader(http.StatusAccepted)
+	res.Write(response)
+}
+
+func (h *Handler) RestoreLinks(res http.ResponseWriter, req *http.Request) {
+	userID, err := getUserID(req.Context())
+	if err != nil {
+		http.Error(res, "failded getting userID", http.StatusBadRequest)
+		return
+	}
+
+	ct := req.Header.Get(сontentType)
+	if !(ct == сontentTypeAppJSON || ct == сontentTypeAppXGZIP) {
+		http.Error(res, "invalid Content-Type", http.StatusBadRequest)
+		return
+	}
+
+	var request []string
+	if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(request) == 0 {
+		http.Error(res, "no idents to restore", http.StatusBadRequest)
+		return
+	}
+
+	restored, err := h.services.RestoreLinks(req.Context(), userID, request...)
+	if err != nil {
+		if quotaError(res, err) {
+			return
+		}
+		if errors.Is(err, domain.ErrForbidden) {
+			http.Error(res, err.Error(), http.StatusForbidden)
+			return
+		}
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response, err := json.Marshal(&restored)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	res.Header().Set(сontentType, сontentTypeAppJSON)
+	res.WriteHeader(http.StatusOK)
+	res.Write(response)
 }
 
-func (h *Handler) flushMessagesDelete(stop <-chan bool) {
-	ticker := time.NewTicker(5 * time.Second)
-	identsBuf := make([]string, 0)
+func (h *Handler) sweepExpiredLinks(stop <-chan bool) {
+	defer close(h.sweepDoneChan)
+	ticker := time.NewTicker(sweepInterval)
+	defer ticker.Stop()
 	for {
 		select {
-		case msg := <-h.delChan:
-			identsBuf = append(identsBuf, msg.idents...)
 		case <-ticker.C:
-			if len(identsBuf) == 0 {
-				continue
+			if err := h.services.DeleteExpiredLinks(context.Background()); err != nil {
+				logger.Log().Debug("cannot delete expired links")
 			}
-			err := h.services.DeleteLinksByIdent(context.Background(), identsBuf...)
-			if err != nil {
-				logger.Log().Debug("cannot delete links")
-				continue
+			h.purgeDeletedLinks()
+			if err := h.services.PruneDeleteJobs(context.Background()); err != nil {
+				logger.Log().Debug("cannot prune delete jobs")
 			}
-			identsBuf = identsBuf[:0]
 		case <-stop:
-			close(h.delChan)
-			for msg := range h.delChan {
-				identsBuf = append(identsBuf, msg.idents...)
-			}
-			if len(identsBuf) == 0 {
-				return
-			}
-			err := h.services.DeleteLinksByIdent(context.Background(), identsBuf...)
-			if err != nil {
-				logger.Log().Debug("cannot delete links when stop")
-				return
-			}
+			return
 		}
 	}
 }
 
-func (h *Handler) FlushMessagesDeleteNow() {
-	h.stopChan <- true
-	close(h.stopChan) 
+// purgeDeletedLinks removes links past the retention period and their clicks.
+func (h *Handler) purgeDeletedLinks() {
+	if _, err := h.services.PurgeDeletedLinks(context.Background()); err != nil {
+		logger.Log().Debug("cannot purge deleted links")
+	}
 }
@@ -11,10 +11,12 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/domain"
 	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/dto"
 	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/mock/mockservice"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/service"
 	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/storage/hashmapstorage"
 	"github.com/go-chi/chi"
 	"github.com/stretchr/testify/assert"
@@ -59,11 +61,33 @@ func Test_Handler_GetShortLink(t *testing.T) {
 			requestBody:        []byte("https://practicum.test4.ru/"),
 			expectedStatusCode: http.StatusBadRequest,
 		},
+		{
+			name:               "same url spelled differently",
+			requestURL:         "/",
+			requestContentType: "text/plain",
+			requestBody:        []byte(" HTTPS://Practicum.Test1.RU:443/#top\n"),
+			expectedStatusCode: http.StatusConflict,
+		},
+		{
+			name:               "empty body",
+			requestURL:         "/",
+			requestContentType: "text/plain",
+			requestBody:        []byte("  "),
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "javascript scheme",
+			requestURL:         "/",
+			requestContentType: "text/plain",
+			requestBody:        []byte("javascript:alert(1)"),
+			expectedStatusCode: http.StatusBadRequest,
+		},
 	}
 
 	linkStorage, _ := hashmapstorage.NewLinkStorage(make(map[string]domain.Link), "")
 	userStorage, _ := hashmapstorage.NewLinkStorage(make(map[string]domain.Link), "")
-	servises := NewServices(linkStorage, userStorage)
+	clickStorage, _ := hashmapstorage.NewClickStorage("")
+	servises := NewServices(linkStorage, userStorage, clickStorage, linkStorage, linkStorage, service.AuthConfig{}, service.LinkConfig{})
 	handler := NewHandler(servises, "http://localhost:8080")
 
 	for _, tt := range tests {
@@ -117,6 +141,14 @@ func Test_Handler_GetFulLink(t *testing.T) {
 			expectedStatusCode: http.StatusGone,
 			expectedLocation:   "",
 		},
+
+		{
+			name:               "is expired",
+			requestURL:         "/",
+			paramURL:           "123459",
+			expectedStatusCode: http.StatusGone,
+			expectedLocation:   "",
+		},
 	}
 	linkMap := make(map[string]domain.Link)
 	link := domain.Link{
@@ -130,11 +162,20 @@ func Test_Handler_GetFulLink(t *testing.T) {
 		FulLink:     "https://practicum.test8.ru/",
 		DeletedFlag: true,
 	}
+	expiredAt := time.Now().Add(-time.Minute)
+	linkExpired := domain.Link{
+		ID:        3,
+		Ident:     "123459",
+		FulLink:   "https://practicum.test9.ru/",
+		ExpiresAt: &expiredAt,
+	}
 	linkMap[link.Ident] = link
 	linkMap[linkDeleted.Ident] = linkDeleted
+	linkMap[linkExpired.Ident] = linkExpired
 	linkStorage, _ := hashmapstorage.NewLinkStorage(linkMap, "")
 	userStorage, _ := hashmapstorage.NewLinkStorage(linkMap, "")
-	servises := NewServices(linkStorage, userStorage)
+	clickStorage, _ := hashmapstorage.NewClickStorage("")
+	servises := NewServices(linkStorage, userStorage, clickStorage, linkStorage, linkStorage, service.AuthConfig{}, service.LinkConfig{})
 	handler := NewHandler(servises, "http://localhost:8080")
 
 	for _, tt := range tests {
@@ -162,11 +203,17 @@ func Test_Handler_GetShortLinkByJson(t *testing.T) {
 	defer c.Finish()
 	userStorage := mockservice.NewMockUserStorage(c)
 	linkStorage := mockservice.NewMockLinkStorage(c)
-	servises := NewServices(linkStorage, userStorage)
+	clickStorage := mockservice.NewMockClickStorage(c)
+	jobStorage := mockservice.NewMockDeleteJobStorage(c)
+	keyStorage := mockservice.NewMockAPIKeyStorage(c)
+	servises := NewServices(linkStorage, userStorage, clickStorage, jobStorage, keyStorage, service.AuthConfig{}, service.LinkConfig{})
 	handler := NewHandler(servises, "http://localhost:8080")
 	testServ := httptest.NewServer(handler.InitRouter())
 	defer testServ.Close()
 
+	// No user has a link quota override.
+	userStorage.EXPECT().GetUser(gomock.Any(), gomock.Any()).Return(domain.User{}, domain.ErrNotFound).AnyTimes()
+
 	type mocBehavior func(sa *mockservice.MockUserStorage, sl *mockservice.MockLinkStorage)
 	tests := []struct {
 		name               string
@@ -191,7 +238,109 @@ func Test_Handler_GetShortLinkByJson(t *testing.T) {
 					FulLink: "some_link",
 				}
 				sa.EXPECT().CreateUser(gomock.Any()).Return(int32(1), nil)
-				sl.EXPECT().Create(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(link, nil)
+				sl.EXPECT().Create(gomock.Any(), gomock.Any()).Return(link, nil)
+			},
+		},
+
+		{
+			name:               "custom alias (json)",
+			requestURL:         "/api/shorten",
+			requestBody:        `{"url": "https://practicum.test.ru/", "alias": "spring-sale"}`,
+			requestContentType: "application/json",
+			expectedStatusCode: http.StatusCreated,
+			expectedErr:        false,
+			mocBehavior: func(sa *mockservice.MockUserStorage, sl *mockservice.MockLinkStorage) {
+				link := domain.Link{
+					ID:      1,
+					Ident:   "spring-sale",
+					FulLink: "https://practicum.test.ru/",
+				}
+				sa.EXPECT().CreateUser(gomock.Any()).Return(int32(1), nil)
+				sl.EXPECT().Create(gomock.Any(), domain.Link{
+					Ident:   "spring-sale",
+					FulLink: "https://practicum.test.ru/",
+					UserID:  1,
+				}).Return(link, nil)
+			},
+		},
+
+		{
+			name:               "alias taken (json)",
+			requestURL:         "/api/shorten",
+			requestBody:        `{"url": "https://practicum.test.ru/", "alias": "spring-sale"}`,
+			requestContentType: "application/json",
+			expectedStatusCode: http.StatusConflict,
+			expectedErr:        true,
+			mocBehavior: func(sa *mockservice.MockUserStorage, sl *mockservice.MockLinkStorage) {
+				sa.EXPECT().CreateUser(gomock.Any()).Return(int32(1), nil)
+				sl.EXPECT().Create(gomock.Any(), gomock.Any()).Return(domain.Link{}, domain.ErrAliasTaken)
+			},
+		},
+
+		{
+			name:               "url already shortened by user (json)",
+			requestURL:         "/api/shorten",
+			requestBody:        `{"url": "https://practicum.test.ru/"}`,
+			requestContentType: "application/json",
+			expectedStatusCode: http.StatusConflict,
+			expectedErr:        false,
+			mocBehavior: func(sa *mockservice.MockUserStorage, sl *mockservice.MockLinkStorage) {
+				link := domain.Link{
+					ID:      1,
+					Ident:   "some_ident",
+					FulLink: "https://practicum.test.ru/",
+					UserID:  1,
+				}
+				sa.EXPECT().CreateUser(gomock.Any()).Return(int32(1), nil)
+				sl.EXPECT().Create(gomock.Any(), gomock.Any()).Return(link, domain.ErrConflict)
+			},
+		},
+
+		{
+			name:               "invalid alias (json)",
+			requestURL:         "/api/shorten",
+			requestBody:        `{"url": "https://practicum.test.ru/", "alias": "spring sale!"}`,
+			requestContentType: "application/json",
+			expectedStatusCode: http.StatusBadRequest,
+			expectedErr:        true,
+			mocBehavior: func(sa *mockservice.MockUserStorage, sl *mockservice.MockLinkStorage) {
+				sa.EXPECT().CreateUser(gomock.Any()).Return(int32(1), nil)
+			},
+		},
+
+		{
+			name:               "reserved alias (json)",
+			requestURL:         "/api/shorten",
+			requestBody:        `{"url": "https://practicum.test.ru/", "alias": "API"}`,
+			requestContentType: "application/json",
+			expectedStatusCode: http.StatusBadRequest,
+			expectedErr:        true,
+			mocBehavior: func(sa *mockservice.MockUserStorage, sl *mockservice.MockLinkStorage) {
+				sa.EXPECT().CreateUser(gomock.Any()).Return(int32(1), nil)
+			},
+		},
+
+		{
+			name:               "ttl and expires_at together (json)",
+			requestURL:         "/api/shorten",
+			requestBody:        `{"url": "https://practicum.test.ru/", "ttl_seconds": 60, "expires_at": "2100-01-01T00:00:00Z"}`,
+			requestContentType: "application/json",
+			expectedStatusCode: http.StatusBadRequest,
+			expectedErr:        true,
+			mocBehavior: func(sa *mockservice.MockUserStorage, sl *mockservice.MockLinkStorage) {
+				sa.EXPECT().CreateUser(gomock.Any()).Return(int32(1), nil)
+			},
+		},
+
+		{
+			name:               "expires_at in the past (json)",
+			requestURL:         "/api/shorten",
+			requestBody:        `{"url": "https://practicum.test.ru/", "expires_at": "2000-01-01T00:00:00Z"}`,
+			requestContentType: "application/json",
+			expectedStatusCode: http.StatusBadRequest,
+			expectedErr:        true,
+			mocBehavior: func(sa *mockservice.MockUserStorage, sl *mockservice.MockLinkStorage) {
+				sa.EXPECT().CreateUser(gomock.Any()).Return(int32(1), nil)
 			},
 		},
 
@@ -281,16 +430,60 @@ func Test_Handler_GetShortLinkByJson(t *testing.T) {
 	}
 }
 
+func Test_Handler_GetShortLinkByJson_GlobalDedupe(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	userStorage := mockservice.NewMockUserStorage(c)
+	linkStorage := mockservice.NewMockLinkStorage(c)
+	clickStorage := mockservice.NewMockClickStorage(c)
+	jobStorage := mockservice.NewMockDeleteJobStorage(c)
+	keyStorage := mockservice.NewMockAPIKeyStorage(c)
+	servises := NewServices(linkStorage, userStorage, clickStorage, jobStorage, keyStorage, service.AuthConfig{}, service.LinkConfig{GlobalDedupe: true})
+	handler := NewHandler(servises, "http://localhost:8080")
+	testServ := httptest.NewServer(handler.InitRouter())
+	defer testServ.Close()
+
+	// No user has a link quota override.
+	userStorage.EXPECT().GetUser(gomock.Any(), gomock.Any()).Return(domain.User{}, domain.ErrNotFound).AnyTimes()
+
+	userStorage.EXPECT().CreateUser(gomock.Any()).Return(int32(2), nil)
+	linkStorage.EXPECT().GetByOriginalURL(gomock.Any(), "https://practicum.test.ru/").Return(domain.Link{
+		ID:      1,
+		Ident:   "some_ident",
+		FulLink: "https://practicum.test.ru/",
+		UserID:  1,
+	}, nil)
+
+	req, err := http.NewRequest(http.MethodPost, testServ.URL+"/api/shorten", bytes.NewBufferString(`{"url": "https://practicum.test.ru/"}`))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := testServ.Client().Do(req)
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	assert.Equal(t, http.StatusConflict, res.StatusCode)
+	var linkRes dto.LinkRes
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&linkRes))
+	assert.Equal(t, "http://localhost:8080/some_ident", linkRes.Result)
+}
+
 func Test_Handler_GetShortLinkByListJSON(t *testing.T) {
 	c := gomock.NewController(t)
 	defer c.Finish()
 	userStorage := mockservice.NewMockUserStorage(c)
 	linkStorage := mockservice.NewMockLinkStorage(c)
-	servises := NewServices(linkStorage, userStorage)
+	clickStorage := mockservice.NewMockClickStorage(c)
+	jobStorage := mockservice.NewMockDeleteJobStorage(c)
+	keyStorage := mockservice.NewMockAPIKeyStorage(c)
+	servises := NewServices(linkStorage, userStorage, clickStorage, jobStorage, keyStorage, service.AuthConfig{}, service.LinkConfig{})
 	handler := NewHandler(servises, "http://localhost:8080")
 	testServ := httptest.NewServer(handler.InitRouter())
 	defer testServ.Close()
 
+	// No user has a link quota override.
+	userStorage.EXPECT().GetUser(gomock.Any(), gomock.Any()).Return(domain.User{}, domain.ErrNotFound).AnyTimes()
+
 	type mocBehavior func(sa *mockservice.MockUserStorage, sl *mockservice.MockLinkStorage)
 	tests := []struct {
 		name               string
@@ -316,6 +509,18 @@ func Test_Handler_GetShortLinkByListJSON(t *testing.T) {
 			},
 		},
 
+		{
+			name:               "duplicate aliases in batch (json)",
+			requestURL:         "/api/shorten/batch",
+			requestBody:        `[{"correlation_id": "string_ident1","original_url":"https://practicum.test1.ru/","alias":"sale"},{"correlation_id":"string_ident2","original_url":"https://practicum.test2.ru/","alias":"sale"}]`,
+			requestContentType: "application/json",
+			expectedStatusCode: http.StatusConflict,
+			expectedErr:        true,
+			mocBehavior: func(sa *mockservice.MockUserStorage, sl *mockservice.MockLinkStorage) {
+				sa.EXPECT().CreateUser(gomock.Any()).Return(int32(1), nil)
+			},
+		},
+
 		{
 			name:               "incorrect Content Type (json)",
 			requestURL:         "/api/shorten/batch",
@@ -409,7 +614,10 @@ func Test_Handler_GetLinksByUser(t *testing.T) {
 	defer c.Finish()
 	userStorage := mockservice.NewMockUserStorage(c)
 	linkStorage := mockservice.NewMockLinkStorage(c)
-	servises := NewServices(linkStorage, userStorage)
+	clickStorage := mockservice.NewMockClickStorage(c)
+	jobStorage := mockservice.NewMockDeleteJobStorage(c)
+	keyStorage := mockservice.NewMockAPIKeyStorage(c)
+	servises := NewServices(linkStorage, userStorage, clickStorage, jobStorage, keyStorage, service.AuthConfig{}, service.LinkConfig{})
 	handler := NewHandler(servises, "http://localhost:8080")
 	testServ := httptest.NewServer(handler.InitRouter())
 	defer testServ.Close()
@@ -421,6 +629,7 @@ func Test_Handler_GetLinksByUser(t *testing.T) {
 		expectedStatusCode int
 		IsNoContent        bool
 		expectedListSize   int
+		expectedNextCursor string
 		mocBehavior        mocBehavior
 	}{
 		{
@@ -430,16 +639,58 @@ func Test_Handler_GetLinksByUser(t *testing.T) {
 			IsNoContent:        false,
 			expectedListSize:   2,
 			mocBehavior: func(sa *mockservice.MockUserStorage, sl *mockservice.MockLinkStorage) {
-				link1 := dto.LinkListByUserIDRes{
-					OriginalURL: "some_oriq_url",
-					ShortURL:    "some_orig_url",
+				link1 := domain.Link{
+					ID:      2,
+					FulLink: "some_oriq_url",
+					Ident:   "some_orig_url",
 				}
-				link2 := dto.LinkListByUserIDRes{
-					OriginalURL: "some_oriq_url_2",
-					ShortURL:    "some_orig_url_3",
+				link2 := domain.Link{
+					ID:      1,
+					FulLink: "some_oriq_url_2",
+					Ident:   "some_orig_url_3",
 				}
 				sa.EXPECT().CreateUser(gomock.Any()).Return(int32(1), nil)
-				sl.EXPECT().GetLinksByUserID(gomock.Any(), gomock.Any()).Return([]dto.LinkListByUserIDRes{link1, link2}, nil)
+				sl.EXPECT().GetLinksByUserID(gomock.Any(), int32(1), dto.LinkPageQuery{
+					SortBy: dto.SortCreated,
+					Desc:   true,
+					Limit:  101,
+				}).Return([]domain.Link{link1, link2}, nil)
+			},
+		},
+		{
+			name:               "geting link by user - next page",
+			requestURL:         "/api/user/urls?limit=1&sort=ident&order=asc&q=example&cursor=aWRlbnQ6YWJj",
+			expectedStatusCode: http.StatusOK,
+			expectedListSize:   1,
+			expectedNextCursor: "aWRlbnQ6YWNl",
+			mocBehavior: func(sa *mockservice.MockUserStorage, sl *mockservice.MockLinkStorage) {
+				link1 := domain.Link{ID: 7, FulLink: "https://example.com/1", Ident: "ace"}
+				link2 := domain.Link{ID: 3, FulLink: "https://example.com/2", Ident: "bad"}
+				sa.EXPECT().CreateUser(gomock.Any()).Return(int32(1), nil)
+				sl.EXPECT().GetLinksByUserID(gomock.Any(), int32(1), dto.LinkPageQuery{
+					SortBy:     dto.SortIdent,
+					Search:     "example",
+					AfterIdent: "abc",
+					Limit:      2,
+				}).Return([]domain.Link{link1, link2}, nil)
+			},
+		},
+		{
+			name:               "geting link by user - cursor of another sort",
+			requestURL:         "/api/user/urls?cursor=aWRlbnQ6YWJj",
+			expectedStatusCode: http.StatusBadRequest,
+			IsNoContent:        true,
+			mocBehavior: func(sa *mockservice.MockUserStorage, sl *mockservice.MockLinkStorage) {
+				sa.EXPECT().CreateUser(gomock.Any()).Return(int32(1), nil)
+			},
+		},
+		{
+			name:               "geting link by user - invalid limit",
+			requestURL:         "/api/user/urls?limit=5000",
+			expectedStatusCode: http.StatusBadRequest,
+			IsNoContent:        true,
+			mocBehavior: func(sa *mockservice.MockUserStorage, sl *mockservice.MockLinkStorage) {
+				sa.EXPECT().CreateUser(gomock.Any()).Return(int32(1), nil)
 			},
 		},
 		{
@@ -450,7 +701,7 @@ func Test_Handler_GetLinksByUser(t *testing.T) {
 			expectedListSize:   2,
 			mocBehavior: func(sa *mockservice.MockUserStorage, sl *mockservice.MockLinkStorage) {
 				sa.EXPECT().CreateUser(gomock.Any()).Return(int32(1), nil)
-				sl.EXPECT().GetLinksByUserID(gomock.Any(), gomock.Any()).Return([]dto.LinkListByUserIDRes{}, nil)
+				sl.EXPECT().GetLinksByUserID(gomock.Any(), gomock.Any(), gomock.Any()).Return([]domain.Link{}, nil)
 			},
 		},
 	}
@@ -467,6 +718,7 @@ func Test_Handler_GetLinksByUser(t *testing.T) {
 			defer res.Body.Close()
 
 			assert.Equal(t, tt.expectedStatusCode, res.StatusCode)
+			assert.Equal(t, tt.expectedNextCursor, res.Header.Get("X-Next-Cursor"))
 			if !tt.IsNoContent {
 				var buf bytes.Buffer
 				var linkRes []dto.LinkListByUserIDRes
@@ -476,6 +728,7 @@ func Test_Handler_GetLinksByUser(t *testing.T) {
 
 				err = json.Unmarshal(buf.Bytes(), &linkRes)
 				require.NoError(t, err)
+				assert.Len(t, linkRes, tt.expectedListSize)
 			}
 		})
 	}
@@ -486,17 +739,21 @@ func Test_Handler_DeleteLinksByIdents(t *testing.T) {
 	defer c.Finish()
 	userStorage := mockservice.NewMockUserStorage(c)
 	linkStorage := mockservice.NewMockLinkStorage(c)
-	servises := NewServices(linkStorage, userStorage)
+	clickStorage := mockservice.NewMockClickStorage(c)
+	jobStorage := mockservice.NewMockDeleteJobStorage(c)
+	keyStorage := mockservice.NewMockAPIKeyStorage(c)
+	servises := NewServices(linkStorage, userStorage, clickStorage, jobStorage, keyStorage, service.AuthConfig{}, service.LinkConfig{})
 	handler := NewHandler(servises, "http://localhost:8080")
 	testServ := httptest.NewServer(handler.InitRouter())
 	defer testServ.Close()
-	type mocBehavior func(sa *mockservice.MockUserStorage, sl *mockservice.MockLinkStorage)
+	type mocBehavior func(sa *mockservice.MockUserStorage, sl *mockservice.MockLinkStorage, sj *mockservice.MockDeleteJobStorage)
 	tests := []struct {
 		name               string
 		requestURL         string
 		requestBody        string
 		requestContentType string
 		expectedStatusCode int
+		expectedLocation   string
 		mocBehavior        mocBehavior
 	}{
 
@@ -506,7 +763,8 @@ func Test_Handler_DeleteLinksByIdents(t *testing.T) {
 			requestBody:        `["some_ident1", "some_ident2", "some_ident3" ]`,
 			requestContentType: "application/json",
 			expectedStatusCode: http.StatusAccepted,
-			mocBehavior: func(sa *mockservice.MockUserStorage, sl *mockservice.MockLinkStorage) {
+			expectedLocation:   "http://localhost:8080/api/user/urls/delete-jobs/7",
+			mocBehavior: func(sa *mockservice.MockUserStorage, sl *mockservice.MockLinkStorage, sj *mockservice.MockDeleteJobStorage) {
 				link1 := domain.Link{
 					ID:      1,
 					Ident:   "some_ident1",
@@ -528,6 +786,10 @@ func Test_Handler_DeleteLinksByIdents(t *testing.T) {
 				links := []domain.Link{link1, link2, link3}
 				sa.EXPECT().CreateUser(gomock.Any()).Return(int32(1), nil)
 				sl.EXPECT().GetByIdents(gomock.Any(), "some_ident1", "some_ident2", "some_ident3").Return(links, nil)
+				sj.EXPECT().CreateDeleteJob(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, job domain.DeleteJob) (domain.DeleteJob, error) {
+					job.ID = 7
+					return job, nil
+				})
 			},
 		},
 
@@ -537,7 +799,7 @@ func Test_Handler_DeleteLinksByIdents(t *testing.T) {
 			requestBody:        `["some_ident1", "some_ident2", "some_ident3" ]`,
 			requestContentType: "application/json",
 			expectedStatusCode: http.StatusForbidden,
-			mocBehavior: func(sa *mockservice.MockUserStorage, sl *mockservice.MockLinkStorage) {
+			mocBehavior: func(sa *mockservice.MockUserStorage, sl *mockservice.MockLinkStorage, sj *mockservice.MockDeleteJobStorage) {
 				link1 := domain.Link{
 					ID:      1,
 					Ident:   "some_ident1",
@@ -565,7 +827,7 @@ func Test_Handler_DeleteLinksByIdents(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			tt.mocBehavior(userStorage, linkStorage)
+			tt.mocBehavior(userStorage, linkStorage, jobStorage)
 
 			req, err := http.NewRequest(http.MethodDelete, testServ.URL+tt.requestURL, bytes.NewBufferString(tt.requestBody))
 			require.NoError(t, err)
@@ -577,6 +839,13 @@ func Test_Handler_DeleteLinksByIdents(t *testing.T) {
 			defer res.Body.Close()
 
 			assert.Equal(t, tt.expectedStatusCode, res.StatusCode)
+			if tt.expectedStatusCode == http.StatusAccepted {
+				assert.Equal(t, tt.expectedLocation, res.Header.Get("Location"))
+				var job dto.DeleteJobRes
+				err = json.NewDecoder(res.Body).Decode(&job)
+				require.NoError(t, err)
+				assert.Equal(t, dto.DeleteJobRes{ID: 7, Status: domain.DeleteJobPending, StatusURL: tt.expectedLocation}, job)
+			}
 		})
 	}
 }
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/domain"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/qrcode"
+	"github.com/go-chi/chi"
+)
+
+const qrCacheControl = "private, max-age=86400"
+
+// GetLinkQR draws a QR code of the short URL. The image depends only on the
+// URL and the query, so its ETag is computed before drawing and a matching
+// If-None-Match is answered without drawing.
+func (h *Handler) GetLinkQR(res http.ResponseWriter, req *http.Request) {
+	userID, err := getUserID(req.Context())
+	if err != nil {
+		http.Error(res, "failded getting userID", http.StatusBadRequest)
+		return
+	}
+
+	opts, err := qrOptions(req)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	link, err := h.services.GetUserLink(req.Context(), chi.URLParam(req, "ident"), userID)
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			http.Error(res, err.Error(), http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, domain.ErrForbidden) {
+			http.Error(res, err.Error(), http.StatusForbidden)
+			return
+		}
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	content := h.baseShortURL + "/" + link.Ident
+	sum := sha256.Sum256([]byte(opts.Key(content)))
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	res.Header().Set("ETag", etag)
+	res.Header().Set("Cache-Control", qrCacheControl)
+	if etagMatch(req.Header.Get("If-None-Match"), etag) {
+		res.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	image, err := qrcode.Encode(content, opts)
+	if err != nil {
+		if errors.Is(err, qrcode.ErrTooSmall) {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	res.Header().Set(сontentType, opts.ContentType())
+	res.WriteHeader(http.StatusOK)
+	res.Write(image)
+}
+
+// qrOptions reads the format, size, level, margin, fg and bg query
+// parameters, falling back to the defaults of package qrcode.
+func qrOptions(req *http.Request) (qrcode.Options, error) {
+	query := req.URL.Query()
+	opts := qrcode.DefaultOptions()
+	if format := query.Get("format"); format != "" {
+		opts.Format = strings.ToLower(format)
+	}
+	if size := query.Get("size"); size != "" {
+		n, err := strconv.Atoi(size)
+		if err != nil {
+			return opts, qrcode.ErrInvalidSize
+		}
+		opts.Size = n
+	}
+	if level := query.Get("level"); level != "" {
+		var err error
+		if opts.Level, err = qrcode.ParseLevel(level); err != nil {
+			return opts, err
+		}
+	}
+	if margin := query.Get("margin"); margin != "" {
+		n, err := strconv.Atoi(margin)
+		if err != nil {
+			return opts, qrcode.ErrInvalidMargin
+		}
+		opts.Margin = n
+	}
+	if fg := query.Get("fg"); fg != "" {
+		var err error
+		if opts.Foreground, err = qrcode.ParseColor(fg); err != nil {
+			return opts, err
+		}
+	}
+	if bg := query.Get("bg"); bg != "" {
+		var err error
+		if opts.Background, err = qrcode.ParseColor(bg); err != nil {
+			return opts, err
+		}
+	}
+	return opts, opts.Validate()
+}
+
+// etagMatch reports whether an If-None-Match header lists etag. Weak
+// validators match too, as the comparison for GET is weak.
+func etagMatch(header, etag string) bool {
+	for _, v := range strings.Split(header, ",") {
+		v = strings.TrimPrefix(strings.TrimSpace(v), "W/")
+		if v == "*" || v == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/domain"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/mock/mockservice"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func Test_Handler_GetLinkQR(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	userStorage := mockservice.NewMockUserStorage(c)
+	linkStorage := mockservice.NewMockLinkStorage(c)
+	clickStorage := mockservice.NewMockClickStorage(c)
+	jobStorage := mockservice.NewMockDeleteJobStorage(c)
+	keyStorage := mockservice.NewMockAPIKeyStorage(c)
+	servises := NewServices(linkStorage, userStorage, clickStorage, jobStorage, keyStorage, service.AuthConfig{}, service.LinkConfig{})
+	handler := NewHandler(servises, "http://localhost:8080")
+	testServ := httptest.NewServer(handler.InitRouter())
+	defer testServ.Close()
+
+	link := domain.Link{
+		ID:      1,
+		Ident:   "some_ident",
+		FulLink: "some_link",
+		UserID:  1,
+	}
+
+	// Get the ETag of the default PNG to revalidate it below.
+	userStorage.EXPECT().CreateUser(gomock.Any()).Return(int32(1), nil)
+	linkStorage.EXPECT().GetOneByIdent(gomock.Any(), "some_ident").Return(link, nil)
+	res, err := testServ.Client().Get(testServ.URL + "/api/user/urls/some_ident/qr")
+	require.NoError(t, err)
+	res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	etag := res.Header.Get("ETag")
+	require.NotEmpty(t, etag)
+
+	type mocBehavior func(sa *mockservice.MockUserStorage, sl *mockservice.MockLinkStorage)
+	tests := []struct {
+		name                string
+		requestURL          string
+		ifNoneMatch         string
+		expectedStatusCode  int
+		expectedContentType string
+		mocBehavior         mocBehavior
+	}{
+		{
+			name:                "qr - svg",
+			requestURL:          "/api/user/urls/some_ident/qr?format=svg&size=512&level=H&margin=2&fg=112233&bg=%23ffffff00",
+			expectedStatusCode:  http.StatusOK,
+			expectedContentType: "image/svg+xml",
+			mocBehavior: func(sa *mockservice.MockUserStorage, sl *mockservice.MockLinkStorage) {
+				sa.EXPECT().CreateUser(gomock.Any()).Return(int32(1), nil)
+				sl.EXPECT().GetOneByIdent(gomock.Any(), "some_ident").Return(link, nil)
+			},
+		},
+
+		{
+			name:               "qr - not modified",
+			requestURL:         "/api/user/urls/some_ident/qr",
+			ifNoneMatch:        `"other", W/` + etag,
+			expectedStatusCode: http.StatusNotModified,
+			mocBehavior: func(sa *mockservice.MockUserStorage, sl *mockservice.MockLinkStorage) {
+				sa.EXPECT().CreateUser(gomock.Any()).Return(int32(1), nil)
+				sl.EXPECT().GetOneByIdent(gomock.Any(), "some_ident").Return(link, nil)
+			},
+		},
+
+		{
+			name:               "qr - invalid color",
+			requestURL:         "/api/user/urls/some_ident/qr?fg=black",
+			expectedStatusCode: http.StatusBadRequest,
+			mocBehavior: func(sa *mockservice.MockUserStorage, sl *mockservice.MockLinkStorage) {
+				sa.EXPECT().CreateUser(gomock.Any()).Return(int32(1), nil)
+			},
+		},
+
+		{
+			name:               "qr - forbidden",
+			requestURL:         "/api/user/urls/some_ident/qr",
+			expectedStatusCode: http.StatusForbidden,
+			mocBehavior: func(sa *mockservice.MockUserStorage, sl *mockservice.MockLinkStorage) {
+				sa.EXPECT().CreateUser(gomock.Any()).Return(int32(2), nil)
+				sl.EXPECT().GetOneByIdent(gomock.Any(), "some_ident").Return(link, nil)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mocBehavior(userStorage, linkStorage)
+
+			req, err := http.NewRequest(http.MethodGet, testServ.URL+tt.requestURL, nil)
+			require.NoError(t, err)
+			if tt.ifNoneMatch != "" {
+				req.Header.Set("If-None-Match", tt.ifNoneMatch)
+			}
+
+			res, err := testServ.Client().Do(req)
+			require.NoError(t, err)
+			defer res.Body.Close()
+
+			assert.Equal(t, tt.expectedStatusCode, res.StatusCode)
+			if tt.expectedStatusCode == http.StatusOK {
+				assert.Equal(t, tt.expectedContentType, res.Header.Get("Content-Type"))
+				assert.NotEqual(t, etag, res.Header.Get("ETag"))
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/domain"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/dto"
+	"github.com/go-chi/chi"
+)
+
+const (
+	quotaErrLinks = "link_quota_exceeded"
+	quotaErrBatch = "batch_too_large"
+)
+
+func (h *Handler) GetQuota(res http.ResponseWriter, req *http.Request) {
+	userID, err := getUserID(req.Context())
+	if err != nil {
+		http.Error(res, "failded getting userID", http.StatusBadRequest)
+		return
+	}
+
+	quota, err := h.services.GetQuota(req.Context(), userID)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeQuota(res, quota)
+}
+
+// SetUserQuota overrides the link quota of any user. It is served to the
+// trusted subnet only.
+func (h *Handler) SetUserQuota(res http.ResponseWriter, req *http.Request) {
+	userID, err := strconv.ParseInt(chi.URLParam(req, "id"), 10, 32)
+	if err != nil || userID <= 0 {
+		http.Error(res, "invalid user id", http.StatusBadRequest)
+		return
+	}
+
+	var request dto.QuotaReq
+	if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+		http.Error(res, "invalid format body", http.StatusBadRequest)
+		return
+	}
+
+	quota, err := h.services.SetLinkQuota(req.Context(), int32(userID), request.LinkQuota)
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidQuery) {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, domain.ErrNotFound) {
+			http.Error(res, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeQuota(res, quota)
+}
+
+func writeQuota(res http.ResponseWriter, quota dto.QuotaRes) {
+	response, err := json.Marshal(&quota)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	res.Header().Set(сontentType, сontentTypeAppJSON)
+	res.WriteHeader(http.StatusOK)
+	res.Write(response)
+}
+
+// quotaError answers 403 with the details of a quota error and reports
+// whether err was one.
+func quotaError(res http.ResponseWriter, err error) bool {
+	var qErr *domain.QuotaError
+	if !errors.As(err, &qErr) {
+		return false
+	}
+	code := quotaErrLinks
+	if errors.Is(err, domain.ErrBatchTooLarge) {
+		code = quotaErrBatch
+	}
+	response, mErr := json.Marshal(&dto.QuotaErrorRes{
+		Error:     code,
+		Message:   qErr.Error(),
+		Limit:     qErr.Limit,
+		Used:      qErr.Used,
+		Requested: qErr.Requested,
+	})
+	if mErr != nil {
+		http.Error(res, mErr.Error(), http.StatusInternalServerError)
+		return true
+	}
+
+	res.Header().Set(сontentType, сontentTypeAppJSON)
+	res.WriteHeader(http.StatusForbidden)
+	res.Write(response)
+	return true
+}
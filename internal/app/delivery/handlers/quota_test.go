@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/domain"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/dto"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/service"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/storage/hashmapstorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Handler_LinkQuota(t *testing.T) {
+	linkStorage, _ := hashmapstorage.NewLinkStorage(make(map[string]domain.Link), "")
+	clickStorage, _ := hashmapstorage.NewClickStorage("")
+	servises := NewServices(linkStorage, linkStorage, clickStorage, linkStorage, linkStorage, service.AuthConfig{}, service.LinkConfig{LinkQuota: 2, MaxBatchSize: 2})
+	handler := NewHandler(servises, "http://localhost:8080")
+	require.NoError(t, handler.SetTrustedSubnet("192.168.1.0/24"))
+	router := handler.InitRouter()
+	testServ := httptest.NewServer(router)
+	defer testServ.Close()
+
+	jar, err := cookiejar.New(nil)
+	require.NoError(t, err)
+	client := testServ.Client()
+	client.Jar = jar
+
+	getQuota := func(t *testing.T) dto.QuotaRes {
+		res, err := client.Get(testServ.URL + "/api/user/quota")
+		require.NoError(t, err)
+		defer res.Body.Close()
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		var quota dto.QuotaRes
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&quota))
+		return quota
+	}
+	post := func(t *testing.T, path, body string) (int, dto.QuotaErrorRes) {
+		res, err := client.Post(testServ.URL+path, "application/json", strings.NewReader(body))
+		require.NoError(t, err)
+		defer res.Body.Close()
+		var quotaErr dto.QuotaErrorRes
+		if res.StatusCode == http.StatusForbidden {
+			require.NoError(t, json.NewDecoder(res.Body).Decode(&quotaErr))
+		}
+		return res.StatusCode, quotaErr
+	}
+
+	quota := getQuota(t)
+	assert.Equal(t, 0, quota.Links)
+	assert.Equal(t, 2, quota.LinkQuota)
+	require.NotNil(t, quota.Remaining)
+	assert.Equal(t, 2, *quota.Remaining)
+	assert.Equal(t, 2, quota.MaxBatchSize)
+
+	status, quotaErr := post(t, "/api/shorten/batch", `[{"correlation_id":"1","original_url":"https://a.com"},{"correlation_id":"2","original_url":"https://b.com"},{"correlation_id":"3","original_url":"https://c.com"}]`)
+	assert.Equal(t, http.StatusForbidden, status)
+	assert.Equal(t, quotaErrBatch, quotaErr.Error)
+	assert.Equal(t, 2, quotaErr.Limit)
+	assert.Equal(t, 3, quotaErr.Requested)
+
+	status, _ = post(t, "/api/shorten/batch", `[{"correlation_id":"1","original_url":"https://a.com"},{"correlation_id":"2","original_url":"https://b.com"}]`)
+	assert.Equal(t, http.StatusCreated, status)
+
+	status, quotaErr = post(t, "/api/shorten", `{"url":"https://c.com"}`)
+	assert.Equal(t, http.StatusForbidden, status)
+	assert.Equal(t, quotaErrLinks, quotaErr.Error)
+	assert.Equal(t, 2, quotaErr.Used)
+
+	// A URL the user already shortened is no new link, so it is not refused
+	// for the quota.
+	status, _ = post(t, "/api/shorten", `{"url":"https://a.com"}`)
+	assert.Equal(t, http.StatusConflict, status)
+	status, _ = post(t, "/api/shorten/batch", `[{"correlation_id":"1","original_url":"https://a.com"}]`)
+	assert.Equal(t, http.StatusConflict, status)
+
+	serverURL, err := url.Parse(testServ.URL)
+	require.NoError(t, err)
+	cookies := jar.Cookies(serverURL)
+	require.Len(t, cookies, 1)
+	userID, _, err := servises.ParseToken(cookies[0].Value)
+	require.NoError(t, err)
+	quotaPath := fmt.Sprintf("/api/internal/users/%d/quota", userID)
+
+	setQuota := func(t *testing.T, remoteAddr, realIP, path, body string) int {
+		req := httptest.NewRequest(http.MethodPut, path, strings.NewReader(body))
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("X-Real-IP", realIP)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	assert.Equal(t, http.StatusForbidden, setQuota(t, "10.0.0.1:4000", "", quotaPath, `{"link_quota":3}`))
+	assert.Equal(t, http.StatusForbidden, setQuota(t, "10.0.0.1:4000", "192.168.1.10", quotaPath, `{"link_quota":3}`))
+	assert.Equal(t, http.StatusBadRequest, setQuota(t, "192.168.1.10:4000", "", quotaPath, `{"link_quota":-1}`))
+	assert.Equal(t, http.StatusNotFound, setQuota(t, "192.168.1.10:4000", "", "/api/internal/users/100/quota", `{"link_quota":3}`))
+	assert.Equal(t, http.StatusOK, setQuota(t, "192.168.1.1:4000", "192.168.1.10", quotaPath, `{"link_quota":3}`))
+
+	status, _ = post(t, "/api/shorten", `{"url":"https://c.com"}`)
+	assert.Equal(t, http.StatusCreated, status)
+	quota = getQuota(t)
+	assert.Equal(t, 3, quota.Links)
+	assert.Equal(t, 3, quota.LinkQuota)
+	assert.True(t, quota.Custom)
+}
+
+func Test_Handler_ClaimQuota(t *testing.T) {
+	linkStorage, _ := hashmapstorage.NewLinkStorage(make(map[string]domain.Link), "")
+	clickStorage, _ := hashmapstorage.NewClickStorage("")
+	servises := NewServices(linkStorage, linkStorage, clickStorage, linkStorage, linkStorage, service.AuthConfig{}, service.LinkConfig{LinkQuota: 2})
+	handler := NewHandler(servises, "http://localhost:8080")
+	testServ := httptest.NewServer(handler.InitRouter())
+	defer testServ.Close()
+
+	newClient := func(t *testing.T) *http.Client {
+		jar, err := cookiejar.New(nil)
+		require.NoError(t, err)
+		client := testServ.Client()
+		client.Jar = jar
+		return client
+	}
+	post := func(t *testing.T, client *http.Client, path, body string) int {
+		res, err := client.Post(testServ.URL+path, "application/json", strings.NewReader(body))
+		require.NoError(t, err)
+		res.Body.Close()
+		return res.StatusCode
+	}
+	creds := `{"login":"staff","password":"correct horse"}`
+
+	owner := newClient(t)
+	require.Equal(t, http.StatusCreated, post(t, owner, "/api/user/register", creds))
+	require.Equal(t, http.StatusCreated, post(t, owner, "/api/shorten", `{"url":"https://a.com"}`))
+
+	anonymous := newClient(t)
+	require.Equal(t, http.StatusCreated, post(t, anonymous, "/api/shorten", `{"url":"https://b.com"}`))
+	require.Equal(t, http.StatusCreated, post(t, anonymous, "/api/shorten", `{"url":"https://c.com"}`))
+	assert.Equal(t, http.StatusForbidden, post(t, anonymous, "/api/user/login", creds))
+
+	res, err := anonymous.Get(testServ.URL + "/api/user/urls")
+	require.NoError(t, err)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	var links []dto.LinkListByUserIDRes
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&links))
+	assert.Len(t, links, 2, "links stay with the anonymous user")
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/metrics"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/ratelimit"
+	"github.com/go-chi/chi"
+)
+
+const (
+	rateLimitLimit     = "RateLimit-Limit"
+	rateLimitRemaining = "RateLimit-Remaining"
+	rateLimitReset     = "RateLimit-Reset"
+	retryAfter         = "Retry-After"
+
+	rateClassWrite    = "write"
+	rateClassRedirect = "redirect"
+)
+
+// rateLimitedRoutes maps the method and route pattern of limited routes to
+// their class.
+var rateLimitedRoutes = map[string]string{
+	http.MethodPost + " /":                     rateClassWrite,
+	http.MethodPost + " /api/shorten":          rateClassWrite,
+	http.MethodPost + " /api/shorten/batch":    rateClassWrite,
+	http.MethodPost + " /api/user/urls/import": rateClassWrite,
+	http.MethodDelete + " /api/user/urls":      rateClassWrite,
+	http.MethodPost + " /api/user/register":    rateClassWrite,
+	http.MethodPost + " /api/user/login":       rateClassWrite,
+	http.MethodGet + " /{ident}":               rateClassRedirect,
+}
+
+// SetRateLimits sets the limits of shorten, delete and sign-in calls and of
+// redirects. A limit with no rate turns its class off.
+func (h *Handler) SetRateLimits(write, redirect ratelimit.Limit) {
+	h.rateLimiters = make(map[string]*ratelimit.Limiter)
+	for class, limit := range map[string]ratelimit.Limit{rateClassWrite: write, rateClassRedirect: redirect} {
+		if limit.Rate > 0 {
+			h.rateLimiters[class] = ratelimit.New(limit)
+		}
+	}
+}
+
+// rateLimit takes a token from the buckets of the client IP and of the user.
+// It runs before setTokenID, so a request without a user on any other route
+// counts as a write, because it creates an anonymous user.
+func (h *Handler) rateLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		userID, err := getUserID(req.Context())
+		if err != nil {
+			http.Error(res, "failded getting userID", http.StatusBadRequest)
+			return
+		}
+
+		class, ok := rateLimitedRoutes[req.Method+" "+chi.RouteContext(req.Context()).RoutePattern()]
+		if !ok && userID <= 0 {
+			class, ok = rateClassWrite, true
+		}
+		limiter := h.rateLimiters[class]
+		if !ok || limiter == nil {
+			next.ServeHTTP(res, req)
+			return
+		}
+
+		result := limiter.Allow("ip:" + h.clientIP(req))
+		if result.Allowed && userID > 0 {
+			result = tighter(result, limiter.Allow("user:"+strconv.Itoa(int(userID))))
+		}
+
+		res.Header().Set(rateLimitLimit, strconv.Itoa(result.Limit))
+		res.Header().Set(rateLimitRemaining, strconv.Itoa(result.Remaining))
+		res.Header().Set(rateLimitReset, seconds(result.Reset))
+		if !result.Allowed {
+			metrics.RateLimited.WithLabelValues(class).Inc()
+			res.Header().Set(retryAfter, seconds(result.RetryAfter))
+			http.Error(res, "too many requests", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(res, req)
+	})
+}
+
+// clientIP is the address the request came from. X-Real-IP is taken only from
+// proxies in the trusted subnet, as anyone else could set it to dodge limits.
+func (h *Handler) clientIP(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+	if h.trustedSubnet == nil {
+		return host
+	}
+	if ip := net.ParseIP(host); ip == nil || !h.trustedSubnet.Contains(ip) {
+		return host
+	}
+	if realIP := net.ParseIP(req.Header.Get("X-Real-IP")); realIP != nil {
+		return realIP.String()
+	}
+	return host
+}
+
+func tighter(a, b ratelimit.Result) ratelimit.Result {
+	if !b.Allowed || b.Remaining < a.Remaining {
+		return b
+	}
+	return a
+}
+
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/domain"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/ratelimit"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/service"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/storage/hashmapstorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Handler_RateLimit(t *testing.T) {
+	newServer := func(t *testing.T) (*httptest.Server, *http.Client) {
+		linkStorage, _ := hashmapstorage.NewLinkStorage(map[string]domain.Link{"some_ident": {Ident: "some_ident", FulLink: "https://ya.ru"}}, "")
+		clickStorage, _ := hashmapstorage.NewClickStorage("")
+		servises := NewServices(linkStorage, linkStorage, clickStorage, linkStorage, linkStorage, service.AuthConfig{}, service.LinkConfig{})
+		handler := NewHandler(servises, "http://localhost:8080")
+		handler.SetRateLimits(ratelimit.Limit{Rate: 0.001, Burst: 2}, ratelimit.Limit{Rate: 0.001, Burst: 3})
+		testServ := httptest.NewServer(handler.InitRouter())
+		t.Cleanup(testServ.Close)
+
+		jar, err := cookiejar.New(nil)
+		require.NoError(t, err)
+		client := testServ.Client()
+		client.Jar = jar
+		client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+		return testServ, client
+	}
+
+	t.Run("shorten - limited per user", func(t *testing.T) {
+		testServ, client := newServer(t)
+		for i, url := range []string{"https://a.com", "https://b.com"} {
+			res, err := client.Post(testServ.URL+"/", "text/plain", strings.NewReader(url))
+			require.NoError(t, err)
+			res.Body.Close()
+			assert.Equal(t, http.StatusCreated, res.StatusCode)
+			assert.Equal(t, "2", res.Header.Get(rateLimitLimit))
+			assert.Equal(t, []string{"1", "0"}[i], res.Header.Get(rateLimitRemaining))
+		}
+
+		res, err := client.Post(testServ.URL+"/api/shorten", "application/json", strings.NewReader(`{"url":"https://c.com"}`))
+		require.NoError(t, err)
+		res.Body.Close()
+		assert.Equal(t, http.StatusTooManyRequests, res.StatusCode)
+		assert.Equal(t, "0", res.Header.Get(rateLimitRemaining))
+		assert.NotEmpty(t, res.Header.Get(retryAfter))
+		assert.NotEmpty(t, res.Header.Get(rateLimitReset))
+
+		// Reading links is not limited.
+		res, err = client.Get(testServ.URL + "/api/user/urls")
+		require.NoError(t, err)
+		res.Body.Close()
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+	})
+
+	t.Run("anonymous requests - limited per ip", func(t *testing.T) {
+		testServ, _ := newServer(t)
+		// No cookie jar, so every request comes without a user.
+		client := &http.Client{}
+		var statuses []int
+		for i := 0; i < 3; i++ {
+			res, err := client.Get(testServ.URL + "/api/user/urls")
+			require.NoError(t, err)
+			res.Body.Close()
+			statuses = append(statuses, res.StatusCode)
+			if res.StatusCode == http.StatusTooManyRequests {
+				assert.Empty(t, res.Cookies(), "no user is created for a refused request")
+			}
+		}
+		assert.Equal(t, []int{http.StatusNoContent, http.StatusNoContent, http.StatusTooManyRequests}, statuses)
+	})
+
+	t.Run("sign in - limited per ip", func(t *testing.T) {
+		testServ, _ := newServer(t)
+		client := &http.Client{}
+		var statuses []int
+		for _, path := range []string{"/api/user/login", "/api/user/register", "/api/user/login"} {
+			res, err := client.Post(testServ.URL+path, "application/json", strings.NewReader(`{"login":"staff","password":"correct horse"}`))
+			require.NoError(t, err)
+			res.Body.Close()
+			statuses = append(statuses, res.StatusCode)
+		}
+		assert.Equal(t, []int{http.StatusUnauthorized, http.StatusCreated, http.StatusTooManyRequests}, statuses)
+	})
+
+	t.Run("redirect - separate limit", func(t *testing.T) {
+		testServ, client := newServer(t)
+		var statuses []int
+		for i := 0; i < 4; i++ {
+			res, err := client.Get(testServ.URL + "/some_ident")
+			require.NoError(t, err)
+			res.Body.Close()
+			statuses = append(statuses, res.StatusCode)
+		}
+		assert.Equal(t, []int{http.StatusTemporaryRedirect, http.StatusTemporaryRedirect, http.StatusTemporaryRedirect, http.StatusTooManyRequests}, statuses)
+	})
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/domain"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/dto"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/mock/mockservice"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func Test_Handler_RestoreLinks(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	userStorage := mockservice.NewMockUserStorage(c)
+	linkStorage := mockservice.NewMockLinkStorage(c)
+	clickStorage := mockservice.NewMockClickStorage(c)
+	jobStorage := mockservice.NewMockDeleteJobStorage(c)
+	keyStorage := mockservice.NewMockAPIKeyStorage(c)
+	servises := NewServices(linkStorage, userStorage, clickStorage, jobStorage, keyStorage, service.AuthConfig{}, service.LinkConfig{})
+	handler := NewHandler(servises, "http://localhost:8080")
+	testServ := httptest.NewServer(handler.InitRouter())
+	defer testServ.Close()
+
+	deletedAt := time.Now().Add(-time.Hour)
+	deleted := domain.Link{ID: 1, Ident: "deleted", FulLink: "some_link", UserID: 1, DeletedFlag: true, DeletedAt: &deletedAt}
+	live := domain.Link{ID: 2, Ident: "live", FulLink: "other_link", UserID: 1}
+
+	type mocBehavior func(sa *mockservice.MockUserStorage, sl *mockservice.MockLinkStorage)
+	tests := []struct {
+		name               string
+		body               string
+		expectedStatusCode int
+		expectedRes        dto.LinkRestoreRes
+		mocBehavior        mocBehavior
+	}{
+		{
+			name:               "restore - simple case",
+			body:               `["deleted","live","unknown"]`,
+			expectedStatusCode: http.StatusOK,
+			expectedRes: dto.LinkRestoreRes{
+				Restored:    []string{"deleted"},
+				NotRestored: []string{"live", "unknown"},
+			},
+			mocBehavior: func(sa *mockservice.MockUserStorage, sl *mockservice.MockLinkStorage) {
+				sa.EXPECT().CreateUser(gomock.Any()).Return(int32(1), nil)
+				sl.EXPECT().GetByIdents(gomock.Any(), "deleted", "live", "unknown").Return([]domain.Link{deleted, live}, nil)
+				sa.EXPECT().GetUser(gomock.Any(), int32(1)).Return(domain.User{ID: 1}, nil)
+				sl.EXPECT().Restore(gomock.Any(), gomock.Any(), "deleted").Return([]string{"deleted"}, nil)
+			},
+		},
+
+		{
+			name:               "restore - over quota",
+			body:               `["deleted"]`,
+			expectedStatusCode: http.StatusForbidden,
+			mocBehavior: func(sa *mockservice.MockUserStorage, sl *mockservice.MockLinkStorage) {
+				quota := 1
+				sa.EXPECT().CreateUser(gomock.Any()).Return(int32(1), nil)
+				sl.EXPECT().GetByIdents(gomock.Any(), "deleted").Return([]domain.Link{deleted}, nil)
+				sa.EXPECT().GetUser(gomock.Any(), int32(1)).Return(domain.User{ID: 1, LinkQuota: &quota}, nil)
+				sl.EXPECT().CountUserLinks(gomock.Any(), int32(1)).Return(1, nil)
+			},
+		},
+
+		{
+			name:               "restore - forbidden",
+			body:               `["deleted"]`,
+			expectedStatusCode: http.StatusForbidden,
+			mocBehavior: func(sa *mockservice.MockUserStorage, sl *mockservice.MockLinkStorage) {
+				sa.EXPECT().CreateUser(gomock.Any()).Return(int32(2), nil)
+				sl.EXPECT().GetByIdents(gomock.Any(), "deleted").Return([]domain.Link{deleted}, nil)
+			},
+		},
+
+		{
+			name:               "restore - empty list",
+			body:               `[]`,
+			expectedStatusCode: http.StatusBadRequest,
+			mocBehavior: func(sa *mockservice.MockUserStorage, sl *mockservice.MockLinkStorage) {
+				sa.EXPECT().CreateUser(gomock.Any()).Return(int32(1), nil)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mocBehavior(userStorage, linkStorage)
+
+			req, err := http.NewRequest(http.MethodPost, testServ.URL+"/api/user/urls/restore", bytes.NewBufferString(tt.body))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+
+			res, err := testServ.Client().Do(req)
+			require.NoError(t, err)
+			defer res.Body.Close()
+
+			assert.Equal(t, tt.expectedStatusCode, res.StatusCode)
+			if tt.expectedStatusCode == http.StatusOK {
+				var restored dto.LinkRestoreRes
+				err = json.NewDecoder(res.Body).Decode(&restored)
+				require.NoError(t, err)
+				assert.Equal(t, tt.expectedRes, restored)
+			}
+		})
+	}
+}
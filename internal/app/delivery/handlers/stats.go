@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+)
+
+func (h *Handler) SetTrustedSubnet(cidr string) error {
+	if cidr == "" {
+		h.trustedSubnet = nil
+		return nil
+	}
+	_, subnet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return err
+	}
+	h.trustedSubnet = subnet
+	return nil
+}
+
+func (h *Handler) GetInternalStats(res http.ResponseWriter, req *http.Request) {
+	stats, err := h.services.GetInternalStats(req.Context())
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	response, err := json.Marshal(&stats)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	res.Header().Set(сontentType, сontentTypeAppJSON)
+	res.WriteHeader(http.StatusOK)
+	res.Write(response)
+}
+
+// trustedOnly serves clients of the trusted subnet only. The client is the
+// peer of the connection, or X-Real-IP when the peer is a trusted proxy.
+func (h *Handler) trustedOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if h.trustedSubnet == nil {
+			http.Error(res, "forbidden", http.StatusForbidden)
+			return
+		}
+		ip := net.ParseIP(h.clientIP(req))
+		if ip == nil || !h.trustedSubnet.Contains(ip) {
+			http.Error(res, "forbidden", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(res, req)
+	})
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/dto"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/mock/mockservice"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func Test_Handler_GetInternalStats(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	userStorage := mockservice.NewMockUserStorage(c)
+	linkStorage := mockservice.NewMockLinkStorage(c)
+	clickStorage := mockservice.NewMockClickStorage(c)
+	jobStorage := mockservice.NewMockDeleteJobStorage(c)
+	keyStorage := mockservice.NewMockAPIKeyStorage(c)
+	servises := NewServices(linkStorage, userStorage, clickStorage, jobStorage, keyStorage, service.AuthConfig{}, service.LinkConfig{})
+	handler := NewHandler(servises, "http://localhost:8080")
+
+	type mocBehavior func(sa *mockservice.MockUserStorage, sl *mockservice.MockLinkStorage)
+	tests := []struct {
+		name               string
+		trustedSubnet      string
+		remoteAddr         string
+		realIP             string
+		expectedStatusCode int
+		expectedStats      dto.InternalStatsRes
+		mocBehavior        mocBehavior
+	}{
+		{
+			name:               "trusted ip",
+			trustedSubnet:      "192.168.1.0/24",
+			remoteAddr:         "192.168.1.10:4000",
+			expectedStatusCode: http.StatusOK,
+			expectedStats:      dto.InternalStatsRes{URLs: 5, Users: 2},
+			mocBehavior: func(sa *mockservice.MockUserStorage, sl *mockservice.MockLinkStorage) {
+				sl.EXPECT().CountLinks(gomock.Any()).Return(5, nil)
+				sa.EXPECT().CountUsers(gomock.Any()).Return(2, nil)
+			},
+		},
+
+		{
+			name:               "trusted proxy",
+			trustedSubnet:      "192.168.1.0/24",
+			remoteAddr:         "192.168.1.1:4000",
+			realIP:             "192.168.1.10",
+			expectedStatusCode: http.StatusOK,
+			expectedStats:      dto.InternalStatsRes{URLs: 5, Users: 2},
+			mocBehavior: func(sa *mockservice.MockUserStorage, sl *mockservice.MockLinkStorage) {
+				sl.EXPECT().CountLinks(gomock.Any()).Return(5, nil)
+				sa.EXPECT().CountUsers(gomock.Any()).Return(2, nil)
+			},
+		},
+
+		{
+			name:               "untrusted ip",
+			trustedSubnet:      "192.168.1.0/24",
+			remoteAddr:         "10.0.0.1:4000",
+			expectedStatusCode: http.StatusForbidden,
+			mocBehavior:        func(sa *mockservice.MockUserStorage, sl *mockservice.MockLinkStorage) {},
+		},
+
+		{
+			name:               "forged header",
+			trustedSubnet:      "192.168.1.0/24",
+			remoteAddr:         "10.0.0.1:4000",
+			realIP:             "192.168.1.10",
+			expectedStatusCode: http.StatusForbidden,
+			mocBehavior:        func(sa *mockservice.MockUserStorage, sl *mockservice.MockLinkStorage) {},
+		},
+
+		{
+			name:               "empty subnet",
+			trustedSubnet:      "",
+			remoteAddr:         "192.168.1.10:4000",
+			expectedStatusCode: http.StatusForbidden,
+			mocBehavior:        func(sa *mockservice.MockUserStorage, sl *mockservice.MockLinkStorage) {},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mocBehavior(userStorage, linkStorage)
+			require.NoError(t, handler.SetTrustedSubnet(tt.trustedSubnet))
+			req := httptest.NewRequest(http.MethodGet, "/api/internal/stats", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.realIP != "" {
+				req.Header.Set("X-Real-IP", tt.realIP)
+			}
+			rec := httptest.NewRecorder()
+			handler.InitRouter().ServeHTTP(rec, req)
+			res := rec.Result()
+			defer res.Body.Close()
+
+			assert.Equal(t, tt.expectedStatusCode, res.StatusCode)
+			if tt.expectedStatusCode == http.StatusOK {
+				var stats dto.InternalStatsRes
+				require.NoError(t, json.NewDecoder(res.Body).Decode(&stats))
+				assert.Equal(t, tt.expectedStats, stats)
+			}
+		})
+	}
+}
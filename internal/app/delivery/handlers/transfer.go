@@ -0,0 +1,331 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/dto"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/logger"
+)
+
+const (
+	сontentTypeTextCSV = "text/csv"
+	сontentTypeNDJSON  = "application/x-ndjson"
+	contentDisposition = "Content-Disposition"
+
+	importBatchSize = 100
+	maxImportErrors = 1000
+	maxImportBody   = 32 << 20
+	maxImportLine   = 64 << 10
+
+	csvColIdent       = "ident"
+	csvColOriginalURL = "original_url"
+	csvColCreatedAt   = "created_at"
+	csvColDeleted     = "is_deleted"
+)
+
+var csvHeader = []string{csvColIdent, csvColOriginalURL, csvColCreatedAt, csvColDeleted}
+
+func (h *Handler) ExportLinks(res http.ResponseWriter, req *http.Request) {
+	userID, err := getUserID(req.Context())
+	if err != nil {
+		http.Error(res, "failded getting userID", http.StatusBadRequest)
+		return
+	}
+
+	format := req.URL.Query().Get("format")
+	if format == "" {
+		format = dto.FormatCSV
+	}
+	if format != dto.FormatCSV && format != dto.FormatNDJSON {
+		http.Error(res, "invalid format, expected csv or ndjson", http.StatusBadRequest)
+		return
+	}
+
+	exp := &linkExporter{res: res, format: format}
+	err = h.services.ExportLinks(req.Context(), userID, exp.write)
+	if err != nil && !exp.started {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err != nil {
+		// The status is sent already, so the client sees a truncated body.
+		logger.Log().Debug("cannot export links: " + err.Error())
+		return
+	}
+	if !exp.started {
+		exp.start()
+		exp.flush()
+	}
+}
+
+func (h *Handler) ImportLinks(res http.ResponseWriter, req *http.Request) {
+	userID, err := getUserID(req.Context())
+	if err != nil {
+		http.Error(res, "failded getting userID", http.StatusBadRequest)
+		return
+	}
+
+	format := req.URL.Query().Get("format")
+	if format == "" {
+		format = importFormat(req.Header.Get(сontentType))
+	}
+	body := http.MaxBytesReader(res, req.Body, maxImportBody)
+	var next func() (dto.LinkImportRow, error)
+	switch format {
+	case dto.FormatCSV:
+		next, err = csvRows(body)
+	case dto.FormatNDJSON:
+		next = ndjsonRows(body)
+	default:
+		http.Error(res, "invalid format, expected csv or ndjson", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var result dto.LinkImportRes
+	batch := make([]dto.LinkImportRow, 0, importBatchSize)
+	importBatch := func() error {
+		batchRes, err := h.services.ImportLinks(req.Context(), userID, batch)
+		result.Add(batchRes, maxImportErrors)
+		batch = batch[:0]
+		return err
+	}
+	for {
+		row, err := next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		var rowErr *importRowError
+		if errors.As(err, &rowErr) {
+			result.Add(dto.LinkImportRes{Failed: 1, Errors: []dto.LinkImportError{{Row: rowErr.row, Error: rowErr.err.Error()}}}, maxImportErrors)
+			continue
+		}
+		var sizeErr *http.MaxBytesError
+		if errors.As(err, &sizeErr) {
+			http.Error(res, fmt.Sprintf("body is larger than %d bytes: %d links imported before it was cut", sizeErr.Limit, result.Imported),
+				http.StatusRequestEntityTooLarge)
+			return
+		}
+		if err != nil {
+			http.Error(res, "invalid body", http.StatusBadRequest)
+			return
+		}
+		batch = append(batch, row)
+		if len(batch) < importBatchSize {
+			continue
+		}
+		if err := importBatch(); err != nil {
+			importFailed(res, err, result.Imported)
+			return
+		}
+	}
+	if len(batch) > 0 {
+		if err := importBatch(); err != nil {
+			importFailed(res, err, result.Imported)
+			return
+		}
+	}
+	response, err := json.Marshal(&result)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	res.Header().Set(сontentType, сontentTypeAppJSON)
+	res.WriteHeader(http.StatusOK)
+	res.Write(response)
+}
+
+func importFailed(res http.ResponseWriter, err error, imported int) {
+	err = fmt.Errorf("%w: %d links imported before the failure", err, imported)
+	if !quotaError(res, err) {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// linkExporter writes export pages as they come, sending the status with the
+// first page so storage errors before it still get a proper response.
+type linkExporter struct {
+	res     http.ResponseWriter
+	format  string
+	csv     *csv.Writer
+	json    *json.Encoder
+	started bool
+}
+
+func (e *linkExporter) start() {
+	e.started = true
+	ct := сontentTypeTextCSV + "; charset=utf-8"
+	if e.format == dto.FormatNDJSON {
+		ct = сontentTypeNDJSON
+	}
+	e.res.Header().Set(сontentType, ct)
+	e.res.Header().Set(contentDisposition, fmt.Sprintf(`attachment; filename="links.%s"`, e.format))
+	e.res.WriteHeader(http.StatusOK)
+	if e.format == dto.FormatNDJSON {
+		e.json = json.NewEncoder(e.res)
+		return
+	}
+	e.csv = csv.NewWriter(e.res)
+	e.csv.Write(csvHeader)
+}
+
+func (e *linkExporter) write(rows []dto.LinkExportRow) error {
+	if !e.started {
+		e.start()
+	}
+	for _, v := range rows {
+		var err error
+		if e.json != nil {
+			err = e.json.Encode(&v)
+		} else {
+			err = e.csv.Write(csvRecord(v))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return e.flush()
+}
+
+func (e *linkExporter) flush() error {
+	if e.csv != nil {
+		e.csv.Flush()
+		if err := e.csv.Error(); err != nil {
+			return err
+		}
+	}
+	if f, ok := e.res.(http.Flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
+
+func csvRecord(row dto.LinkExportRow) []string {
+	createdAt := ""
+	if row.CreatedAt != nil {
+		createdAt = row.CreatedAt.UTC().Format(time.RFC3339)
+	}
+	return []string{row.Ident, row.OriginalURL, createdAt, strconv.FormatBool(row.Deleted)}
+}
+
+// importRowError is a row that cannot be read. Reading goes on with the next
+// row.
+type importRowError struct {
+	row int
+	err error
+}
+
+func (e *importRowError) Error() string {
+	return fmt.Sprintf("row %d: %s", e.row, e.err)
+}
+
+func importFormat(ct string) string {
+	mediaType, _, _ := mime.ParseMediaType(ct)
+	switch mediaType {
+	case сontentTypeTextCSV:
+		return dto.FormatCSV
+	case сontentTypeNDJSON:
+		return dto.FormatNDJSON
+	}
+	return ""
+}
+
+// csvRows reads the header of a CSV import and returns a reader of its rows.
+// Columns are found by name; only original_url is required.
+func csvRows(body io.Reader) (func() (dto.LinkImportRow, error), error) {
+	r := csv.NewReader(body)
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err != nil {
+		return nil, errors.New("invalid csv header")
+	}
+	cols := make(map[string]int, len(header))
+	for i, v := range header {
+		cols[strings.ToLower(strings.TrimSpace(v))] = i
+	}
+	if _, ok := cols[csvColOriginalURL]; !ok {
+		return nil, fmt.Errorf("csv header has no %s column", csvColOriginalURL)
+	}
+	field := func(record []string, name string) string {
+		i, ok := cols[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	return func() (dto.LinkImportRow, error) {
+		record, err := r.Read()
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return dto.LinkImportRow{}, &importRowError{row: parseErr.StartLine, err: parseErr.Err}
+		}
+		if err != nil {
+			return dto.LinkImportRow{}, err
+		}
+		line, _ := r.FieldPos(0)
+		row := dto.LinkImportRow{
+			Row:         line,
+			Ident:       field(record, csvColIdent),
+			OriginalURL: field(record, csvColOriginalURL),
+		}
+		if deleted := field(record, csvColDeleted); deleted != "" {
+			row.Deleted, err = strconv.ParseBool(deleted)
+			if err != nil {
+				return dto.LinkImportRow{}, &importRowError{row: line, err: fmt.Errorf("invalid %s: %q", csvColDeleted, deleted)}
+			}
+		}
+		return row, nil
+	}, nil
+}
+
+// ndjsonRows returns a reader of the rows of an NDJSON import. Each line is
+// decoded on its own, so a malformed line, or one longer than maxImportLine,
+// fails only its row.
+func ndjsonRows(body io.Reader) func() (dto.LinkImportRow, error) {
+	r := bufio.NewReaderSize(body, maxImportLine)
+	line := 0
+	return func() (dto.LinkImportRow, error) {
+		for {
+			b, err := r.ReadSlice('\n')
+			if errors.Is(err, bufio.ErrBufferFull) {
+				line++
+				for errors.Is(err, bufio.ErrBufferFull) {
+					_, err = r.ReadSlice('\n')
+				}
+				if err != nil && !errors.Is(err, io.EOF) {
+					return dto.LinkImportRow{}, err
+				}
+				return dto.LinkImportRow{}, &importRowError{row: line, err: fmt.Errorf("line is longer than %d bytes", maxImportLine)}
+			}
+			if len(b) == 0 && err != nil {
+				return dto.LinkImportRow{}, err
+			}
+			line++
+			b = bytes.TrimSpace(b)
+			if len(b) == 0 {
+				continue
+			}
+			var v dto.LinkExportRow
+			if err := json.Unmarshal(b, &v); err != nil {
+				return dto.LinkImportRow{}, &importRowError{row: line, err: errors.New("invalid json")}
+			}
+			return dto.LinkImportRow{Row: line, Ident: v.Ident, OriginalURL: v.OriginalURL, Deleted: v.Deleted}, nil
+		}
+	}
+}
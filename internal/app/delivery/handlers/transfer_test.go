@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/domain"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/dto"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/service"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/storage/hashmapstorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Handler_ImportExportLinks(t *testing.T) {
+	linkStorage, _ := hashmapstorage.NewLinkStorage(make(map[string]domain.Link), "")
+	clickStorage, _ := hashmapstorage.NewClickStorage("")
+	servises := NewServices(linkStorage, linkStorage, clickStorage, linkStorage, linkStorage, service.AuthConfig{}, service.LinkConfig{})
+	handler := NewHandler(servises, "http://localhost:8080")
+	testServ := httptest.NewServer(handler.InitRouter())
+	defer testServ.Close()
+
+	jar, err := cookiejar.New(nil)
+	require.NoError(t, err)
+	client := testServ.Client()
+	client.Jar = jar
+
+	importAs := func(t *testing.T, client *http.Client, contentType, body string) (int, dto.LinkImportRes) {
+		res, err := client.Post(testServ.URL+"/api/user/urls/import", contentType, strings.NewReader(body))
+		require.NoError(t, err)
+		defer res.Body.Close()
+		var importRes dto.LinkImportRes
+		if res.StatusCode == http.StatusOK {
+			require.NoError(t, json.NewDecoder(res.Body).Decode(&importRes))
+		}
+		return res.StatusCode, importRes
+	}
+	importLinks := func(t *testing.T, contentType, body string) (int, dto.LinkImportRes) {
+		return importAs(t, client, contentType, body)
+	}
+
+	t.Run("import - csv", func(t *testing.T) {
+		body := "original_url,ident,is_deleted\n" +
+			"https://ya.ru,imported,false\n" +
+			"https://go.dev,,\n" +
+			"https://ya.ru,,\n" +
+			"https://example.com,a,\n" +
+			"https://deleted.com,,true\n" +
+			"https://bad.com,,maybe\n" +
+			",,\n"
+		status, res := importLinks(t, "text/csv", body)
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, 2, res.Imported)
+		assert.Equal(t, 1, res.Skipped)
+		assert.Equal(t, 4, res.Failed)
+		var rows []int
+		for _, v := range res.Errors {
+			rows = append(rows, v.Row)
+		}
+		assert.ElementsMatch(t, []int{4, 5, 7, 8}, rows)
+	})
+
+	t.Run("import - ndjson", func(t *testing.T) {
+		body := `{"original_url":"https://pkg.go.dev","ident":"pkg"}` + "\n" +
+			"not json\n" +
+			"\n" +
+			`{"original_url":"https://pkg.go.dev/std"}`
+		status, res := importLinks(t, "application/x-ndjson", body)
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, 2, res.Imported)
+		require.Len(t, res.Errors, 1)
+		assert.Equal(t, 2, res.Errors[0].Row)
+	})
+
+	t.Run("import - long line and many errors", func(t *testing.T) {
+		body := `{"original_url":"https://` + strings.Repeat("a", maxImportLine) + `.com"}` + "\n" +
+			strings.Repeat("not json\n", maxImportErrors+10) +
+			`{"original_url":"https://pkg.go.dev/net"}`
+		status, res := importAs(t, &http.Client{}, "application/x-ndjson", body)
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, 1, res.Imported)
+		assert.Equal(t, maxImportErrors+11, res.Failed)
+		require.Len(t, res.Errors, maxImportErrors)
+		assert.Equal(t, 1, res.Errors[0].Row)
+	})
+
+	t.Run("import - body too large", func(t *testing.T) {
+		body := "original_url\n" + strings.Repeat(strings.Repeat("a", 1000)+"\n", maxImportBody/1000)
+		status, _ := importAs(t, &http.Client{}, "text/csv", body)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, status)
+	})
+
+	t.Run("import - missing original_url column", func(t *testing.T) {
+		status, _ := importLinks(t, "text/csv", "ident\nsome\n")
+		assert.Equal(t, http.StatusBadRequest, status)
+	})
+
+	t.Run("import - unknown format", func(t *testing.T) {
+		status, _ := importLinks(t, "application/xml", "<links/>")
+		assert.Equal(t, http.StatusBadRequest, status)
+	})
+
+	t.Run("export - ndjson", func(t *testing.T) {
+		res, err := client.Get(testServ.URL + "/api/user/urls/export?format=ndjson")
+		require.NoError(t, err)
+		defer res.Body.Close()
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "application/x-ndjson", res.Header.Get("Content-Type"))
+
+		var urls []string
+		scanner := bufio.NewScanner(res.Body)
+		for scanner.Scan() {
+			var row dto.LinkExportRow
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &row))
+			assert.NotNil(t, row.CreatedAt)
+			urls = append(urls, row.OriginalURL)
+		}
+		assert.Equal(t, []string{"https://ya.ru", "https://go.dev", "https://pkg.go.dev", "https://pkg.go.dev/std"}, urls)
+	})
+
+	t.Run("export - csv", func(t *testing.T) {
+		res, err := client.Get(testServ.URL + "/api/user/urls/export?format=csv")
+		require.NoError(t, err)
+		defer res.Body.Close()
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		records, err := csv.NewReader(res.Body).ReadAll()
+		require.NoError(t, err)
+		require.Len(t, records, 5)
+		assert.Equal(t, []string{"ident", "original_url", "created_at", "is_deleted"}, records[0])
+		assert.Equal(t, []string{"imported", "https://ya.ru"}, records[1][:2])
+		assert.Equal(t, "false", records[1][3])
+	})
+
+	t.Run("export - invalid format", func(t *testing.T) {
+		res, err := client.Get(testServ.URL + "/api/user/urls/export?format=xml")
+		require.NoError(t, err)
+		defer res.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	})
+}
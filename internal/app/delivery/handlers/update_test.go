@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/domain"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/dto"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/mock/mockservice"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func Test_Handler_UpdateLink(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	userStorage := mockservice.NewMockUserStorage(c)
+	linkStorage := mockservice.NewMockLinkStorage(c)
+	clickStorage := mockservice.NewMockClickStorage(c)
+	jobStorage := mockservice.NewMockDeleteJobStorage(c)
+	keyStorage := mockservice.NewMockAPIKeyStorage(c)
+	servises := NewServices(linkStorage, userStorage, clickStorage, jobStorage, keyStorage, service.AuthConfig{}, service.LinkConfig{})
+	handler := NewHandler(servises, "http://localhost:8080")
+	testServ := httptest.NewServer(handler.InitRouter())
+	defer testServ.Close()
+
+	link := domain.Link{
+		ID:      1,
+		Ident:   "some_ident",
+		FulLink: "some_link",
+		UserID:  1,
+	}
+	changedAt := time.Date(2023, 10, 1, 0, 0, 0, 0, time.UTC)
+
+	type mocBehavior func(sa *mockservice.MockUserStorage, sl *mockservice.MockLinkStorage)
+	tests := []struct {
+		name               string
+		method             string
+		requestURL         string
+		contentType        string
+		body               string
+		expectedStatusCode int
+		expectedDetails    dto.LinkDetailsRes
+		mocBehavior        mocBehavior
+	}{
+		{
+			name:               "update - simple case",
+			method:             http.MethodPatch,
+			requestURL:         "/api/user/urls/some_ident",
+			contentType:        "application/json",
+			body:               `{"url":"https://new.example/"}`,
+			expectedStatusCode: http.StatusOK,
+			expectedDetails: dto.LinkDetailsRes{
+				ShortURL:    "http://localhost:8080/some_ident",
+				OriginalURL: "https://new.example/",
+				History:     []dto.LinkChangeRes{{OriginalURL: "some_link", ChangedAt: changedAt}},
+			},
+			mocBehavior: func(sa *mockservice.MockUserStorage, sl *mockservice.MockLinkStorage) {
+				updated := link
+				updated.FulLink = "https://new.example/"
+				sa.EXPECT().CreateUser(gomock.Any()).Return(int32(1), nil)
+				sl.EXPECT().GetOneByIdent(gomock.Any(), "some_ident").Return(link, nil)
+				sl.EXPECT().Update(gomock.Any(), updated).Return(updated, nil)
+				sl.EXPECT().GetHistory(gomock.Any(), "some_ident").Return([]domain.LinkChange{{OriginalURL: "some_link", ChangedAt: changedAt}}, nil)
+			},
+		},
+
+		{
+			name:               "update - empty body",
+			method:             http.MethodPatch,
+			requestURL:         "/api/user/urls/some_ident",
+			contentType:        "application/json",
+			body:               `{}`,
+			expectedStatusCode: http.StatusBadRequest,
+			mocBehavior: func(sa *mockservice.MockUserStorage, sl *mockservice.MockLinkStorage) {
+				sa.EXPECT().CreateUser(gomock.Any()).Return(int32(1), nil)
+			},
+		},
+
+		{
+			name:               "update - invalid expiry",
+			method:             http.MethodPatch,
+			requestURL:         "/api/user/urls/some_ident",
+			contentType:        "application/json",
+			body:               `{"ttl_seconds":60,"no_expiry":true}`,
+			expectedStatusCode: http.StatusBadRequest,
+			mocBehavior: func(sa *mockservice.MockUserStorage, sl *mockservice.MockLinkStorage) {
+				sa.EXPECT().CreateUser(gomock.Any()).Return(int32(1), nil)
+				sl.EXPECT().GetOneByIdent(gomock.Any(), "some_ident").Return(link, nil)
+			},
+		},
+
+		{
+			name:               "update - invalid url",
+			method:             http.MethodPatch,
+			requestURL:         "/api/user/urls/some_ident",
+			contentType:        "application/json",
+			body:               `{"url":"javascript:alert(1)"}`,
+			expectedStatusCode: http.StatusBadRequest,
+			mocBehavior: func(sa *mockservice.MockUserStorage, sl *mockservice.MockLinkStorage) {
+				sa.EXPECT().CreateUser(gomock.Any()).Return(int32(1), nil)
+				sl.EXPECT().GetOneByIdent(gomock.Any(), "some_ident").Return(link, nil)
+			},
+		},
+
+		{
+			name:               "update - forbidden",
+			method:             http.MethodPatch,
+			requestURL:         "/api/user/urls/some_ident",
+			contentType:        "application/json",
+			body:               `{"url":"https://new.example/"}`,
+			expectedStatusCode: http.StatusForbidden,
+			mocBehavior: func(sa *mockservice.MockUserStorage, sl *mockservice.MockLinkStorage) {
+				sa.EXPECT().CreateUser(gomock.Any()).Return(int32(2), nil)
+				sl.EXPECT().GetOneByIdent(gomock.Any(), "some_ident").Return(link, nil)
+			},
+		},
+
+		{
+			name:               "update - conflict",
+			method:             http.MethodPatch,
+			requestURL:         "/api/user/urls/some_ident",
+			contentType:        "application/json",
+			body:               `{"url":"https://other.example/"}`,
+			expectedStatusCode: http.StatusConflict,
+			mocBehavior: func(sa *mockservice.MockUserStorage, sl *mockservice.MockLinkStorage) {
+				sa.EXPECT().CreateUser(gomock.Any()).Return(int32(1), nil)
+				sl.EXPECT().GetOneByIdent(gomock.Any(), "some_ident").Return(link, nil)
+				sl.EXPECT().Update(gomock.Any(), gomock.Any()).Return(domain.Link{}, domain.ErrConflict)
+			},
+		},
+
+		{
+			name:               "history - simple case",
+			method:             http.MethodGet,
+			requestURL:         "/api/user/urls/some_ident/history",
+			expectedStatusCode: http.StatusOK,
+			expectedDetails: dto.LinkDetailsRes{
+				ShortURL:    "http://localhost:8080/some_ident",
+				OriginalURL: "some_link",
+				History:     []dto.LinkChangeRes{},
+			},
+			mocBehavior: func(sa *mockservice.MockUserStorage, sl *mockservice.MockLinkStorage) {
+				sa.EXPECT().CreateUser(gomock.Any()).Return(int32(1), nil)
+				sl.EXPECT().GetOneByIdent(gomock.Any(), "some_ident").Return(link, nil)
+				sl.EXPECT().GetHistory(gomock.Any(), "some_ident").Return(nil, nil)
+			},
+		},
+
+		{
+			name:               "history - deleted link",
+			method:             http.MethodGet,
+			requestURL:         "/api/user/urls/some_ident/history",
+			expectedStatusCode: http.StatusNotFound,
+			mocBehavior: func(sa *mockservice.MockUserStorage, sl *mockservice.MockLinkStorage) {
+				deleted := link
+				deleted.DeletedFlag = true
+				sa.EXPECT().CreateUser(gomock.Any()).Return(int32(1), nil)
+				sl.EXPECT().GetOneByIdent(gomock.Any(), "some_ident").Return(deleted, nil)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mocBehavior(userStorage, linkStorage)
+
+			req, err := http.NewRequest(tt.method, testServ.URL+tt.requestURL, bytes.NewBufferString(tt.body))
+			require.NoError(t, err)
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+
+			res, err := testServ.Client().Do(req)
+			require.NoError(t, err)
+			defer res.Body.Close()
+
+			assert.Equal(t, tt.expectedStatusCode, res.StatusCode)
+			if tt.expectedStatusCode == http.StatusOK {
+				var details dto.LinkDetailsRes
+				err = json.NewDecoder(res.Body).Decode(&details)
+				require.NoError(t, err)
+				assert.Equal(t, tt.expectedDetails, details)
+			}
+		})
+	}
+}
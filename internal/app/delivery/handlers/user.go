@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/domain"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/dto"
+)
+
+// Register creates an account for the caller. Links the caller made while
+// anonymous move to the account.
+func (h *Handler) Register(res http.ResponseWriter, req *http.Request) {
+	userID, err := getUserID(req.Context())
+	if err != nil {
+		http.Error(res, "failded getting userID", http.StatusBadRequest)
+		return
+	}
+	creds, ok := readCredentials(res, req)
+	if !ok {
+		return
+	}
+
+	user, err := h.services.Register(req.Context(), creds)
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidQuery) {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, domain.ErrConflict) {
+			http.Error(res, "login already taken", http.StatusConflict)
+			return
+		}
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	authRes := dto.AuthRes{UserID: user.ID, Login: user.Login}
+	authRes.ClaimedLinks, err = h.claimAnonymousLinks(req.Context(), userID, user.ID)
+	if err != nil {
+		if quotaError(res, err) {
+			return
+		}
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.writeAuth(res, http.StatusCreated, authRes)
+}
+
+// Login signs the caller in to an account. Links the caller made while
+// anonymous move to the account.
+func (h *Handler) Login(res http.ResponseWriter, req *http.Request) {
+	userID, err := getUserID(req.Context())
+	if err != nil {
+		http.Error(res, "failded getting userID", http.StatusBadRequest)
+		return
+	}
+	creds, ok := readCredentials(res, req)
+	if !ok {
+		return
+	}
+
+	user, err := h.services.Login(req.Context(), creds)
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidCredentials) {
+			http.Error(res, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	authRes := dto.AuthRes{UserID: user.ID, Login: user.Login}
+	authRes.ClaimedLinks, err = h.claimAnonymousLinks(req.Context(), userID, user.ID)
+	if err != nil {
+		if quotaError(res, err) {
+			return
+		}
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.writeAuth(res, http.StatusOK, authRes)
+}
+
+// claimAnonymousLinks moves the links of the caller to the account it signed
+// in to, unless the caller is an account itself. Links that would not fit in
+// the quota of the account fail the sign-in, which keeps the caller's session.
+func (h *Handler) claimAnonymousLinks(ctx context.Context, fromUserID, toUserID int32) (int, error) {
+	if fromUserID <= 0 || fromUserID == toUserID {
+		return 0, nil
+	}
+	registered, err := h.services.IsRegistered(ctx, fromUserID)
+	if err != nil || registered {
+		return 0, err
+	}
+	return h.services.ClaimLinks(ctx, fromUserID, toUserID)
+}
+
+func readCredentials(res http.ResponseWriter, req *http.Request) (dto.CredentialsReq, bool) {
+	var creds dto.CredentialsReq
+	ct := req.Header.Get(сontentType)
+	if !(ct == сontentTypeAppJSON || ct == сontentTypeAppXGZIP) {
+		http.Error(res, "invalid Content-Type", http.StatusBadRequest)
+		return creds, false
+	}
+	if err := json.NewDecoder(req.Body).Decode(&creds); err != nil {
+		http.Error(res, "invalid format body", http.StatusBadRequest)
+		return creds, false
+	}
+	return creds, true
+}
+
+func (h *Handler) writeAuth(res http.ResponseWriter, status int, authRes dto.AuthRes) {
+	tokenVal, err := h.services.BuildJWTString(authRes.UserID)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	response, err := json.Marshal(&authRes)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.issueToken(res, tokenVal)
+	res.Header().Set(сontentType, сontentTypeAppJSON)
+	res.WriteHeader(status)
+	res.Write(response)
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/domain"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/dto"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/service"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/storage/hashmapstorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Handler_RegisterAndLogin(t *testing.T) {
+	linkStorage, _ := hashmapstorage.NewLinkStorage(make(map[string]domain.Link), "")
+	clickStorage, _ := hashmapstorage.NewClickStorage("")
+	servises := NewServices(linkStorage, linkStorage, clickStorage, linkStorage, linkStorage, service.AuthConfig{}, service.LinkConfig{})
+	handler := NewHandler(servises, "http://localhost:8080")
+	testServ := httptest.NewServer(handler.InitRouter())
+	defer testServ.Close()
+
+	newClient := func(t *testing.T) *http.Client {
+		jar, err := cookiejar.New(nil)
+		require.NoError(t, err)
+		client := *testServ.Client()
+		client.Jar = jar
+		return &client
+	}
+	post := func(t *testing.T, client *http.Client, path, body string) (int, dto.AuthRes) {
+		res, err := client.Post(testServ.URL+path, "application/json", strings.NewReader(body))
+		require.NoError(t, err)
+		defer res.Body.Close()
+		var authRes dto.AuthRes
+		if res.StatusCode < http.StatusBadRequest && strings.HasPrefix(path, "/api/user/") {
+			require.NoError(t, json.NewDecoder(res.Body).Decode(&authRes))
+		}
+		return res.StatusCode, authRes
+	}
+	countLinks := func(t *testing.T, client *http.Client) int {
+		res, err := client.Get(testServ.URL + "/api/user/urls")
+		require.NoError(t, err)
+		defer res.Body.Close()
+		if res.StatusCode == http.StatusNoContent {
+			return 0
+		}
+		var links []dto.LinkListByUserIDRes
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&links))
+		return len(links)
+	}
+	creds := `{"login":"staff","password":"correct horse"}`
+
+	userID := func(t *testing.T, client *http.Client) int32 {
+		serverURL, err := url.Parse(testServ.URL)
+		require.NoError(t, err)
+		cookies := client.Jar.Cookies(serverURL)
+		require.Len(t, cookies, 1)
+		userID, _, err := servises.ParseToken(cookies[0].Value)
+		require.NoError(t, err)
+		return userID
+	}
+
+	first := newClient(t)
+	status, _ := post(t, first, "/api/shorten", `{"url":"https://a.example"}`)
+	require.Equal(t, http.StatusCreated, status)
+	anonymousID := userID(t, first)
+	status, registered := post(t, first, "/api/user/register", creds)
+	require.Equal(t, http.StatusCreated, status)
+	assert.Equal(t, "staff", registered.Login)
+	assert.NotEqual(t, anonymousID, registered.UserID)
+	assert.Equal(t, registered.UserID, userID(t, first))
+	assert.Equal(t, 1, registered.ClaimedLinks)
+	assert.Equal(t, 1, countLinks(t, first))
+	isRegistered, err := servises.IsRegistered(context.Background(), anonymousID)
+	require.NoError(t, err)
+	assert.False(t, isRegistered, "tokens of the anonymous user are not sessions of the account")
+
+	status, _ = post(t, newClient(t), "/api/user/register", creds)
+	assert.Equal(t, http.StatusConflict, status)
+	status, _ = post(t, newClient(t), "/api/user/register", `{"login":"staff2","password":"short"}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	second := newClient(t)
+	status, _ = post(t, second, "/api/shorten", `{"url":"https://b.example"}`)
+	require.Equal(t, http.StatusCreated, status)
+	status, _ = post(t, second, "/api/user/login", `{"login":"staff","password":"wrong password"}`)
+	assert.Equal(t, http.StatusUnauthorized, status)
+	status, loggedIn := post(t, second, "/api/user/login", creds)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, registered.UserID, loggedIn.UserID)
+	assert.Equal(t, 1, loggedIn.ClaimedLinks)
+	assert.Equal(t, 2, countLinks(t, second))
+	assert.Equal(t, 2, countLinks(t, first))
+}
@@ -0,0 +1,41 @@
+package domain
+
+import "time"
+
+// API key scopes. Shorten covers creating and editing links, read covers
+// listing them and their stats, and delete covers deleting and restoring
+// them.
+const (
+	ScopeShorten = "shorten"
+	ScopeRead    = "read"
+	ScopeDelete  = "delete"
+)
+
+var Scopes = []string{ScopeShorten, ScopeRead, ScopeDelete}
+
+// APIKey is a long-lived credential of a user. Only the SHA-256 of the key is
+// kept; Prefix is its start, shown to tell keys apart.
+type APIKey struct {
+	ID        int64      `json:"id" db:"id"`
+	UserID    int32      `json:"user_id" db:"user_id"`
+	Name      string     `json:"name" db:"name"`
+	Prefix    string     `json:"prefix" db:"prefix"`
+	Hash      string     `json:"hash" db:"key_hash"`
+	Scopes    []string   `json:"scopes" db:"-"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty" db:"expires_at"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+}
+
+func (k APIKey) IsActive(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
+
+func (k APIKey) HasScope(scope string) bool {
+	for _, v := range k.Scopes {
+		if v == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,12 @@
+package domain
+
+import "time"
+
+type Click struct {
+	ID        int32     `json:"id" db:"id"`
+	Ident     string    `json:"short_url" db:"short_url"`
+	ClickedAt time.Time `json:"clicked_at" db:"clicked_at"`
+	Referrer  string    `json:"referrer" db:"referrer"`
+	UserAgent string    `json:"user_agent" db:"user_agent"`
+	ClientIP  string    `json:"client_ip" db:"client_ip"`
+}
@@ -0,0 +1,24 @@
+package domain
+
+import "time"
+
+// Delete job states. A pending job is retried until it is done or has used
+// up its attempts and is dead.
+const (
+	DeleteJobPending = "pending"
+	DeleteJobDone    = "done"
+	DeleteJobDead    = "dead"
+)
+
+// DeleteJob is a queued request of a user to delete links.
+type DeleteJob struct {
+	ID            int64     `json:"id" db:"id"`
+	UserID        int32     `json:"user_id" db:"user_id"`
+	Idents        []string  `json:"idents" db:"-"`
+	Status        string    `json:"status" db:"status"`
+	Attempts      int       `json:"attempts" db:"attempts"`
+	LastError     string    `json:"last_error,omitempty" db:"last_error"`
+	NextAttemptAt time.Time `json:"next_attempt_at" db:"next_attempt_at"`
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at" db:"updated_at"`
+}
@@ -0,0 +1,40 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrAliasTaken    = errors.New("alias already taken")
+	ErrConflict      = errors.New("data conflict")
+	ErrInvalidAlias  = errors.New("invalid alias")
+	ErrInvalidExpiry = errors.New("invalid expiry")
+	ErrNotFound      = errors.New("not found")
+	ErrForbidden     = errors.New("forbidden")
+	ErrInvalidQuery  = errors.New("invalid query")
+	ErrInvalidURL    = errors.New("invalid url")
+
+	ErrInvalidCredentials = errors.New("invalid login or password")
+	ErrInvalidAPIKey      = errors.New("invalid api key")
+
+	ErrQuotaExceeded = errors.New("link quota exceeded")
+	ErrBatchTooLarge = errors.New("batch too large")
+)
+
+// QuotaError is returned when a request would take a user past Limit. Err is
+// ErrQuotaExceeded or ErrBatchTooLarge.
+type QuotaError struct {
+	Err       error
+	Limit     int
+	Used      int
+	Requested int
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("%s: %d requested, %d of %d used", e.Err, e.Requested, e.Used, e.Limit)
+}
+
+func (e *QuotaError) Unwrap() error {
+	return e.Err
+}
@@ -1,9 +1,24 @@
 package domain
 
+import "time"
+
 type Link struct {
-	ID          int32  `json:"uuid" db:"id"`
-	Ident       string `json:"short_url" db:"short_url"`
-	FulLink     string `json:"original_url" db:"original_url"`
-	UserID      int32  `json:"user_id" db:"user_id"`
-	DeletedFlag bool   `json:"is_deleted" db:"is_deleted"`
+	ID          int32      `json:"uuid" db:"id"`
+	Ident       string     `json:"short_url" db:"short_url"`
+	FulLink     string     `json:"original_url" db:"original_url"`
+	UserID      int32      `json:"user_id" db:"user_id"`
+	DeletedFlag bool       `json:"is_deleted" db:"is_deleted"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty" db:"expires_at"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
+	CreatedAt   *time.Time `json:"created_at,omitempty" db:"created_at"`
+}
+
+func (l Link) IsExpired(now time.Time) bool {
+	return l.ExpiresAt != nil && !now.Before(*l.ExpiresAt)
+}
+
+// LinkChange is a prior destination of a link, replaced at ChangedAt.
+type LinkChange struct {
+	OriginalURL string    `json:"original_url" db:"original_url"`
+	ChangedAt   time.Time `json:"changed_at" db:"changed_at"`
 }
@@ -1,5 +1,15 @@
 package domain
 
+// User is an anonymous user until Login and PasswordHash are set by
+// registration. LinkQuota overrides the configured cap on active links when
+// set; 0 lifts the cap.
 type User struct {
-	ID int32 `db:"id"`
+	ID           int32  `json:"id" db:"id"`
+	Login        string `json:"login,omitempty" db:"login"`
+	PasswordHash string `json:"password_hash,omitempty" db:"password_hash"`
+	LinkQuota    *int   `json:"link_quota,omitempty" db:"link_quota"`
+}
+
+func (u User) IsRegistered() bool {
+	return u.Login != ""
 }
@@ -0,0 +1,22 @@
+package dto
+
+import "time"
+
+// APIKeyReq creates an API key. Without scopes the key gets all of them.
+type APIKeyReq struct {
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes,omitempty"`
+	TTLSeconds int64      `json:"ttl_seconds,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+}
+
+// APIKeyRes describes an API key. Key is only set when the key is created.
+type APIKeyRes struct {
+	ID        int64      `json:"id"`
+	Name      string     `json:"name"`
+	Prefix    string     `json:"prefix"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	Key       string     `json:"key,omitempty"`
+}
@@ -0,0 +1,17 @@
+package dto
+
+type LinkStatsRes struct {
+	TotalClicks  int64            `json:"total_clicks"`
+	ClicksPerDay []ClicksPerDay   `json:"clicks_per_day"`
+	TopReferrers []ReferrerClicks `json:"top_referrers"`
+}
+
+type ClicksPerDay struct {
+	Day    string `json:"day" db:"day"`
+	Clicks int64  `json:"clicks" db:"clicks"`
+}
+
+type ReferrerClicks struct {
+	Referrer string `json:"referrer" db:"referrer"`
+	Clicks   int64  `json:"clicks" db:"clicks"`
+}
@@ -0,0 +1,14 @@
+package dto
+
+import "time"
+
+// DeleteJobRes is the state of a deletion request. NextAttemptAt is set while
+// the job waits for a retry.
+type DeleteJobRes struct {
+	ID            int64      `json:"id"`
+	Status        string     `json:"status"`
+	Attempts      int        `json:"attempts"`
+	LastError     string     `json:"last_error,omitempty"`
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"`
+	StatusURL     string     `json:"status_url"`
+}
@@ -1,7 +1,12 @@
 package dto
 
+import "time"
+
 type LinkReq struct {
-	URL string `json:"url"`
+	URL        string     `json:"url"`
+	Alias      string     `json:"alias,omitempty"`
+	TTLSeconds int64      `json:"ttl_seconds,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
 }
 
 type LinkRes struct {
@@ -9,8 +14,11 @@ type LinkRes struct {
 }
 
 type LinkListReq struct {
-	CorrelationID string `json:"correlation_id"`
-	OriginalURL   string `json:"original_url"`
+	CorrelationID string     `json:"correlation_id"`
+	OriginalURL   string     `json:"original_url"`
+	Alias         string     `json:"alias,omitempty"`
+	TTLSeconds    int64      `json:"ttl_seconds,omitempty"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"`
 }
 
 type LinkListRes struct {
@@ -22,3 +30,73 @@ type LinkListByUserIDRes struct {
 	OriginalURL string `json:"original_url" db:"original_url"`
 	ShortURL    string `json:"short_url" db:"short_url"`
 }
+
+// Sort orders for a user's links. Links sort by creation through their
+// sequential ID.
+const (
+	SortCreated = "created"
+	SortIdent   = "ident"
+	OrderAsc    = "asc"
+	OrderDesc   = "desc"
+)
+
+// LinkPageReq is a request for a page of a user's links as received from
+// clients. Cursor is the NextCursor of the previous page.
+type LinkPageReq struct {
+	Limit  int
+	Cursor string
+	Sort   string
+	Order  string
+	Search string
+}
+
+type LinkPageRes struct {
+	Links      []LinkListByUserIDRes
+	NextCursor string
+}
+
+// LinkPageQuery selects a page of a user's links in storage. The page starts
+// after AfterID or AfterIdent, depending on SortBy, when they are set.
+// Deleted links are skipped unless IncludeDeleted is set.
+type LinkPageQuery struct {
+	SortBy         string
+	Desc           bool
+	Search         string
+	AfterID        int32
+	AfterIdent     string
+	Limit          int
+	IncludeDeleted bool
+}
+
+// LinkUpdateReq changes a link. Empty fields are left as they are; NoExpiry
+// removes the expiry.
+type LinkUpdateReq struct {
+	URL        string     `json:"url,omitempty"`
+	TTLSeconds int64      `json:"ttl_seconds,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	NoExpiry   bool       `json:"no_expiry,omitempty"`
+}
+
+func (r LinkUpdateReq) IsEmpty() bool {
+	return r.URL == "" && r.TTLSeconds == 0 && r.ExpiresAt == nil && !r.NoExpiry
+}
+
+// LinkDetailsRes is a link with its prior destinations, newest first.
+type LinkDetailsRes struct {
+	ShortURL    string          `json:"short_url"`
+	OriginalURL string          `json:"original_url"`
+	ExpiresAt   *time.Time      `json:"expires_at,omitempty"`
+	History     []LinkChangeRes `json:"history"`
+}
+
+type LinkChangeRes struct {
+	OriginalURL string    `json:"original_url"`
+	ChangedAt   time.Time `json:"changed_at"`
+}
+
+// LinkRestoreRes lists the requested idents that were restored and those
+// that were not.
+type LinkRestoreRes struct {
+	Restored    []string `json:"restored"`
+	NotRestored []string `json:"not_restored"`
+}
@@ -0,0 +1,26 @@
+package dto
+
+// QuotaRes shows the active links of a user against the link quota. A
+// LinkQuota or MaxBatchSize of 0 means no cap, and Remaining is left out.
+type QuotaRes struct {
+	Links        int  `json:"links"`
+	LinkQuota    int  `json:"link_quota"`
+	Remaining    *int `json:"remaining,omitempty"`
+	MaxBatchSize int  `json:"max_batch_size"`
+	Custom       bool `json:"custom"`
+}
+
+// QuotaReq sets the link quota of a user; a null LinkQuota restores the
+// default.
+type QuotaReq struct {
+	LinkQuota *int `json:"link_quota"`
+}
+
+// QuotaErrorRes explains why a request was refused by a quota.
+type QuotaErrorRes struct {
+	Error     string `json:"error"`
+	Message   string `json:"message"`
+	Limit     int    `json:"limit"`
+	Used      int    `json:"used"`
+	Requested int    `json:"requested"`
+}
@@ -0,0 +1,6 @@
+package dto
+
+type InternalStatsRes struct {
+	URLs  int `json:"urls"`
+	Users int `json:"users"`
+}
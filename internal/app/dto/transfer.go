@@ -0,0 +1,52 @@
+package dto
+
+import "time"
+
+// Formats of link exports and imports.
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+)
+
+// LinkExportRow is a link in a user's export. Imports read the same rows.
+type LinkExportRow struct {
+	Ident       string     `json:"ident"`
+	OriginalURL string     `json:"original_url"`
+	CreatedAt   *time.Time `json:"created_at,omitempty"`
+	Deleted     bool       `json:"is_deleted"`
+}
+
+// LinkImportRow is a row read from an import. Row is the line of the body
+// it was read from.
+type LinkImportRow struct {
+	Row         int
+	Ident       string
+	OriginalURL string
+	Deleted     bool
+}
+
+type LinkImportError struct {
+	Row   int    `json:"row"`
+	Error string `json:"error"`
+}
+
+type LinkImportRes struct {
+	Imported int               `json:"imported"`
+	Skipped  int               `json:"skipped"`
+	Failed   int               `json:"failed"`
+	Errors   []LinkImportError `json:"errors,omitempty"`
+}
+
+// Add folds the result of a batch into r. Errors past maxErrors are counted
+// in Failed but not kept.
+func (r *LinkImportRes) Add(batch LinkImportRes, maxErrors int) {
+	r.Imported += batch.Imported
+	r.Skipped += batch.Skipped
+	r.Failed += batch.Failed
+	if room := maxErrors - len(r.Errors); room > 0 {
+		if len(batch.Errors) > room {
+			batch.Errors = batch.Errors[:room]
+		}
+		r.Errors = append(r.Errors, batch.Errors...)
+	}
+}
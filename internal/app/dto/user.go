@@ -0,0 +1,14 @@
+package dto
+
+type CredentialsReq struct {
+	Login    string `json:"login"`
+	Password string `json:"password"`
+}
+
+// AuthRes answers registration and login. ClaimedLinks counts the links of
+// the anonymous user moved to the account.
+type AuthRes struct {
+	UserID       int32  `json:"user_id"`
+	Login        string `json:"login"`
+	ClaimedLinks int    `json:"claimed_links,omitempty"`
+}
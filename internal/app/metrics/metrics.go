@@ -0,0 +1,74 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "shortener"
+
+var (
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Number of HTTP requests by route, method and status.",
+	}, []string{"route", "method", "status"})
+
+	HTTPDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route, method and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	RedirectHits = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "redirect_hits_total",
+		Help:      "Number of successful short link redirects.",
+	})
+
+	PendingDeleteJobs = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "pending_delete_jobs",
+		Help:      "Number of delete jobs waiting to be run or retried.",
+	})
+
+	DeleteBatches = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "delete_batches_total",
+		Help:      "Number of delete job attempts by result: success, failure (retried) or dead.",
+	}, []string{"result"})
+
+	ClicksDropped = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "clicks_dropped_total",
+		Help:      "Number of clicks dropped because the click buffer was full or storage kept failing.",
+	})
+
+	RateLimited = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limited_total",
+		Help:      "Number of requests refused by the rate limiter by class: write or redirect.",
+	}, []string{"class"})
+
+	StorageDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "storage_operation_duration_seconds",
+		Help:      "Storage operation latency by backend and operation.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"backend", "operation"})
+)
+
+func ObserveStorage(backend, operation string, start time.Time) {
+	StorageDuration.WithLabelValues(backend, operation).Observe(time.Since(start).Seconds())
+}
+
+func InitRouter() http.Handler {
+	router := http.NewServeMux()
+	router.Handle("/metrics", promhttp.Handler())
+	return router
+}
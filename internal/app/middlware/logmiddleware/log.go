@@ -28,6 +28,13 @@ func (res *logginResponseWriter) WriteHeader(statusCode int) {
 	res.responseData.status = statusCode
 }
 
+// Flush passes flushes of streamed responses through to the wrapped writer.
+func (res *logginResponseWriter) Flush() {
+	if f, ok := res.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
 func WithLogging(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
 		start := time.Now()
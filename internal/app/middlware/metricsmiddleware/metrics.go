@@ -0,0 +1,46 @@
+package metricsmiddleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/metrics"
+	"github.com/go-chi/chi"
+)
+
+type metricsResponseWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (res *metricsResponseWriter) WriteHeader(statusCode int) {
+	res.ResponseWriter.WriteHeader(statusCode)
+	res.status = statusCode
+}
+
+// Flush passes flushes of streamed responses through to the wrapped writer.
+func (res *metricsResponseWriter) Flush() {
+	if f, ok := res.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func WithMetrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		mRes := &metricsResponseWriter{
+			ResponseWriter: res,
+			status:         http.StatusOK,
+		}
+		next.ServeHTTP(mRes, req)
+
+		route := "unmatched"
+		if rctx := chi.RouteContext(req.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+		status := strconv.Itoa(mRes.status)
+		metrics.HTTPRequests.WithLabelValues(route, req.Method, status).Inc()
+		metrics.HTTPDuration.WithLabelValues(route, req.Method, status).Observe(time.Since(start).Seconds())
+	})
+}
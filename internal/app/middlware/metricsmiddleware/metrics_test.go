@@ -0,0 +1,31 @@
+package metricsmiddleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/metrics"
+	"github.com/go-chi/chi"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_WithMetrics(t *testing.T) {
+	router := chi.NewRouter()
+	router.Use(WithMetrics)
+	router.Get("/{ident}", func(res http.ResponseWriter, req *http.Request) {
+		res.WriteHeader(http.StatusTemporaryRedirect)
+	})
+
+	counter := metrics.HTTPRequests.WithLabelValues("/{ident}", http.MethodGet, "307")
+	before := testutil.ToFloat64(counter)
+
+	for _, ident := range []string{"abc", "def"} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/"+ident, nil))
+		assert.Equal(t, http.StatusTemporaryRedirect, rec.Code)
+	}
+
+	assert.Equal(t, before+2, testutil.ToFloat64(counter))
+}
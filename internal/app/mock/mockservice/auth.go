@@ -4,6 +4,7 @@ import (
 	context "context"
 	reflect "reflect"
 
+	domain "github.com/Aleksey-Andris/go-yandex-shortener/internal/app/domain"
 	gomock "go.uber.org/mock/gomock"
 )
 
@@ -30,6 +31,21 @@ func (m *MockUserStorage) EXPECT() *MockUserStorageMockRecorder {
 	return m.recorder
 }
 
+// CountUsers mocks base method.
+func (m *MockUserStorage) CountUsers(ctx context.Context) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountUsers", ctx)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountUsers indicates an expected call of CountUsers.
+func (mr *MockUserStorageMockRecorder) CountUsers(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountUsers", reflect.TypeOf((*MockUserStorage)(nil).CountUsers), ctx)
+}
+
 // CreateUser mocks base method.
 func (m *MockUserStorage) CreateUser(ctx context.Context) (int32, error) {
 	m.ctrl.T.Helper()
@@ -44,3 +60,61 @@ func (mr *MockUserStorageMockRecorder) CreateUser(ctx interface{}) *gomock.Call
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUser", reflect.TypeOf((*MockUserStorage)(nil).CreateUser), ctx)
 }
+
+// GetUser mocks base method.
+func (m *MockUserStorage) GetUser(ctx context.Context, userID int32) (domain.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUser", ctx, userID)
+	ret0, _ := ret[0].(domain.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUser indicates an expected call of GetUser.
+func (mr *MockUserStorageMockRecorder) GetUser(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUser", reflect.TypeOf((*MockUserStorage)(nil).GetUser), ctx, userID)
+}
+
+// GetUserByLogin mocks base method.
+func (m *MockUserStorage) GetUserByLogin(ctx context.Context, login string) (domain.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserByLogin", ctx, login)
+	ret0, _ := ret[0].(domain.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserByLogin indicates an expected call of GetUserByLogin.
+func (mr *MockUserStorageMockRecorder) GetUserByLogin(ctx, login interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByLogin", reflect.TypeOf((*MockUserStorage)(nil).GetUserByLogin), ctx, login)
+}
+
+// SetCredentials mocks base method.
+func (m *MockUserStorage) SetCredentials(ctx context.Context, user domain.User) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetCredentials", ctx, user)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetCredentials indicates an expected call of SetCredentials.
+func (mr *MockUserStorageMockRecorder) SetCredentials(ctx, user interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetCredentials", reflect.TypeOf((*MockUserStorage)(nil).SetCredentials), ctx, user)
+}
+
+// SetLinkQuota mocks base method.
+func (m *MockUserStorage) SetLinkQuota(ctx context.Context, userID int32, quota *int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetLinkQuota", ctx, userID, quota)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetLinkQuota indicates an expected call of SetLinkQuota.
+func (mr *MockUserStorageMockRecorder) SetLinkQuota(ctx, userID, quota interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetLinkQuota", reflect.TypeOf((*MockUserStorage)(nil).SetLinkQuota), ctx, userID, quota)
+}
@@ -0,0 +1,76 @@
+package mockservice
+
+import (
+	context "context"
+	reflect "reflect"
+
+	domain "github.com/Aleksey-Andris/go-yandex-shortener/internal/app/domain"
+	dto "github.com/Aleksey-Andris/go-yandex-shortener/internal/app/dto"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockClickStorage is a mock of ClickStorage interface.
+type MockClickStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockClickStorageMockRecorder
+}
+
+// MockClickStorageMockRecorder is the mock recorder for MockClickStorage.
+type MockClickStorageMockRecorder struct {
+	mock *MockClickStorage
+}
+
+// NewMockClickStorage creates a new mock instance.
+func NewMockClickStorage(ctrl *gomock.Controller) *MockClickStorage {
+	mock := &MockClickStorage{ctrl: ctrl}
+	mock.recorder = &MockClickStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockClickStorage) EXPECT() *MockClickStorageMockRecorder {
+	return m.recorder
+}
+
+// Close mocks base method.
+func (m *MockClickStorage) Close() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Close")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Close indicates an expected call of Close.
+func (mr *MockClickStorageMockRecorder) Close() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockClickStorage)(nil).Close))
+}
+
+// CreateClicks mocks base method.
+func (m *MockClickStorage) CreateClicks(ctx context.Context, clicks []domain.Click) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateClicks", ctx, clicks)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateClicks indicates an expected call of CreateClicks.
+func (mr *MockClickStorageMockRecorder) CreateClicks(ctx, clicks interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateClicks", reflect.TypeOf((*MockClickStorage)(nil).CreateClicks), ctx, clicks)
+}
+
+// GetStatsByIdent mocks base method.
+func (m *MockClickStorage) GetStatsByIdent(ctx context.Context, ident string, topReferrers int) (dto.LinkStatsRes, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetStatsByIdent", ctx, ident, topReferrers)
+	ret0, _ := ret[0].(dto.LinkStatsRes)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetStatsByIdent indicates an expected call of GetStatsByIdent.
+func (mr *MockClickStorageMockRecorder) GetStatsByIdent(ctx, ident, topReferrers interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStatsByIdent", reflect.TypeOf((*MockClickStorage)(nil).GetStatsByIdent), ctx, ident, topReferrers)
+}
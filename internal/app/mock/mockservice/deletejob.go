@@ -0,0 +1,121 @@
+package mockservice
+
+import (
+	context "context"
+	reflect "reflect"
+	time "time"
+
+	domain "github.com/Aleksey-Andris/go-yandex-shortener/internal/app/domain"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockDeleteJobStorage is a mock of DeleteJobStorage interface.
+type MockDeleteJobStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockDeleteJobStorageMockRecorder
+}
+
+// MockDeleteJobStorageMockRecorder is the mock recorder for MockDeleteJobStorage.
+type MockDeleteJobStorageMockRecorder struct {
+	mock *MockDeleteJobStorage
+}
+
+// NewMockDeleteJobStorage creates a new mock instance.
+func NewMockDeleteJobStorage(ctrl *gomock.Controller) *MockDeleteJobStorage {
+	mock := &MockDeleteJobStorage{ctrl: ctrl}
+	mock.recorder = &MockDeleteJobStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockDeleteJobStorage) EXPECT() *MockDeleteJobStorageMockRecorder {
+	return m.recorder
+}
+
+// ClaimDeleteJobs mocks base method.
+func (m *MockDeleteJobStorage) ClaimDeleteJobs(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]domain.DeleteJob, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClaimDeleteJobs", ctx, now, lease, limit)
+	ret0, _ := ret[0].([]domain.DeleteJob)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ClaimDeleteJobs indicates an expected call of ClaimDeleteJobs.
+func (mr *MockDeleteJobStorageMockRecorder) ClaimDeleteJobs(ctx, now, lease, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClaimDeleteJobs", reflect.TypeOf((*MockDeleteJobStorage)(nil).ClaimDeleteJobs), ctx, now, lease, limit)
+}
+
+// CountPendingJobs mocks base method.
+func (m *MockDeleteJobStorage) CountPendingJobs(ctx context.Context) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountPendingJobs", ctx)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountPendingJobs indicates an expected call of CountPendingJobs.
+func (mr *MockDeleteJobStorageMockRecorder) CountPendingJobs(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountPendingJobs", reflect.TypeOf((*MockDeleteJobStorage)(nil).CountPendingJobs), ctx)
+}
+
+// CreateDeleteJob mocks base method.
+func (m *MockDeleteJobStorage) CreateDeleteJob(ctx context.Context, job domain.DeleteJob) (domain.DeleteJob, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateDeleteJob", ctx, job)
+	ret0, _ := ret[0].(domain.DeleteJob)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateDeleteJob indicates an expected call of CreateDeleteJob.
+func (mr *MockDeleteJobStorageMockRecorder) CreateDeleteJob(ctx, job interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateDeleteJob", reflect.TypeOf((*MockDeleteJobStorage)(nil).CreateDeleteJob), ctx, job)
+}
+
+// DeleteFinishedJobs mocks base method.
+func (m *MockDeleteJobStorage) DeleteFinishedJobs(ctx context.Context, before time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteFinishedJobs", ctx, before)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteFinishedJobs indicates an expected call of DeleteFinishedJobs.
+func (mr *MockDeleteJobStorageMockRecorder) DeleteFinishedJobs(ctx, before interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteFinishedJobs", reflect.TypeOf((*MockDeleteJobStorage)(nil).DeleteFinishedJobs), ctx, before)
+}
+
+// GetDeleteJob mocks base method.
+func (m *MockDeleteJobStorage) GetDeleteJob(ctx context.Context, id int64) (domain.DeleteJob, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDeleteJob", ctx, id)
+	ret0, _ := ret[0].(domain.DeleteJob)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDeleteJob indicates an expected call of GetDeleteJob.
+func (mr *MockDeleteJobStorageMockRecorder) GetDeleteJob(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDeleteJob", reflect.TypeOf((*MockDeleteJobStorage)(nil).GetDeleteJob), ctx, id)
+}
+
+// UpdateDeleteJob mocks base method.
+func (m *MockDeleteJobStorage) UpdateDeleteJob(ctx context.Context, job domain.DeleteJob) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateDeleteJob", ctx, job)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateDeleteJob indicates an expected call of UpdateDeleteJob.
+func (mr *MockDeleteJobStorageMockRecorder) UpdateDeleteJob(ctx, job interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateDeleteJob", reflect.TypeOf((*MockDeleteJobStorage)(nil).UpdateDeleteJob), ctx, job)
+}
@@ -3,6 +3,7 @@ package mockservice
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	domain "github.com/Aleksey-Andris/go-yandex-shortener/internal/app/domain"
 	dto "github.com/Aleksey-Andris/go-yandex-shortener/internal/app/dto"
@@ -32,6 +33,21 @@ func (m *MockLinkStorage) EXPECT() *MockLinkStorageMockRecorder {
 	return m.recorder
 }
 
+// ClaimLinks mocks base method.
+func (m *MockLinkStorage) ClaimLinks(ctx context.Context, fromUserID int32, toUserID int32) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClaimLinks", ctx, fromUserID, toUserID)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ClaimLinks indicates an expected call of ClaimLinks.
+func (mr *MockLinkStorageMockRecorder) ClaimLinks(ctx, fromUserID, toUserID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClaimLinks", reflect.TypeOf((*MockLinkStorage)(nil).ClaimLinks), ctx, fromUserID, toUserID)
+}
+
 // Close mocks base method.
 func (m *MockLinkStorage) Close() error {
 	m.ctrl.T.Helper()
@@ -46,19 +62,49 @@ func (mr *MockLinkStorageMockRecorder) Close() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockLinkStorage)(nil).Close))
 }
 
+// CountLinks mocks base method.
+func (m *MockLinkStorage) CountLinks(ctx context.Context) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountLinks", ctx)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountLinks indicates an expected call of CountLinks.
+func (mr *MockLinkStorageMockRecorder) CountLinks(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountLinks", reflect.TypeOf((*MockLinkStorage)(nil).CountLinks), ctx)
+}
+
+// CountUserLinks mocks base method.
+func (m *MockLinkStorage) CountUserLinks(ctx context.Context, userID int32) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountUserLinks", ctx, userID)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountUserLinks indicates an expected call of CountUserLinks.
+func (mr *MockLinkStorageMockRecorder) CountUserLinks(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountUserLinks", reflect.TypeOf((*MockLinkStorage)(nil).CountUserLinks), ctx, userID)
+}
+
 // Create mocks base method.
-func (m *MockLinkStorage) Create(ctx context.Context, idemt, fulLink string, userID int32) (domain.Link, error) {
+func (m *MockLinkStorage) Create(ctx context.Context, link domain.Link) (domain.Link, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Create", ctx, idemt, fulLink, userID)
+	ret := m.ctrl.Call(m, "Create", ctx, link)
 	ret0, _ := ret[0].(domain.Link)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Create indicates an expected call of Create.
-func (mr *MockLinkStorageMockRecorder) Create(ctx, idemt, fulLink, userID interface{}) *gomock.Call {
+func (mr *MockLinkStorageMockRecorder) Create(ctx, link interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockLinkStorage)(nil).Create), ctx, idemt, fulLink, userID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockLinkStorage)(nil).Create), ctx, link)
 }
 
 // CreateLinks mocks base method.
@@ -76,9 +122,9 @@ func (mr *MockLinkStorageMockRecorder) CreateLinks(ctx, links, userID interface{
 }
 
 // DeleteByIdents mocks base method.
-func (m *MockLinkStorage) DeleteByIdents(ctx context.Context, idents ...string) error {
+func (m *MockLinkStorage) DeleteByIdents(ctx context.Context, userID int32, idents ...string) error {
 	m.ctrl.T.Helper()
-	varargs := []interface{}{ctx}
+	varargs := []interface{}{ctx, userID}
 	for _, a := range idents {
 		varargs = append(varargs, a)
 	}
@@ -88,12 +134,26 @@ func (m *MockLinkStorage) DeleteByIdents(ctx context.Context, idents ...string)
 }
 
 // DeleteByIdents indicates an expected call of DeleteByIdents.
-func (mr *MockLinkStorageMockRecorder) DeleteByIdents(ctx interface{}, idents ...interface{}) *gomock.Call {
+func (mr *MockLinkStorageMockRecorder) DeleteByIdents(ctx, userID interface{}, idents ...interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	varargs := append([]interface{}{ctx}, idents...)
+	varargs := append([]interface{}{ctx, userID}, idents...)
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteByIdents", reflect.TypeOf((*MockLinkStorage)(nil).DeleteByIdents), varargs...)
 }
 
+// DeleteExpired mocks base method.
+func (m *MockLinkStorage) DeleteExpired(ctx context.Context, now time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteExpired", ctx, now)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteExpired indicates an expected call of DeleteExpired.
+func (mr *MockLinkStorageMockRecorder) DeleteExpired(ctx, now interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpired", reflect.TypeOf((*MockLinkStorage)(nil).DeleteExpired), ctx, now)
+}
+
 // GetByIdents mocks base method.
 func (m *MockLinkStorage) GetByIdents(ctx context.Context, idents ...string) ([]domain.Link, error) {
 	m.ctrl.T.Helper()
@@ -114,19 +174,49 @@ func (mr *MockLinkStorageMockRecorder) GetByIdents(ctx interface{}, idents ...in
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByIdents", reflect.TypeOf((*MockLinkStorage)(nil).GetByIdents), varargs...)
 }
 
+// GetByOriginalURL mocks base method.
+func (m *MockLinkStorage) GetByOriginalURL(ctx context.Context, url string) (domain.Link, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetByOriginalURL", ctx, url)
+	ret0, _ := ret[0].(domain.Link)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetByOriginalURL indicates an expected call of GetByOriginalURL.
+func (mr *MockLinkStorageMockRecorder) GetByOriginalURL(ctx, url interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByOriginalURL", reflect.TypeOf((*MockLinkStorage)(nil).GetByOriginalURL), ctx, url)
+}
+
+// GetHistory mocks base method.
+func (m *MockLinkStorage) GetHistory(ctx context.Context, ident string) ([]domain.LinkChange, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetHistory", ctx, ident)
+	ret0, _ := ret[0].([]domain.LinkChange)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetHistory indicates an expected call of GetHistory.
+func (mr *MockLinkStorageMockRecorder) GetHistory(ctx, ident interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetHistory", reflect.TypeOf((*MockLinkStorage)(nil).GetHistory), ctx, ident)
+}
+
 // GetLinksByUserID mocks base method.
-func (m *MockLinkStorage) GetLinksByUserID(ctx context.Context, userID int32) ([]dto.LinkListByUserIDRes, error) {
+func (m *MockLinkStorage) GetLinksByUserID(ctx context.Context, userID int32, query dto.LinkPageQuery) ([]domain.Link, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetLinksByUserID", ctx, userID)
-	ret0, _ := ret[0].([]dto.LinkListByUserIDRes)
+	ret := m.ctrl.Call(m, "GetLinksByUserID", ctx, userID, query)
+	ret0, _ := ret[0].([]domain.Link)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetLinksByUserID indicates an expected call of GetLinksByUserID.
-func (mr *MockLinkStorageMockRecorder) GetLinksByUserID(ctx, userID interface{}) *gomock.Call {
+func (mr *MockLinkStorageMockRecorder) GetLinksByUserID(ctx, userID, query interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLinksByUserID", reflect.TypeOf((*MockLinkStorage)(nil).GetLinksByUserID), ctx, userID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLinksByUserID", reflect.TypeOf((*MockLinkStorage)(nil).GetLinksByUserID), ctx, userID, query)
 }
 
 // GetOneByIdent mocks base method.
@@ -143,3 +233,83 @@ func (mr *MockLinkStorageMockRecorder) GetOneByIdent(ctx, ident interface{}) *go
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOneByIdent", reflect.TypeOf((*MockLinkStorage)(nil).GetOneByIdent), ctx, ident)
 }
+
+// GetUserLinkByURL mocks base method.
+func (m *MockLinkStorage) GetUserLinkByURL(ctx context.Context, userID int32, url string) (domain.Link, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserLinkByURL", ctx, userID, url)
+	ret0, _ := ret[0].(domain.Link)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserLinkByURL indicates an expected call of GetUserLinkByURL.
+func (mr *MockLinkStorageMockRecorder) GetUserLinkByURL(ctx, userID, url interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserLinkByURL", reflect.TypeOf((*MockLinkStorage)(nil).GetUserLinkByURL), ctx, userID, url)
+}
+
+// NextIdentSeq mocks base method.
+func (m *MockLinkStorage) NextIdentSeq(ctx context.Context) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NextIdentSeq", ctx)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// NextIdentSeq indicates an expected call of NextIdentSeq.
+func (mr *MockLinkStorageMockRecorder) NextIdentSeq(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NextIdentSeq", reflect.TypeOf((*MockLinkStorage)(nil).NextIdentSeq), ctx)
+}
+
+// Purge mocks base method.
+func (m *MockLinkStorage) Purge(ctx context.Context, before time.Time) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Purge", ctx, before)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Purge indicates an expected call of Purge.
+func (mr *MockLinkStorageMockRecorder) Purge(ctx, before interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Purge", reflect.TypeOf((*MockLinkStorage)(nil).Purge), ctx, before)
+}
+
+// Restore mocks base method.
+func (m *MockLinkStorage) Restore(ctx context.Context, deletedAfter time.Time, idents ...string) ([]string, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx, deletedAfter}
+	for _, a := range idents {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "Restore", varargs...)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Restore indicates an expected call of Restore.
+func (mr *MockLinkStorageMockRecorder) Restore(ctx, deletedAfter interface{}, idents ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx, deletedAfter}, idents...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Restore", reflect.TypeOf((*MockLinkStorage)(nil).Restore), varargs...)
+}
+
+// Update mocks base method.
+func (m *MockLinkStorage) Update(ctx context.Context, link domain.Link) (domain.Link, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Update", ctx, link)
+	ret0, _ := ret[0].(domain.Link)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Update indicates an expected call of Update.
+func (mr *MockLinkStorageMockRecorder) Update(ctx, link interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockLinkStorage)(nil).Update), ctx, link)
+}
@@ -0,0 +1,208 @@
+package qrcode
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+
+	qr "github.com/skip2/go-qrcode"
+)
+
+// Output formats.
+const (
+	FormatPNG = "png"
+	FormatSVG = "svg"
+)
+
+const (
+	DefaultSize   = 256
+	DefaultMargin = 4
+	MinSize       = 64
+	MaxSize       = 2048
+	MaxMargin     = 16
+)
+
+var (
+	ErrInvalidFormat = errors.New("invalid format: must be png or svg")
+	ErrInvalidLevel  = errors.New("invalid level: must be L, M, Q or H")
+	ErrInvalidColor  = errors.New("invalid color: must be RRGGBB or RRGGBBAA hex")
+	ErrInvalidSize   = fmt.Errorf("invalid size: must be between %d and %d", MinSize, MaxSize)
+	ErrInvalidMargin = fmt.Errorf("invalid margin: must be between 0 and %d", MaxMargin)
+	ErrTooSmall      = errors.New("size is too small for the code")
+)
+
+var levels = map[string]qr.RecoveryLevel{
+	"L": qr.Low,
+	"M": qr.Medium,
+	"Q": qr.High,
+	"H": qr.Highest,
+}
+
+// Options control how a code is drawn. Size is the image side in pixels and
+// Margin the quiet zone in modules.
+type Options struct {
+	Format     string
+	Size       int
+	Level      string
+	Margin     int
+	Foreground color.NRGBA
+	Background color.NRGBA
+}
+
+func DefaultOptions() Options {
+	return Options{
+		Format:     FormatPNG,
+		Size:       DefaultSize,
+		Level:      "M",
+		Margin:     DefaultMargin,
+		Foreground: color.NRGBA{A: 0xff},
+		Background: color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+	}
+}
+
+func (o Options) Validate() error {
+	if o.Format != FormatPNG && o.Format != FormatSVG {
+		return ErrInvalidFormat
+	}
+	if _, ok := levels[o.Level]; !ok {
+		return ErrInvalidLevel
+	}
+	if o.Size < MinSize || o.Size > MaxSize {
+		return ErrInvalidSize
+	}
+	if o.Margin < 0 || o.Margin > MaxMargin {
+		return ErrInvalidMargin
+	}
+	return nil
+}
+
+// ContentType is the media type of the format.
+func (o Options) ContentType() string {
+	if o.Format == FormatSVG {
+		return "image/svg+xml"
+	}
+	return "image/png"
+}
+
+// Key identifies the image drawn for content with these options. Drawing is
+// deterministic, so it can serve as an ETag.
+func (o Options) Key(content string) string {
+	return fmt.Sprintf("%s|%d|%s|%d|%s|%s|%s",
+		o.Format, o.Size, o.Level, o.Margin, FormatColor(o.Foreground), FormatColor(o.Background), content)
+}
+
+// ParseLevel accepts the error correction levels L, M, Q and H.
+func ParseLevel(s string) (string, error) {
+	level := strings.ToUpper(s)
+	if _, ok := levels[level]; !ok {
+		return "", ErrInvalidLevel
+	}
+	return level, nil
+}
+
+// ParseColor parses RRGGBB or RRGGBBAA hex with an optional leading #.
+func ParseColor(s string) (color.NRGBA, error) {
+	s = strings.TrimPrefix(s, "#")
+	if len(s) != 6 && len(s) != 8 {
+		return color.NRGBA{}, ErrInvalidColor
+	}
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return color.NRGBA{}, ErrInvalidColor
+	}
+	c := color.NRGBA{R: b[0], G: b[1], B: b[2], A: 0xff}
+	if len(b) == 4 {
+		c.A = b[3]
+	}
+	return c, nil
+}
+
+func FormatColor(c color.NRGBA) string {
+	return fmt.Sprintf("%02x%02x%02x%02x", c.R, c.G, c.B, c.A)
+}
+
+// Encode draws content as a QR code.
+func Encode(content string, opts Options) ([]byte, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	code, err := qr.New(content, levels[opts.Level])
+	if err != nil {
+		return nil, err
+	}
+	code.DisableBorder = true
+	modules := code.Bitmap()
+
+	side := len(modules) + 2*opts.Margin
+	if opts.Format == FormatSVG {
+		return drawSVG(modules, side, opts), nil
+	}
+	scale := opts.Size / side
+	if scale < 1 {
+		return nil, ErrTooSmall
+	}
+	return drawPNG(modules, scale, opts)
+}
+
+// drawPNG scales modules to whole pixels and centers the code, so the image
+// is exactly Size pixels wide.
+func drawPNG(modules [][]bool, scale int, opts Options) ([]byte, error) {
+	img := image.NewPaletted(image.Rect(0, 0, opts.Size, opts.Size), color.Palette{opts.Background, opts.Foreground})
+	offset := (opts.Size - scale*len(modules)) / 2
+	for y, row := range modules {
+		for x, dark := range row {
+			if !dark {
+				continue
+			}
+			for dy := 0; dy < scale; dy++ {
+				start := img.PixOffset(offset+x*scale, offset+y*scale+dy)
+				for dx := 0; dx < scale; dx++ {
+					img.Pix[start+dx] = 1
+				}
+			}
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// drawSVG draws each run of dark modules in a row as one rectangle in a
+// viewBox measured in modules.
+func drawSVG(modules [][]bool, side int, opts Options) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		opts.Size, opts.Size, side, side)
+	fmt.Fprintf(&buf, `<rect width="%d" height="%d"%s/>`, side, side, svgFill(opts.Background))
+	buf.WriteString(`<path d="`)
+	for y, row := range modules {
+		for x := 0; x < len(row); x++ {
+			if !row[x] {
+				continue
+			}
+			run := 1
+			for x+run < len(row) && row[x+run] {
+				run++
+			}
+			fmt.Fprintf(&buf, "M%d %dh%dv1h-%dz", x+opts.Margin, y+opts.Margin, run, run)
+			x += run
+		}
+	}
+	fmt.Fprintf(&buf, `"%s/></svg>`, svgFill(opts.Foreground))
+	return buf.Bytes()
+}
+
+func svgFill(c color.NRGBA) string {
+	fill := fmt.Sprintf(` fill="#%02x%02x%02x"`, c.R, c.G, c.B)
+	if c.A != 0xff {
+		fill += fmt.Sprintf(` fill-opacity="%.3g"`, float64(c.A)/0xff)
+	}
+	return fill
+}
@@ -0,0 +1,76 @@
+package qrcode
+
+import (
+	"bytes"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const content = "http://localhost:8080/some_ident"
+
+func Test_EncodePNG(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Size = 300
+	opts.Margin = 2
+	opts.Foreground = color.NRGBA{R: 0x11, G: 0x22, B: 0x33, A: 0xff}
+
+	data, err := Encode(content, opts)
+	require.NoError(t, err)
+	img, err := png.Decode(bytes.NewReader(data))
+	require.NoError(t, err)
+	require.Equal(t, 300, img.Bounds().Dx())
+	require.Equal(t, 300, img.Bounds().Dy())
+
+	// Version 3 codes are 29 modules wide: 33 with the margin, 9 pixels each,
+	// centered with 1 spare pixel on each side.
+	scale, offset := 9, 1
+	corner := offset + opts.Margin*scale
+	assert.Equal(t, color.NRGBA{R: 0x11, G: 0x22, B: 0x33, A: 0xff}, color.NRGBAModel.Convert(img.At(corner, corner)))
+	assert.Equal(t, color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}, color.NRGBAModel.Convert(img.At(corner-1, corner-1)))
+}
+
+func Test_EncodeSVG(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Format = FormatSVG
+	opts.Background = color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0x80}
+
+	data, err := Encode(content, opts)
+	require.NoError(t, err)
+	svg := string(data)
+	assert.True(t, strings.HasPrefix(svg, `<svg xmlns="http://www.w3.org/2000/svg" width="256" height="256" viewBox="0 0 37 37"`))
+	assert.Contains(t, svg, `<rect width="37" height="37" fill="#ffffff" fill-opacity="0.502"/>`)
+	// The top-left finder pattern starts with a run of 7 dark modules.
+	assert.Contains(t, svg, `M4 4h7v1h-7z`)
+}
+
+func Test_Options(t *testing.T) {
+	c, err := ParseColor("#FF000080")
+	require.NoError(t, err)
+	assert.Equal(t, color.NRGBA{R: 0xff, A: 0x80}, c)
+	_, err = ParseColor("red")
+	assert.ErrorIs(t, err, ErrInvalidColor)
+
+	level, err := ParseLevel("q")
+	require.NoError(t, err)
+	assert.Equal(t, "Q", level)
+	_, err = ParseLevel("X")
+	assert.ErrorIs(t, err, ErrInvalidLevel)
+
+	opts := DefaultOptions()
+	opts.Size = MaxSize + 1
+	assert.ErrorIs(t, opts.Validate(), ErrInvalidSize)
+
+	opts = DefaultOptions()
+	opts.Size = MinSize
+	opts.Level = "H"
+	opts.Margin = MaxMargin
+	_, err = Encode(strings.Repeat("x", 100), opts)
+	assert.ErrorIs(t, err, ErrTooSmall)
+
+	assert.NotEqual(t, DefaultOptions().Key(content), opts.Key(content))
+}
@@ -0,0 +1,49 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Limiter_Allow(t *testing.T) {
+	now := time.Date(2023, 10, 1, 12, 0, 0, 0, time.UTC)
+	l := New(PerMinute(60, 2))
+	l.now = func() time.Time { return now }
+
+	res := l.Allow("a")
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 2, res.Limit)
+	assert.Equal(t, 1, res.Remaining)
+	assert.Equal(t, time.Second, res.Reset)
+
+	assert.True(t, l.Allow("a").Allowed)
+	res = l.Allow("a")
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 0, res.Remaining)
+	assert.Equal(t, time.Second, res.RetryAfter)
+	assert.Equal(t, 2*time.Second, res.Reset)
+
+	assert.True(t, l.Allow("b").Allowed, "buckets are kept per key")
+
+	now = now.Add(500 * time.Millisecond)
+	res = l.Allow("a")
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 500*time.Millisecond, res.RetryAfter)
+
+	now = now.Add(500 * time.Millisecond)
+	assert.True(t, l.Allow("a").Allowed)
+}
+
+func Test_Limiter_Sweep(t *testing.T) {
+	now := time.Date(2023, 10, 1, 12, 0, 0, 0, time.UTC)
+	l := New(PerMinute(60, 1))
+	l.now = func() time.Time { return now }
+
+	l.Allow("a")
+	l.Allow("b")
+	now = now.Add(sweepInterval)
+	l.Allow("c")
+	assert.Len(t, l.buckets, 1)
+}
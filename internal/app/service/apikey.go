@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/domain"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/dto"
+)
+
+const (
+	// APIKeyPrefix starts every API key, so leaked keys are easy to find.
+	APIKeyPrefix     = "ys_"
+	apiKeyBytes      = 32
+	apiKeyShownLen   = len(APIKeyPrefix) + 8
+	apiKeyNameMaxLen = 64
+)
+
+// APIKeyStorage keeps API keys by the SHA-256 of the key. Revoked keys are
+// not listed.
+type APIKeyStorage interface {
+	CreateAPIKey(ctx context.Context, key domain.APIKey) (domain.APIKey, error)
+	GetAPIKeys(ctx context.Context, userID int32) ([]domain.APIKey, error)
+	GetAPIKeyByHash(ctx context.Context, hash string) (domain.APIKey, error)
+	RevokeAPIKey(ctx context.Context, id int64, userID int32, at time.Time) error
+}
+
+type apiKeyService struct {
+	storage APIKeyStorage
+}
+
+func NewAPIKeyService(storage APIKeyStorage) *apiKeyService {
+	return &apiKeyService{storage: storage}
+}
+
+// CreateAPIKey issues a key for userID. The key itself is in the result only,
+// it cannot be read again.
+func (s *apiKeyService) CreateAPIKey(ctx context.Context, userID int32, keyReq dto.APIKeyReq) (dto.APIKeyRes, error) {
+	name := strings.TrimSpace(keyReq.Name)
+	if name == "" || len(name) > apiKeyNameMaxLen {
+		return dto.APIKeyRes{}, fmt.Errorf("%w: name must be 1 to %d characters long", domain.ErrInvalidQuery, apiKeyNameMaxLen)
+	}
+	scopes, err := validateScopes(keyReq.Scopes)
+	if err != nil {
+		return dto.APIKeyRes{}, err
+	}
+	expiresAt, err := expiryTime(keyReq.TTLSeconds, keyReq.ExpiresAt)
+	if err != nil {
+		return dto.APIKeyRes{}, err
+	}
+
+	b := make([]byte, apiKeyBytes)
+	if _, err := rand.Read(b); err != nil {
+		return dto.APIKeyRes{}, err
+	}
+	secret := APIKeyPrefix + hex.EncodeToString(b)
+	key, err := s.storage.CreateAPIKey(ctx, domain.APIKey{
+		UserID:    userID,
+		Name:      name,
+		Prefix:    secret[:apiKeyShownLen],
+		Hash:      hashAPIKey(secret),
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		return dto.APIKeyRes{}, err
+	}
+	res := apiKeyRes(key)
+	res.Key = secret
+	return res, nil
+}
+
+func (s *apiKeyService) GetAPIKeys(ctx context.Context, userID int32) ([]dto.APIKeyRes, error) {
+	keys, err := s.storage.GetAPIKeys(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]dto.APIKeyRes, 0, len(keys))
+	for _, v := range keys {
+		result = append(result, apiKeyRes(v))
+	}
+	return result, nil
+}
+
+func (s *apiKeyService) RevokeAPIKey(ctx context.Context, userID int32, id int64) error {
+	return s.storage.RevokeAPIKey(ctx, id, userID, time.Now())
+}
+
+// ParseAPIKey returns the active key matching secret.
+func (s *apiKeyService) ParseAPIKey(ctx context.Context, secret string) (domain.APIKey, error) {
+	if !strings.HasPrefix(secret, APIKeyPrefix) {
+		return domain.APIKey{}, domain.ErrInvalidAPIKey
+	}
+	key, err := s.storage.GetAPIKeyByHash(ctx, hashAPIKey(secret))
+	if errors.Is(err, domain.ErrNotFound) {
+		return domain.APIKey{}, domain.ErrInvalidAPIKey
+	}
+	if err != nil {
+		return domain.APIKey{}, err
+	}
+	if !key.IsActive(time.Now()) {
+		return domain.APIKey{}, domain.ErrInvalidAPIKey
+	}
+	return key, nil
+}
+
+// validateScopes checks scopes against the known ones and drops repeats. No
+// scopes means all of them.
+func validateScopes(scopes []string) ([]string, error) {
+	if len(scopes) == 0 {
+		return append([]string(nil), domain.Scopes...), nil
+	}
+	seen := make(map[string]bool, len(scopes))
+	result := make([]string, 0, len(scopes))
+	for _, v := range scopes {
+		if !(domain.APIKey{Scopes: domain.Scopes}).HasScope(v) {
+			return nil, fmt.Errorf("%w: unknown scope %q", domain.ErrInvalidQuery, v)
+		}
+		if !seen[v] {
+			seen[v] = true
+			result = append(result, v)
+		}
+	}
+	return result, nil
+}
+
+// hashAPIKey is a plain SHA-256: keys are random, so there is nothing for a
+// slow hash to protect, and the hash is the lookup key.
+func hashAPIKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func apiKeyRes(key domain.APIKey) dto.APIKeyRes {
+	return dto.APIKeyRes{
+		ID:        key.ID,
+		Name:      key.Name,
+		Prefix:    key.Prefix,
+		Scopes:    key.Scopes,
+		ExpiresAt: key.ExpiresAt,
+		CreatedAt: key.CreatedAt,
+	}
+}
@@ -2,19 +2,50 @@ package service
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
+	"unicode"
 
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/domain"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/dto"
 	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/bcrypt"
 )
 
 const (
-	signingKey = "ushjdhui38487"
-	tokenExp   = time.Hour * 3
+	defaultTokenExp = time.Hour * 3
+	ephemeralKID    = "ephemeral"
+	kidHeader       = "kid"
+
+	loginMinLen    = 3
+	loginMaxLen    = 64
+	passwordMinLen = 8
+	// bcrypt ignores bytes past 72, so longer passwords are refused rather
+	// than silently cut.
+	passwordMaxLen = 72
+	// dummyPasswordHash is compared against for unknown logins, so they take
+	// as long to refuse as wrong passwords.
+	dummyPasswordHash = "$2a$10$nJR/JRMchTgnbx6fi.fMTu1qU.M5Ll9fs41vSfIj8uew53uvmPWwe"
 )
 
 type UserStorage interface {
 	CreateUser(ctx context.Context) (int32, error)
+	CountUsers(ctx context.Context) (int, error)
+	GetUser(ctx context.Context, userID int32) (domain.User, error)
+	GetUserByLogin(ctx context.Context, login string) (domain.User, error)
+	SetCredentials(ctx context.Context, user domain.User) error
+	SetLinkQuota(ctx context.Context, userID int32, quota *int) error
+}
+
+type AuthConfig struct {
+	SigningKeys   map[string]string
+	ActiveKID     string
+	TokenExp      time.Duration
+	RefreshBefore time.Duration
 }
 
 type tokenClaims struct {
@@ -24,40 +55,52 @@ type tokenClaims struct {
 
 type authService struct {
 	storage UserStorage
+	cfg     AuthConfig
 }
 
-func NewAauthService(userStorage UserStorage) *authService {
+func NewAauthService(userStorage UserStorage, cfg AuthConfig) *authService {
+	if len(cfg.SigningKeys) == 0 {
+		cfg.SigningKeys = map[string]string{ephemeralKID: randomSecret()}
+		cfg.ActiveKID = ephemeralKID
+	}
+	if cfg.TokenExp <= 0 {
+		cfg.TokenExp = defaultTokenExp
+	}
 	return &authService{
 		storage: userStorage,
+		cfg:     cfg,
 	}
 }
 
 func (s *authService) ParseToken(tokenString string) (int32, bool, error) {
-	claims := &tokenClaims{}
-	token, err := jwt.ParseWithClaims(tokenString, claims,
-		func(t *jwt.Token) (interface{}, error) {
-			if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
-			}
-			return []byte(signingKey), nil
-		})
-
+	claims, token, err := s.parseClaims(tokenString)
 	if err != nil {
 		return 0, false, err
 	}
+	return claims.UserID, token.Valid, nil
+}
 
-	return claims.UserID, token.Valid, err
+func (s *authService) NeedsRefresh(tokenString string) bool {
+	claims, token, err := s.parseClaims(tokenString)
+	if err != nil || !token.Valid || claims.ExpiresAt == nil {
+		return false
+	}
+	if token.Header[kidHeader] != s.cfg.ActiveKID {
+		return true
+	}
+	return time.Until(claims.ExpiresAt.Time) < s.cfg.RefreshBefore
 }
 
 func (s *authService) BuildJWTString(userID int32) (string, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, tokenClaims{
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(tokenExp)),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(s.cfg.TokenExp)),
 		},
 		UserID: userID,
 	})
+	token.Header[kidHeader] = s.cfg.ActiveKID
 
-	tokenString, err := token.SignedString([]byte(signingKey))
+	tokenString, err := token.SignedString([]byte(s.cfg.SigningKeys[s.cfg.ActiveKID]))
 	if err != nil {
 		return "", err
 	}
@@ -66,5 +109,94 @@ func (s *authService) BuildJWTString(userID int32) (string, error) {
 }
 
 func (s *authService) CreateUser(ctx context.Context) (int32, error) {
-   return s.storage.CreateUser(ctx)
+	return s.storage.CreateUser(ctx)
+}
+
+// Register creates an account. It always gets a new user ID: tokens issued
+// to the anonymous caller must not become sessions of the account, so the
+// caller's links are claimed instead.
+func (s *authService) Register(ctx context.Context, creds dto.CredentialsReq) (domain.User, error) {
+	if err := validateCredentials(creds); err != nil {
+		return domain.User{}, err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(creds.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return domain.User{}, err
+	}
+
+	userID, err := s.storage.CreateUser(ctx)
+	if err != nil {
+		return domain.User{}, err
+	}
+	user := domain.User{ID: userID, Login: creds.Login, PasswordHash: string(hash)}
+	if err := s.storage.SetCredentials(ctx, user); err != nil {
+		return domain.User{}, err
+	}
+	return user, nil
+}
+
+func (s *authService) Login(ctx context.Context, creds dto.CredentialsReq) (domain.User, error) {
+	user, err := s.storage.GetUserByLogin(ctx, creds.Login)
+	if errors.Is(err, domain.ErrNotFound) {
+		bcrypt.CompareHashAndPassword([]byte(dummyPasswordHash), []byte(creds.Password))
+		return domain.User{}, domain.ErrInvalidCredentials
+	}
+	if err != nil {
+		return domain.User{}, err
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(creds.Password)); err != nil {
+		return domain.User{}, domain.ErrInvalidCredentials
+	}
+	return user, nil
+}
+
+// IsRegistered reports whether userID has an account. Users the storage does
+// not know are anonymous.
+func (s *authService) IsRegistered(ctx context.Context, userID int32) (bool, error) {
+	user, err := s.storage.GetUser(ctx, userID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return false, nil
+	}
+	return user.IsRegistered(), err
+}
+
+func validateCredentials(creds dto.CredentialsReq) error {
+	if len(creds.Login) < loginMinLen || len(creds.Login) > loginMaxLen {
+		return fmt.Errorf("%w: login must be %d to %d characters long", domain.ErrInvalidQuery, loginMinLen, loginMaxLen)
+	}
+	if strings.IndexFunc(creds.Login, func(r rune) bool { return unicode.IsSpace(r) || !unicode.IsPrint(r) }) >= 0 {
+		return fmt.Errorf("%w: login must not contain spaces", domain.ErrInvalidQuery)
+	}
+	if len(creds.Password) < passwordMinLen || len(creds.Password) > passwordMaxLen {
+		return fmt.Errorf("%w: password must be %d to %d bytes long", domain.ErrInvalidQuery, passwordMinLen, passwordMaxLen)
+	}
+	return nil
+}
+
+func (s *authService) parseClaims(tokenString string) (*tokenClaims, *jwt.Token, error) {
+	claims := &tokenClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims,
+		func(t *jwt.Token) (interface{}, error) {
+			if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+				return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
+			}
+			kid, _ := t.Header[kidHeader].(string)
+			key, ok := s.cfg.SigningKeys[kid]
+			if !ok {
+				return nil, fmt.Errorf("unknown signing key: %q", kid)
+			}
+			return []byte(key), nil
+		})
+	return claims, token, err
+}
+
+// randomSecret makes the ephemeral signing key. It panics when the system has
+// no randomness, as tokens signed with a guessable key are worse than no
+// start.
+func randomSecret() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic("cannot generate a JWT signing key: " + err.Error())
+	}
+	return hex.EncodeToString(b)
 }
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func Test_authService_KeyRotation(t *testing.T) {
+	oldService := NewAauthService(nil, AuthConfig{
+		SigningKeys: map[string]string{"old": "secret-old"},
+		ActiveKID:   "old",
+	})
+	oldToken, err := oldService.BuildJWTString(7)
+	require.NoError(t, err)
+
+	rotated := NewAauthService(nil, AuthConfig{
+		SigningKeys:   map[string]string{"old": "secret-old", "new": "secret-new"},
+		ActiveKID:     "new",
+		RefreshBefore: time.Minute,
+	})
+	userID, valid, err := rotated.ParseToken(oldToken)
+	require.NoError(t, err)
+	assert.True(t, valid)
+	assert.Equal(t, int32(7), userID)
+	assert.True(t, rotated.NeedsRefresh(oldToken))
+
+	newToken, err := rotated.BuildJWTString(7)
+	require.NoError(t, err)
+	assert.False(t, rotated.NeedsRefresh(newToken))
+
+	_, _, err = oldService.ParseToken(newToken)
+	assert.Error(t, err)
+}
+
+func Test_authService_NeedsRefresh(t *testing.T) {
+	s := NewAauthService(nil, AuthConfig{
+		SigningKeys:   map[string]string{"k": "secret"},
+		ActiveKID:     "k",
+		TokenExp:      time.Minute,
+		RefreshBefore: 2 * time.Minute,
+	})
+	token, err := s.BuildJWTString(1)
+	require.NoError(t, err)
+	assert.True(t, s.NeedsRefresh(token))
+	assert.False(t, s.NeedsRefresh("not a token"))
+}
+
+func Test_authService_dummyPasswordHash(t *testing.T) {
+	cost, err := bcrypt.Cost([]byte(dummyPasswordHash))
+	require.NoError(t, err)
+	assert.Equal(t, bcrypt.DefaultCost, cost)
+}
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"context"
+
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/domain"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/dto"
+)
+
+const (
+	topReferrersLimit = 10
+)
+
+type ClickStorage interface {
+	CreateClicks(ctx context.Context, clicks []domain.Click) error
+	GetStatsByIdent(ctx context.Context, ident string, topReferrers int) (dto.LinkStatsRes, error)
+	Close() error
+}
+
+type clickService struct {
+	storage     ClickStorage
+	linkStorage LinkStorage
+}
+
+func NewClickService(storage ClickStorage, linkStorage LinkStorage) *clickService {
+	return &clickService{
+		storage:     storage,
+		linkStorage: linkStorage,
+	}
+}
+
+func (s *clickService) RecordClicks(ctx context.Context, clicks ...domain.Click) error {
+	if len(clicks) == 0 {
+		return nil
+	}
+	return s.storage.CreateClicks(ctx, clicks)
+}
+
+func (s *clickService) GetLinkStats(ctx context.Context, ident string, userID int32) (dto.LinkStatsRes, error) {
+	link, err := s.linkStorage.GetOneByIdent(ctx, ident)
+	if err != nil {
+		return dto.LinkStatsRes{}, err
+	}
+	if link.UserID != userID {
+		return dto.LinkStatsRes{}, domain.ErrForbidden
+	}
+	return s.storage.GetStatsByIdent(ctx, ident, topReferrersLimit)
+}
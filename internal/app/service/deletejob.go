@@ -0,0 +1,142 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/domain"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/dto"
+)
+
+const (
+	deleteJobBatch = 100
+	// deleteJobLease is how long a claimed job is hidden from other workers.
+	// A job whose worker died before finishing it is picked up again after it.
+	deleteJobLease       = time.Minute
+	deleteJobMaxAttempts = 10
+	deleteJobBaseBackoff = 5 * time.Second
+	deleteJobMaxBackoff  = time.Hour
+	// DeleteJobRetention is how long done and dead jobs are kept for status
+	// requests.
+	DeleteJobRetention = 7 * 24 * time.Hour
+)
+
+// DeleteJobStorage is the outbox of deletion requests. ClaimDeleteJobs returns
+// pending jobs due at now and postpones them by lease, so a job is processed
+// by one worker at a time.
+type DeleteJobStorage interface {
+	CreateDeleteJob(ctx context.Context, job domain.DeleteJob) (domain.DeleteJob, error)
+	GetDeleteJob(ctx context.Context, id int64) (domain.DeleteJob, error)
+	ClaimDeleteJobs(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]domain.DeleteJob, error)
+	UpdateDeleteJob(ctx context.Context, job domain.DeleteJob) error
+	DeleteFinishedJobs(ctx context.Context, before time.Time) error
+	CountPendingJobs(ctx context.Context) (int, error)
+}
+
+type deleteJobService struct {
+	storage     DeleteJobStorage
+	linkStorage LinkStorage
+}
+
+func NewDeleteJobService(storage DeleteJobStorage, linkStorage LinkStorage) *deleteJobService {
+	return &deleteJobService{
+		storage:     storage,
+		linkStorage: linkStorage,
+	}
+}
+
+// EnqueueDelete stores a job deleting the user's links. The links are deleted
+// by ProcessDeleteJobs.
+func (s *deleteJobService) EnqueueDelete(ctx context.Context, userID int32, idents ...string) (dto.DeleteJobRes, error) {
+	now := time.Now()
+	job, err := s.storage.CreateDeleteJob(ctx, domain.DeleteJob{
+		UserID:        userID,
+		Idents:        idents,
+		Status:        domain.DeleteJobPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	})
+	if err != nil {
+		return dto.DeleteJobRes{}, err
+	}
+	return deleteJobRes(job), nil
+}
+
+func (s *deleteJobService) GetDeleteJob(ctx context.Context, id int64, userID int32) (dto.DeleteJobRes, error) {
+	job, err := s.storage.GetDeleteJob(ctx, id)
+	if err != nil {
+		return dto.DeleteJobRes{}, err
+	}
+	if job.UserID != userID {
+		return dto.DeleteJobRes{}, domain.ErrForbidden
+	}
+	return deleteJobRes(job), nil
+}
+
+// ProcessDeleteJobs runs the due jobs and returns them in their new state. A
+// failed job is retried with exponential backoff and is dead after
+// deleteJobMaxAttempts attempts.
+func (s *deleteJobService) ProcessDeleteJobs(ctx context.Context) ([]domain.DeleteJob, error) {
+	jobs, err := s.storage.ClaimDeleteJobs(ctx, time.Now(), deleteJobLease, deleteJobBatch)
+	if err != nil {
+		return nil, err
+	}
+	for i := range jobs {
+		job := &jobs[i]
+		err := s.linkStorage.DeleteByIdents(ctx, job.UserID, job.Idents...)
+		job.Attempts++
+		job.UpdatedAt = time.Now()
+		switch {
+		case err == nil:
+			job.Status = domain.DeleteJobDone
+			job.LastError = ""
+		case job.Attempts >= deleteJobMaxAttempts:
+			job.Status = domain.DeleteJobDead
+			job.LastError = err.Error()
+		default:
+			job.LastError = err.Error()
+			job.NextAttemptAt = job.UpdatedAt.Add(deleteJobBackoff(job.Attempts))
+		}
+		if err := s.storage.UpdateDeleteJob(ctx, *job); err != nil {
+			return jobs[:i], err
+		}
+	}
+	return jobs, nil
+}
+
+// CountPendingDeleteJobs returns how many jobs wait to be run or retried.
+func (s *deleteJobService) CountPendingDeleteJobs(ctx context.Context) (int, error) {
+	return s.storage.CountPendingJobs(ctx)
+}
+
+// PruneDeleteJobs removes done and dead jobs older than DeleteJobRetention.
+func (s *deleteJobService) PruneDeleteJobs(ctx context.Context) error {
+	return s.storage.DeleteFinishedJobs(ctx, time.Now().Add(-DeleteJobRetention))
+}
+
+// deleteJobBackoff is the delay before the retry following attempt.
+func deleteJobBackoff(attempt int) time.Duration {
+	backoff := deleteJobBaseBackoff
+	for i := 1; i < attempt && backoff < deleteJobMaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > deleteJobMaxBackoff {
+		backoff = deleteJobMaxBackoff
+	}
+	return backoff
+}
+
+func deleteJobRes(job domain.DeleteJob) dto.DeleteJobRes {
+	res := dto.DeleteJobRes{
+		ID:        job.ID,
+		Status:    job.Status,
+		Attempts:  job.Attempts,
+		LastError: job.LastError,
+	}
+	if job.Status == domain.DeleteJobPending && job.Attempts > 0 {
+		next := job.NextAttemptAt
+		res.NextAttemptAt = &next
+	}
+	return res
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/domain"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/mock/mockservice"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func Test_deleteJobBackoff(t *testing.T) {
+	assert.Equal(t, 5*time.Second, deleteJobBackoff(1))
+	assert.Equal(t, 10*time.Second, deleteJobBackoff(2))
+	assert.Equal(t, 40*time.Second, deleteJobBackoff(4))
+	assert.Equal(t, time.Hour, deleteJobBackoff(100))
+}
+
+func Test_deleteJobService_ProcessDeleteJobs(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	jobStorage := mockservice.NewMockDeleteJobStorage(c)
+	linkStorage := mockservice.NewMockLinkStorage(c)
+	s := NewDeleteJobService(jobStorage, linkStorage)
+	errDB := errors.New("connection refused")
+
+	jobs := []domain.DeleteJob{
+		{ID: 1, UserID: 1, Idents: []string{"a"}, Status: domain.DeleteJobPending},
+		{ID: 2, UserID: 1, Idents: []string{"b"}, Status: domain.DeleteJobPending},
+		{ID: 3, UserID: 1, Idents: []string{"c"}, Status: domain.DeleteJobPending, Attempts: deleteJobMaxAttempts - 1},
+	}
+	jobStorage.EXPECT().ClaimDeleteJobs(gomock.Any(), gomock.Any(), deleteJobLease, deleteJobBatch).Return(jobs, nil)
+	linkStorage.EXPECT().DeleteByIdents(gomock.Any(), int32(1), "a").Return(nil)
+	linkStorage.EXPECT().DeleteByIdents(gomock.Any(), int32(1), "b").Return(errDB)
+	linkStorage.EXPECT().DeleteByIdents(gomock.Any(), int32(1), "c").Return(errDB)
+	jobStorage.EXPECT().UpdateDeleteJob(gomock.Any(), gomock.Any()).Return(nil).Times(3)
+
+	start := time.Now()
+	processed, err := s.ProcessDeleteJobs(context.Background())
+	require.NoError(t, err)
+	require.Len(t, processed, 3)
+
+	assert.Equal(t, domain.DeleteJobDone, processed[0].Status)
+	assert.Equal(t, 1, processed[0].Attempts)
+
+	assert.Equal(t, domain.DeleteJobPending, processed[1].Status)
+	assert.Equal(t, errDB.Error(), processed[1].LastError)
+	assert.False(t, processed[1].NextAttemptAt.Before(start.Add(deleteJobBaseBackoff)))
+
+	assert.Equal(t, domain.DeleteJobDead, processed[2].Status)
+	assert.Equal(t, deleteJobMaxAttempts, processed[2].Attempts)
+}
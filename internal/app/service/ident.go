@@ -0,0 +1,157 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Ident generator strategies.
+const (
+	IdentSequence = "sequence"
+	IdentRandom   = "random"
+	IdentHash     = "hash"
+)
+
+const (
+	DefaultIdentStrategy = IdentRandom
+	DefaultIdentLength   = 8
+	DefaultIdentAlphabet = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+	minIdentLength = 4
+	// maxIdentAttempts bounds the retries after an ident collision.
+	maxIdentAttempts = 5
+)
+
+var errIdentsExhausted = errors.New("no free ident found")
+
+// IdentGenerator produces idents for links created without an alias. attempt
+// counts the retries after the previous ident collided, starting at 0.
+type IdentGenerator interface {
+	Generate(ctx context.Context, url string, attempt int) (string, error)
+}
+
+// IdentSequencer hands out increasing numbers that are never reused.
+type IdentSequencer interface {
+	NextIdentSeq(ctx context.Context) (int64, error)
+}
+
+// NewIdentGenerator returns the generator for strategy. Sequence idents are
+// left-padded to length, random and hash idents have exactly length symbols.
+func NewIdentGenerator(strategy string, length int, alphabet string, seq IdentSequencer) (IdentGenerator, error) {
+	if err := ValidateIdentAlphabet(alphabet); err != nil {
+		return nil, err
+	}
+	if length < minIdentLength || length > aliasMaxLen {
+		return nil, fmt.Errorf("invalid ident length %d: must be between %d and %d", length, minIdentLength, aliasMaxLen)
+	}
+	switch strategy {
+	case IdentSequence:
+		return &sequenceGenerator{seq: seq, length: length, alphabet: alphabet}, nil
+	case IdentRandom:
+		return &randomGenerator{length: length, alphabet: alphabet}, nil
+	case IdentHash:
+		return &hashGenerator{length: length, alphabet: alphabet}, nil
+	}
+	return nil, fmt.Errorf("invalid ident strategy %q: must be %s, %s or %s", strategy, IdentSequence, IdentRandom, IdentHash)
+}
+
+// ValidateIdentAlphabet checks that alphabet has at least two distinct symbols
+// and only symbols allowed in aliases.
+func ValidateIdentAlphabet(alphabet string) error {
+	seen := make(map[rune]bool, len(alphabet))
+	for _, r := range alphabet {
+		if !strings.ContainsRune(aliasAlphabet, r) {
+			return fmt.Errorf("invalid ident alphabet: %q is not allowed", r)
+		}
+		if seen[r] {
+			return fmt.Errorf("invalid ident alphabet: %q repeats", r)
+		}
+		seen[r] = true
+	}
+	if len(seen) < 2 {
+		return errors.New("invalid ident alphabet: at least two symbols required")
+	}
+	return nil
+}
+
+type sequenceGenerator struct {
+	seq      IdentSequencer
+	length   int
+	alphabet string
+}
+
+// Generate ignores attempt: every call takes a fresh number, so a collision
+// with an alias is skipped by the retry.
+func (g *sequenceGenerator) Generate(ctx context.Context, url string, attempt int) (string, error) {
+	n, err := g.seq.NextIdentSeq(ctx)
+	if err != nil {
+		return "", err
+	}
+	ident := encodeBase(big.NewInt(n), g.alphabet)
+	if pad := g.length - len(ident); pad > 0 {
+		ident = strings.Repeat(g.alphabet[:1], pad) + ident
+	}
+	return ident, nil
+}
+
+type randomGenerator struct {
+	length   int
+	alphabet string
+}
+
+func (g *randomGenerator) Generate(ctx context.Context, url string, attempt int) (string, error) {
+	max := big.NewInt(int64(len(g.alphabet)))
+	ident := make([]byte, g.length)
+	for i := range ident {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		ident[i] = g.alphabet[n.Int64()]
+	}
+	return string(ident), nil
+}
+
+type hashGenerator struct {
+	length   int
+	alphabet string
+}
+
+// Generate derives the ident from the URL, so the same URL first maps to the
+// same ident. Retries mix in attempt to move off a collision.
+func (g *hashGenerator) Generate(ctx context.Context, url string, attempt int) (string, error) {
+	data := url
+	if attempt > 0 {
+		data += "#" + strconv.Itoa(attempt)
+	}
+	sum := sha256.Sum256([]byte(data))
+	ident := encodeBase(new(big.Int).SetBytes(sum[:]), g.alphabet)
+	if len(ident) < g.length {
+		ident = strings.Repeat(g.alphabet[:1], g.length-len(ident)) + ident
+	}
+	return ident[:g.length], nil
+}
+
+func encodeBase(n *big.Int, alphabet string) string {
+	if n.Sign() == 0 {
+		return alphabet[:1]
+	}
+	base := big.NewInt(int64(len(alphabet)))
+	n = new(big.Int).Set(n)
+	mod := new(big.Int)
+	var digits []byte
+	for n.Sign() > 0 {
+		n.DivMod(n, base, mod)
+		digits = append(digits, alphabet[mod.Int64()])
+	}
+	for i, j := 0, len(digits)-1; i < j; i, j = i+1, j-1 {
+		digits[i], digits[j] = digits[j], digits[i]
+	}
+	return string(digits)
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/domain"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/dto"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/mock/mockservice"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+type counter int64
+
+func (c *counter) NextIdentSeq(ctx context.Context) (int64, error) {
+	*c++
+	return int64(*c), nil
+}
+
+func Test_IdentGenerators(t *testing.T) {
+	ctx := context.Background()
+	var seq counter = 60
+
+	sequence, err := NewIdentGenerator(IdentSequence, 4, DefaultIdentAlphabet, &seq)
+	require.NoError(t, err)
+	ident, err := sequence.Generate(ctx, "https://example.com", 0)
+	require.NoError(t, err)
+	assert.Equal(t, "000Z", ident)
+	ident, err = sequence.Generate(ctx, "https://example.com", 0)
+	require.NoError(t, err)
+	assert.Equal(t, "0010", ident)
+
+	random, err := NewIdentGenerator(IdentRandom, 10, "ab", nil)
+	require.NoError(t, err)
+	ident, err = random.Generate(ctx, "https://example.com", 0)
+	require.NoError(t, err)
+	assert.Regexp(t, "^[ab]{10}$", ident)
+
+	hash, err := NewIdentGenerator(IdentHash, 6, DefaultIdentAlphabet, nil)
+	require.NoError(t, err)
+	first, err := hash.Generate(ctx, "https://example.com", 0)
+	require.NoError(t, err)
+	again, err := hash.Generate(ctx, "https://example.com", 0)
+	require.NoError(t, err)
+	retry, err := hash.Generate(ctx, "https://example.com", 1)
+	require.NoError(t, err)
+	assert.Len(t, first, 6)
+	assert.Equal(t, first, again)
+	assert.NotEqual(t, first, retry)
+
+	_, err = NewIdentGenerator("uuid", 8, DefaultIdentAlphabet, nil)
+	assert.Error(t, err)
+	_, err = NewIdentGenerator(IdentRandom, 8, "a/b", nil)
+	assert.Error(t, err)
+	_, err = NewIdentGenerator(IdentRandom, 2, DefaultIdentAlphabet, nil)
+	assert.Error(t, err)
+}
+
+func Test_linkService_GetIdent_RetriesCollision(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+	storage := mockservice.NewMockLinkStorage(c)
+	var seq counter
+	generator, err := NewIdentGenerator(IdentSequence, 4, DefaultIdentAlphabet, &seq)
+	require.NoError(t, err)
+	s := NewLinkService(storage, LinkConfig{IdentGenerator: generator})
+
+	gomock.InOrder(
+		storage.EXPECT().Create(gomock.Any(), domain.Link{Ident: "0001", FulLink: "https://example.com", UserID: 1}).
+			Return(domain.Link{}, domain.ErrAliasTaken),
+		storage.EXPECT().Create(gomock.Any(), domain.Link{Ident: "0002", FulLink: "https://example.com", UserID: 1}).
+			Return(domain.Link{ID: 1, Ident: "0002"}, nil),
+	)
+	ident, err := s.GetIdent(context.Background(), dto.LinkReq{URL: "https://example.com"}, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "0002", ident)
+
+	storage.EXPECT().Create(gomock.Any(), gomock.Any()).Return(domain.Link{}, domain.ErrAliasTaken)
+	_, err = s.GetIdent(context.Background(), dto.LinkReq{URL: "https://example.com", Alias: "taken"}, 1)
+	assert.ErrorIs(t, err, domain.ErrAliasTaken)
+}
@@ -2,61 +2,239 @@ package service
 
 import (
 	"context"
-	"crypto/md5"
-	"math/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/domain"
 	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/dto"
-	"github.com/speps/go-hashids"
 )
 
 const (
-	salt = "Qw6"
+	aliasMinLen   = 3
+	aliasMaxLen   = 64
+	aliasAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_"
+
+	defaultPageLimit = 100
+	maxPageLimit     = 1000
+
+	DefaultRestoreWindow = 7 * 24 * time.Hour
+	DefaultPurgeAfter    = 30 * 24 * time.Hour
 )
 
+var reservedAliases = map[string]bool{
+	"api":  true,
+	"ping": true,
+}
+
 type LinkStorage interface {
 	GetOneByIdent(ctx context.Context, ident string) (domain.Link, error)
-	Create(ctx context.Context, idemt, fulLink string, userID int32) (domain.Link, error)
+	GetByOriginalURL(ctx context.Context, url string) (domain.Link, error)
+	GetUserLinkByURL(ctx context.Context, userID int32, url string) (domain.Link, error)
+	Create(ctx context.Context, link domain.Link) (domain.Link, error)
 	CreateLinks(ctx context.Context, links []domain.Link, userID int32) error
-	GetLinksByUserID(ctx context.Context, userID int32) ([]dto.LinkListByUserIDRes, error)
-	DeleteByIdents(ctx context.Context, idents ...string) error
+	GetLinksByUserID(ctx context.Context, userID int32, query dto.LinkPageQuery) ([]domain.Link, error)
+	DeleteByIdents(ctx context.Context, userID int32, idents ...string) error
 	GetByIdents(ctx context.Context, idents ...string) ([]domain.Link, error)
+	DeleteExpired(ctx context.Context, now time.Time) error
+	CountLinks(ctx context.Context) (int, error)
+	CountUserLinks(ctx context.Context, userID int32) (int, error)
+	NextIdentSeq(ctx context.Context) (int64, error)
+	Update(ctx context.Context, link domain.Link) (domain.Link, error)
+	GetHistory(ctx context.Context, ident string) ([]domain.LinkChange, error)
+	Restore(ctx context.Context, deletedAfter time.Time, idents ...string) ([]string, error)
+	Purge(ctx context.Context, before time.Time) ([]string, error)
+	ClaimLinks(ctx context.Context, fromUserID, toUserID int32) (int, error)
 	Close() error
 }
 
+// LinkConfig tunes link creation. Storages keep original URLs unique per
+// user; with GlobalDedupe a URL shortened by anyone resolves to that link.
+// IdentGenerator defaults to random idents of DefaultIdentLength. Deleted
+// links can be restored for RestoreWindow and are purged after PurgeAfter.
+// A user keeps at most LinkQuota active links and shortens at most
+// MaxBatchSize links in a batch; 0 lifts either cap. Destination URLs must
+// use one of AllowedSchemes, DefaultAllowedSchemes by default, and lose their
+// fragment unless KeepFragment is set.
+type LinkConfig struct {
+	GlobalDedupe   bool
+	IdentGenerator IdentGenerator
+	RestoreWindow  time.Duration
+	PurgeAfter     time.Duration
+	LinkQuota      int
+	MaxBatchSize   int
+	AllowedSchemes []string
+	KeepFragment   bool
+}
+
 type linkService struct {
 	storage LinkStorage
+	cfg     LinkConfig
+	quota   QuotaChecker
 }
 
-func NewLinkService(storage LinkStorage) *linkService {
+func NewLinkService(storage LinkStorage, cfg LinkConfig) *linkService {
+	if cfg.IdentGenerator == nil {
+		cfg.IdentGenerator = &randomGenerator{length: DefaultIdentLength, alphabet: DefaultIdentAlphabet}
+	}
+	if cfg.RestoreWindow == 0 {
+		cfg.RestoreWindow = DefaultRestoreWindow
+	}
+	if cfg.PurgeAfter == 0 {
+		cfg.PurgeAfter = DefaultPurgeAfter
+	}
+	if len(cfg.AllowedSchemes) == 0 {
+		cfg.AllowedSchemes = DefaultAllowedSchemes
+	}
 	return &linkService{
 		storage: storage,
+		cfg:     cfg,
 	}
 }
 
-func (s *linkService) GetIdent(ctx context.Context, fulLink string, userID int32) (string, error) {
-	ident := s.GenerateIdent(fulLink)
-	link, err := s.storage.Create(ctx, ident, fulLink, userID)
-	return link.Ident, err
+func (s *linkService) GetIdent(ctx context.Context, linkReq dto.LinkReq, userID int32) (string, error) {
+	if linkReq.Alias != "" {
+		if err := s.ValidateAlias(linkReq.Alias); err != nil {
+			return "", err
+		}
+	}
+	expiresAt, err := expiryTime(linkReq.TTLSeconds, linkReq.ExpiresAt)
+	if err != nil {
+		return "", err
+	}
+	linkReq.URL, err = s.normalizeURL(linkReq.URL)
+	if err != nil {
+		return "", err
+	}
+	if existing, err := s.globalDuplicate(ctx, linkReq.URL); err != nil || existing.Ident != "" {
+		return existing.Ident, err
+	}
+	if err := s.checkQuota(ctx, userID, 1); err != nil {
+		if existing, dupErr := s.userDuplicate(ctx, userID, linkReq.URL); dupErr != nil {
+			return existing.Ident, dupErr
+		}
+		return "", err
+	}
+
+	link := domain.Link{
+		Ident:     linkReq.Alias,
+		FulLink:   linkReq.URL,
+		UserID:    userID,
+		ExpiresAt: expiresAt,
+	}
+	for attempt := 0; attempt < maxIdentAttempts; attempt++ {
+		if linkReq.Alias == "" {
+			link.Ident, err = s.generateIdent(ctx, linkReq.URL, attempt, nil)
+			if err != nil {
+				return "", err
+			}
+		}
+		created, err := s.storage.Create(ctx, link)
+		if errors.Is(err, domain.ErrAliasTaken) && linkReq.Alias == "" {
+			continue
+		}
+		return created.Ident, err
+	}
+	return "", errIdentsExhausted
 }
 
 func (s *linkService) GetIdents(ctx context.Context, linkReq []dto.LinkListReq, userID int32) ([]dto.LinkListRes, error) {
-	result := make([]dto.LinkListRes, 0)
-	links := make([]domain.Link, 0)
+	links := make([]domain.Link, 0, len(linkReq))
+	taken := make(map[string]bool)
+	var generated bool
 	for _, v := range linkReq {
-		ident := s.GenerateIdent(v.OriginalURL)
-		result = append(result, dto.LinkListRes{CorrelationID: v.CorrelationID, ShortURL: ident})
-		links = append(links, domain.Link{Ident: ident, FulLink: v.OriginalURL})
+		if v.Alias != "" {
+			if err := s.ValidateAlias(v.Alias); err != nil {
+				return nil, err
+			}
+			if taken[v.Alias] {
+				return nil, fmt.Errorf("%w: %s", domain.ErrAliasTaken, v.Alias)
+			}
+			taken[v.Alias] = true
+		}
+		generated = generated || v.Alias == ""
+		expiresAt, err := expiryTime(v.TTLSeconds, v.ExpiresAt)
+		if err != nil {
+			return nil, err
+		}
+		originalURL, err := s.normalizeURL(v.OriginalURL)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := s.globalDuplicate(ctx, originalURL); err != nil {
+			return nil, err
+		}
+		links = append(links, domain.Link{Ident: v.Alias, FulLink: originalURL, ExpiresAt: expiresAt})
 	}
-	err := s.storage.CreateLinks(ctx, links, userID)
-	if err != nil {
+	if err := s.checkQuota(ctx, userID, len(links)); err != nil {
+		for _, v := range links {
+			if _, dupErr := s.userDuplicate(ctx, userID, v.FulLink); dupErr != nil {
+				return nil, dupErr
+			}
+		}
 		return nil, err
 	}
-	return result, nil
+
+	for attempt := 0; attempt < maxIdentAttempts; attempt++ {
+		batchTaken := make(map[string]bool, len(taken))
+		for k := range taken {
+			batchTaken[k] = true
+		}
+		for i, v := range linkReq {
+			if v.Alias != "" {
+				continue
+			}
+			ident, err := s.generateIdent(ctx, links[i].FulLink, attempt, batchTaken)
+			if err != nil {
+				return nil, err
+			}
+			links[i].Ident = ident
+			batchTaken[ident] = true
+		}
+		err := s.storage.CreateLinks(ctx, links, userID)
+		if errors.Is(err, domain.ErrAliasTaken) && generated {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		result := make([]dto.LinkListRes, 0, len(links))
+		for i, v := range linkReq {
+			result = append(result, dto.LinkListRes{CorrelationID: v.CorrelationID, ShortURL: links[i].Ident})
+		}
+		return result, nil
+	}
+	return nil, errIdentsExhausted
 }
 
-func (s *linkService) GetLinksByUserID(ctx context.Context, userID int32) ([]dto.LinkListByUserIDRes, error) {
-	return s.storage.GetLinksByUserID(ctx, userID)
+func (s *linkService) GetLinksByUserID(ctx context.Context, userID int32, pageReq dto.LinkPageReq) (dto.LinkPageRes, error) {
+	query, err := pageQuery(pageReq)
+	if err != nil {
+		return dto.LinkPageRes{}, err
+	}
+	limit := query.Limit
+	query.Limit++
+	links, err := s.storage.GetLinksByUserID(ctx, userID, query)
+	if err != nil {
+		return dto.LinkPageRes{}, err
+	}
+
+	var page dto.LinkPageRes
+	if len(links) > limit {
+		links = links[:limit]
+		page.NextCursor = encodeCursor(query.SortBy, links[limit-1])
+	}
+	page.Links = make([]dto.LinkListByUserIDRes, 0, len(links))
+	for _, v := range links {
+		page.Links = append(page.Links, dto.LinkListByUserIDRes{
+			OriginalURL: v.FulLink,
+			ShortURL:    v.Ident,
+		})
+	}
+	return page, nil
 }
 
 func (s *linkService) GetFulLink(ctx context.Context, ident string) (domain.Link, error) {
@@ -67,8 +245,138 @@ func (s *linkService) GetFulLink(ctx context.Context, ident string) (domain.Link
 	return link, nil
 }
 
-func (s *linkService) DeleteLinksByIdent(ctx context.Context, idents ...string) error {
-	return s.storage.DeleteByIdents(ctx, idents...)
+func (s *linkService) DeleteExpiredLinks(ctx context.Context) error {
+	return s.storage.DeleteExpired(ctx, time.Now())
+}
+
+// SetQuotaChecker makes creating, restoring and claiming links check the link
+// quota of the user the links go to.
+func (s *linkService) SetQuotaChecker(quota QuotaChecker) {
+	s.quota = quota
+}
+
+// RestoreLinks undeletes the user's links deleted within the restore window.
+// Idents that are unknown, not deleted, deleted too long ago, expired or whose
+// URL the user has shortened again are reported as not restored. Restoring
+// more links than the quota leaves fails with a *domain.QuotaError.
+func (s *linkService) RestoreLinks(ctx context.Context, userID int32, idents ...string) (dto.LinkRestoreRes, error) {
+	links, err := s.storage.GetByIdents(ctx, idents...)
+	if err != nil {
+		return dto.LinkRestoreRes{}, err
+	}
+	now := time.Now()
+	deletedAfter := now.Add(-s.cfg.RestoreWindow)
+	var candidates []string
+	for _, link := range links {
+		if link.UserID != userID {
+			return dto.LinkRestoreRes{}, domain.ErrForbidden
+		}
+		if link.DeletedFlag && !link.IsExpired(now) && (link.DeletedAt == nil || link.DeletedAt.After(deletedAfter)) {
+			candidates = append(candidates, link.Ident)
+		}
+	}
+	if err := s.checkQuota(ctx, userID, len(candidates)); err != nil {
+		return dto.LinkRestoreRes{}, err
+	}
+
+	res := dto.LinkRestoreRes{Restored: make([]string, 0), NotRestored: make([]string, 0)}
+	restored := make(map[string]bool)
+	if len(candidates) > 0 {
+		restoredIdents, err := s.storage.Restore(ctx, deletedAfter, candidates...)
+		if err != nil {
+			return dto.LinkRestoreRes{}, err
+		}
+		for _, v := range restoredIdents {
+			restored[v] = true
+		}
+	}
+	seen := make(map[string]bool, len(idents))
+	for _, v := range idents {
+		if seen[v] {
+			continue
+		}
+		seen[v] = true
+		if restored[v] {
+			res.Restored = append(res.Restored, v)
+		} else {
+			res.NotRestored = append(res.NotRestored, v)
+		}
+	}
+	return res, nil
+}
+
+// PurgeDeletedLinks permanently removes links deleted longer than PurgeAfter
+// ago and returns their idents.
+func (s *linkService) PurgeDeletedLinks(ctx context.Context) ([]string, error) {
+	return s.storage.Purge(ctx, time.Now().Add(-s.cfg.PurgeAfter))
+}
+
+// UpdateLink changes the destination or expiry of the user's link, keeping
+// the replaced destination in its history.
+func (s *linkService) UpdateLink(ctx context.Context, ident string, userID int32, updateReq dto.LinkUpdateReq) (dto.LinkDetailsRes, error) {
+	link, err := s.ownedLink(ctx, ident, userID)
+	if err != nil {
+		return dto.LinkDetailsRes{}, err
+	}
+	switch {
+	case updateReq.NoExpiry && (updateReq.TTLSeconds != 0 || updateReq.ExpiresAt != nil):
+		return dto.LinkDetailsRes{}, fmt.Errorf("%w: no_expiry excludes ttl_seconds and expires_at", domain.ErrInvalidExpiry)
+	case updateReq.NoExpiry:
+		link.ExpiresAt = nil
+	case updateReq.TTLSeconds != 0 || updateReq.ExpiresAt != nil:
+		link.ExpiresAt, err = expiryTime(updateReq.TTLSeconds, updateReq.ExpiresAt)
+		if err != nil {
+			return dto.LinkDetailsRes{}, err
+		}
+	}
+	if updateReq.URL != "" {
+		link.FulLink, err = s.normalizeURL(updateReq.URL)
+		if err != nil {
+			return dto.LinkDetailsRes{}, err
+		}
+	}
+
+	link, err = s.storage.Update(ctx, link)
+	if err != nil {
+		return dto.LinkDetailsRes{}, err
+	}
+	return s.linkDetails(ctx, link)
+}
+
+func (s *linkService) GetLinkHistory(ctx context.Context, ident string, userID int32) (dto.LinkDetailsRes, error) {
+	link, err := s.ownedLink(ctx, ident, userID)
+	if err != nil {
+		return dto.LinkDetailsRes{}, err
+	}
+	return s.linkDetails(ctx, link)
+}
+
+// GetUserLink returns the user's link that is not deleted.
+func (s *linkService) GetUserLink(ctx context.Context, ident string, userID int32) (domain.Link, error) {
+	return s.ownedLink(ctx, ident, userID)
+}
+
+// ClaimLinks moves the links of an anonymous user to an account. It fails
+// with a *domain.QuotaError and moves nothing if the active links would take
+// the account past its quota.
+func (s *linkService) ClaimLinks(ctx context.Context, fromUserID, toUserID int32) (int, error) {
+	if s.quota != nil {
+		n, err := s.storage.CountUserLinks(ctx, fromUserID)
+		if err != nil {
+			return 0, err
+		}
+		if err := s.checkQuota(ctx, toUserID, n); err != nil {
+			return 0, err
+		}
+	}
+	return s.storage.ClaimLinks(ctx, fromUserID, toUserID)
+}
+
+func (s *linkService) checkQuota(ctx context.Context, userID int32, n int) error {
+	if s.quota == nil {
+		return nil
+	}
+	return s.quota.CheckLinkQuota(ctx, userID, n)
 }
 
 func (s *linkService) CanDelete(ctx context.Context, userID int32, idents ...string) (bool, error) {
@@ -84,12 +392,178 @@ func (s *linkService) CanDelete(ctx context.Context, userID int32, idents ...str
 	return true, nil
 }
 
-func (s *linkService) GenerateIdent(url string) string {
-	hash := md5.New()
-	hash.Write([]byte(url))
-	hd := hashids.NewData()
-	hd.Salt = string(hash.Sum([]byte(salt)))
-	h, _ := hashids.NewWithData(hd)
-	ident, _ := h.Encode([]int{rand.Int()})
-	return ident
+// globalDuplicate returns the live link to url with ErrConflict when global
+// dedupe is on and any user has already shortened it.
+func (s *linkService) globalDuplicate(ctx context.Context, url string) (domain.Link, error) {
+	if !s.cfg.GlobalDedupe {
+		return domain.Link{}, nil
+	}
+	link, err := s.storage.GetByOriginalURL(ctx, url)
+	if errors.Is(err, domain.ErrNotFound) {
+		return domain.Link{}, nil
+	}
+	if err != nil {
+		return domain.Link{}, err
+	}
+	return link, domain.ErrConflict
+}
+
+// userDuplicate returns the user's live link to url with domain.ErrConflict.
+// Storages refuse such duplicates on create; the lookup is for users over the
+// quota, who get the existing link rather than a quota error.
+func (s *linkService) userDuplicate(ctx context.Context, userID int32, url string) (domain.Link, error) {
+	link, err := s.storage.GetUserLinkByURL(ctx, userID, url)
+	if errors.Is(err, domain.ErrNotFound) {
+		return domain.Link{}, nil
+	}
+	if err != nil {
+		return domain.Link{}, err
+	}
+	return link, domain.ErrConflict
+}
+
+// ownedLink returns the user's link that is not deleted.
+func (s *linkService) ownedLink(ctx context.Context, ident string, userID int32) (domain.Link, error) {
+	link, err := s.storage.GetOneByIdent(ctx, ident)
+	if err != nil {
+		return domain.Link{}, err
+	}
+	if link.DeletedFlag {
+		return domain.Link{}, domain.ErrNotFound
+	}
+	if link.UserID != userID {
+		return domain.Link{}, domain.ErrForbidden
+	}
+	return link, nil
+}
+
+func (s *linkService) linkDetails(ctx context.Context, link domain.Link) (dto.LinkDetailsRes, error) {
+	history, err := s.storage.GetHistory(ctx, link.Ident)
+	if err != nil {
+		return dto.LinkDetailsRes{}, err
+	}
+	details := dto.LinkDetailsRes{
+		ShortURL:    link.Ident,
+		OriginalURL: link.FulLink,
+		ExpiresAt:   link.ExpiresAt,
+		History:     make([]dto.LinkChangeRes, 0, len(history)),
+	}
+	for _, v := range history {
+		details.History = append(details.History, dto.LinkChangeRes{OriginalURL: v.OriginalURL, ChangedAt: v.ChangedAt})
+	}
+	return details, nil
+}
+
+// generateIdent returns a generated ident that is neither reserved nor in
+// taken, advancing attempt past such idents.
+func (s *linkService) generateIdent(ctx context.Context, url string, attempt int, taken map[string]bool) (string, error) {
+	for ; attempt < maxIdentAttempts*2; attempt++ {
+		ident, err := s.cfg.IdentGenerator.Generate(ctx, url, attempt)
+		if err != nil {
+			return "", err
+		}
+		if !reservedAliases[strings.ToLower(ident)] && !taken[ident] {
+			return ident, nil
+		}
+	}
+	return "", errIdentsExhausted
+}
+
+func (s *linkService) ValidateAlias(alias string) error {
+	if len(alias) < aliasMinLen || len(alias) > aliasMaxLen {
+		return fmt.Errorf("%w: length must be between %d and %d", domain.ErrInvalidAlias, aliasMinLen, aliasMaxLen)
+	}
+	for _, r := range alias {
+		if !strings.ContainsRune(aliasAlphabet, r) {
+			return fmt.Errorf("%w: unexpected character %q", domain.ErrInvalidAlias, r)
+		}
+	}
+	if reservedAliases[strings.ToLower(alias)] {
+		return fmt.Errorf("%w: %s is reserved", domain.ErrInvalidAlias, alias)
+	}
+	return nil
+}
+
+func expiryTime(ttlSeconds int64, at *time.Time) (*time.Time, error) {
+	if ttlSeconds != 0 && at != nil {
+		return nil, fmt.Errorf("%w: ttl_seconds and expires_at are mutually exclusive", domain.ErrInvalidExpiry)
+	}
+	if ttlSeconds < 0 {
+		return nil, fmt.Errorf("%w: ttl_seconds must be positive", domain.ErrInvalidExpiry)
+	}
+	if ttlSeconds > 0 {
+		expiresAt := time.Now().Add(time.Duration(ttlSeconds) * time.Second)
+		return &expiresAt, nil
+	}
+	if at != nil && !at.After(time.Now()) {
+		return nil, fmt.Errorf("%w: expires_at is in the past", domain.ErrInvalidExpiry)
+	}
+	return at, nil
+}
+
+func pageQuery(pageReq dto.LinkPageReq) (dto.LinkPageQuery, error) {
+	query := dto.LinkPageQuery{
+		SortBy: pageReq.Sort,
+		Search: pageReq.Search,
+		Limit:  pageReq.Limit,
+	}
+	if query.SortBy == "" {
+		query.SortBy = dto.SortCreated
+	}
+	if query.SortBy != dto.SortCreated && query.SortBy != dto.SortIdent {
+		return query, fmt.Errorf("%w: sort must be %s or %s", domain.ErrInvalidQuery, dto.SortCreated, dto.SortIdent)
+	}
+	switch pageReq.Order {
+	case "", dto.OrderDesc:
+		query.Desc = true
+	case dto.OrderAsc:
+	default:
+		return query, fmt.Errorf("%w: order must be %s or %s", domain.ErrInvalidQuery, dto.OrderAsc, dto.OrderDesc)
+	}
+	if query.Limit == 0 {
+		query.Limit = defaultPageLimit
+	}
+	if query.Limit < 0 || query.Limit > maxPageLimit {
+		return query, fmt.Errorf("%w: limit must be between 1 and %d", domain.ErrInvalidQuery, maxPageLimit)
+	}
+	if pageReq.Cursor != "" {
+		if err := decodeCursor(pageReq.Cursor, &query); err != nil {
+			return query, err
+		}
+	}
+	return query, nil
+}
+
+// encodeCursor returns an opaque cursor pointing after link in the sort order.
+func encodeCursor(sortBy string, link domain.Link) string {
+	key := link.Ident
+	if sortBy == dto.SortCreated {
+		key = strconv.FormatInt(int64(link.ID), 10)
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(sortBy + ":" + key))
+}
+
+func decodeCursor(cursor string, query *dto.LinkPageQuery) error {
+	invalid := fmt.Errorf("%w: malformed cursor", domain.ErrInvalidQuery)
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return invalid
+	}
+	sortBy, key, ok := strings.Cut(string(data), ":")
+	if !ok || key == "" {
+		return invalid
+	}
+	if sortBy != query.SortBy {
+		return fmt.Errorf("%w: cursor was issued for sort %s", domain.ErrInvalidQuery, sortBy)
+	}
+	if sortBy == dto.SortIdent {
+		query.AfterIdent = key
+		return nil
+	}
+	id, err := strconv.ParseInt(key, 10, 32)
+	if err != nil || id <= 0 {
+		return invalid
+	}
+	query.AfterID = int32(id)
+	return nil
 }
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/domain"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/dto"
+)
+
+// QuotaChecker checks that n more active links keep a user within the link
+// quota.
+type QuotaChecker interface {
+	CheckLinkQuota(ctx context.Context, userID int32, n int) error
+}
+
+// quotaService caps the active links of a user at the user's override or at
+// LinkConfig.LinkQuota. Checks count the links before they are created, so
+// concurrent requests of one user can each pass and together overshoot the
+// quota by up to the links of all but one of them.
+type quotaService struct {
+	linkStorage LinkStorage
+	userStorage UserStorage
+	cfg         LinkConfig
+}
+
+func NewQuotaService(linkStorage LinkStorage, userStorage UserStorage, cfg LinkConfig) *quotaService {
+	return &quotaService{
+		linkStorage: linkStorage,
+		userStorage: userStorage,
+		cfg:         cfg,
+	}
+}
+
+func (s *quotaService) GetQuota(ctx context.Context, userID int32) (dto.QuotaRes, error) {
+	limit, custom, err := s.linkQuota(ctx, userID)
+	if err != nil {
+		return dto.QuotaRes{}, err
+	}
+	used, err := s.linkStorage.CountUserLinks(ctx, userID)
+	if err != nil {
+		return dto.QuotaRes{}, err
+	}
+	res := dto.QuotaRes{
+		Links:        used,
+		LinkQuota:    limit,
+		MaxBatchSize: s.cfg.MaxBatchSize,
+		Custom:       custom,
+	}
+	if limit > 0 {
+		remaining := limit - used
+		if remaining < 0 {
+			remaining = 0
+		}
+		res.Remaining = &remaining
+	}
+	return res, nil
+}
+
+// CheckLinkQuota fails with a *domain.QuotaError if n more links would take
+// the user past the quota.
+func (s *quotaService) CheckLinkQuota(ctx context.Context, userID int32, n int) error {
+	if n <= 0 {
+		return nil
+	}
+	limit, _, err := s.linkQuota(ctx, userID)
+	if err != nil || limit == 0 {
+		return err
+	}
+	used, err := s.linkStorage.CountUserLinks(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if used+n > limit {
+		return &domain.QuotaError{Err: domain.ErrQuotaExceeded, Limit: limit, Used: used, Requested: n}
+	}
+	return nil
+}
+
+// CheckBatchSize fails with a *domain.QuotaError if a batch of n links is
+// over MaxBatchSize.
+func (s *quotaService) CheckBatchSize(n int) error {
+	if s.cfg.MaxBatchSize > 0 && n > s.cfg.MaxBatchSize {
+		return &domain.QuotaError{Err: domain.ErrBatchTooLarge, Limit: s.cfg.MaxBatchSize, Requested: n}
+	}
+	return nil
+}
+
+// SetLinkQuota overrides the link quota of a user; nil restores the default
+// and 0 lifts the cap.
+func (s *quotaService) SetLinkQuota(ctx context.Context, userID int32, quota *int) (dto.QuotaRes, error) {
+	if quota != nil && *quota < 0 {
+		return dto.QuotaRes{}, fmt.Errorf("%w: link_quota must not be negative", domain.ErrInvalidQuery)
+	}
+	if err := s.userStorage.SetLinkQuota(ctx, userID, quota); err != nil {
+		return dto.QuotaRes{}, err
+	}
+	return s.GetQuota(ctx, userID)
+}
+
+// linkQuota returns the quota of a user and whether it is an override.
+// Users unknown to the storage get the default.
+func (s *quotaService) linkQuota(ctx context.Context, userID int32) (int, bool, error) {
+	user, err := s.userStorage.GetUser(ctx, userID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return s.cfg.LinkQuota, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	if user.LinkQuota != nil {
+		return *user.LinkQuota, true, nil
+	}
+	return s.cfg.LinkQuota, false, nil
+}
@@ -0,0 +1,31 @@
+package service
+
+import (
+	"context"
+
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/dto"
+)
+
+type statsService struct {
+	linkStorage LinkStorage
+	userStorage UserStorage
+}
+
+func NewStatsService(linkStorage LinkStorage, userStorage UserStorage) *statsService {
+	return &statsService{
+		linkStorage: linkStorage,
+		userStorage: userStorage,
+	}
+}
+
+func (s *statsService) GetInternalStats(ctx context.Context) (dto.InternalStatsRes, error) {
+	urls, err := s.linkStorage.CountLinks(ctx)
+	if err != nil {
+		return dto.InternalStatsRes{}, err
+	}
+	users, err := s.userStorage.CountUsers(ctx)
+	if err != nil {
+		return dto.InternalStatsRes{}, err
+	}
+	return dto.InternalStatsRes{URLs: urls, Users: users}, nil
+}
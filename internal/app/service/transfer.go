@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/domain"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/dto"
+)
+
+const exportPageSize = 500
+
+// ExportLinks passes all of a user's links, deleted ones included, to write
+// a page at a time in creation order.
+func (s *linkService) ExportLinks(ctx context.Context, userID int32, write func([]dto.LinkExportRow) error) error {
+	query := dto.LinkPageQuery{SortBy: dto.SortCreated, Limit: exportPageSize, IncludeDeleted: true}
+	for {
+		links, err := s.storage.GetLinksByUserID(ctx, userID, query)
+		if err != nil {
+			return err
+		}
+		if len(links) == 0 {
+			return nil
+		}
+		rows := make([]dto.LinkExportRow, 0, len(links))
+		for _, v := range links {
+			rows = append(rows, dto.LinkExportRow{
+				Ident:       v.Ident,
+				OriginalURL: v.FulLink,
+				CreatedAt:   v.CreatedAt,
+				Deleted:     v.DeletedFlag,
+			})
+		}
+		if err := write(rows); err != nil {
+			return err
+		}
+		if len(links) < exportPageSize {
+			return nil
+		}
+		query.AfterID = links[len(links)-1].ID
+	}
+}
+
+// ImportLinks creates a batch of imported rows through GetIdents, keeping
+// the idents the rows carry. Deleted rows are skipped. A batch rejected as a
+// whole is retried row by row to find the rows at fault; errors not caused by
+// a row abort the import.
+func (s *linkService) ImportLinks(ctx context.Context, userID int32, rows []dto.LinkImportRow) (dto.LinkImportRes, error) {
+	var res dto.LinkImportRes
+	valid := make([]dto.LinkImportRow, 0, len(rows))
+	for _, v := range rows {
+		if v.Deleted {
+			res.Skipped++
+			continue
+		}
+		var err error
+		v.OriginalURL, err = s.normalizeURL(v.OriginalURL)
+		if err == nil {
+			err = s.validateImportAlias(v.Ident)
+		}
+		if err != nil {
+			addImportError(&res, v.Row, err)
+			continue
+		}
+		valid = append(valid, v)
+	}
+	if len(valid) == 0 {
+		return res, nil
+	}
+
+	linkReq := make([]dto.LinkListReq, 0, len(valid))
+	for _, v := range valid {
+		linkReq = append(linkReq, dto.LinkListReq{
+			CorrelationID: strconv.Itoa(v.Row),
+			OriginalURL:   v.OriginalURL,
+			Alias:         v.Ident,
+		})
+	}
+	_, err := s.GetIdents(ctx, linkReq, userID)
+	if err == nil {
+		res.Imported += len(valid)
+		return res, nil
+	}
+	if !isRowError(err) {
+		return res, err
+	}
+
+	for _, v := range valid {
+		ident, err := s.GetIdent(ctx, dto.LinkReq{URL: v.OriginalURL, Alias: v.Ident}, userID)
+		switch {
+		case err == nil:
+			res.Imported++
+		case errors.Is(err, domain.ErrConflict) && ident != "":
+			addImportError(&res, v.Row, fmt.Errorf("%w: already shortened as %s", err, ident))
+		case isRowError(err):
+			addImportError(&res, v.Row, err)
+		default:
+			return res, err
+		}
+	}
+	return res, nil
+}
+
+func (s *linkService) validateImportAlias(alias string) error {
+	if alias == "" {
+		return nil
+	}
+	return s.ValidateAlias(alias)
+}
+
+func addImportError(res *dto.LinkImportRes, row int, err error) {
+	res.Failed++
+	res.Errors = append(res.Errors, dto.LinkImportError{Row: row, Error: err.Error()})
+}
+
+func isRowError(err error) bool {
+	return errors.Is(err, domain.ErrAliasTaken) || errors.Is(err, domain.ErrConflict) ||
+		errors.Is(err, domain.ErrInvalidAlias) || errors.Is(err, domain.ErrInvalidExpiry) ||
+		errors.Is(err, domain.ErrInvalidURL)
+}
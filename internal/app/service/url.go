@@ -0,0 +1,101 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/domain"
+	"golang.org/x/net/idna"
+)
+
+// maxURLLen keeps normalized URLs within the size of a Postgres btree index
+// entry, as original_url is indexed.
+const maxURLLen = 2048
+
+// DefaultAllowedSchemes are the schemes of destination URLs accepted when
+// LinkConfig.AllowedSchemes is empty.
+var DefaultAllowedSchemes = []string{"http", "https"}
+
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// normalizeURL validates a destination URL and returns the form links are
+// stored and deduplicated by: the scheme and host lowercased, the host in
+// punycode, the default port and, unless KeepFragment is set, the fragment
+// dropped. Errors wrap domain.ErrInvalidURL with the reason. Links stored
+// before URLs were normalized keep their URL as given and are not matched by
+// its normalized form, so shortening it again makes a new link.
+func (s *linkService) normalizeURL(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", fmt.Errorf("%w: empty url", domain.ErrInvalidURL)
+	}
+	if len(raw) > maxURLLen {
+		return "", fmt.Errorf("%w: longer than %d bytes", domain.ErrInvalidURL, maxURLLen)
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return "", fmt.Errorf("%w: %s", domain.ErrInvalidURL, err)
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	if u.Scheme == "" {
+		return "", fmt.Errorf("%w: missing scheme", domain.ErrInvalidURL)
+	}
+	if !s.schemeAllowed(u.Scheme) {
+		return "", fmt.Errorf("%w: scheme %q is not allowed", domain.ErrInvalidURL, u.Scheme)
+	}
+	if u.Opaque != "" || u.Host == "" {
+		return "", fmt.Errorf("%w: missing host", domain.ErrInvalidURL)
+	}
+
+	host, port := u.Hostname(), u.Port()
+	if host == "" {
+		return "", fmt.Errorf("%w: missing host", domain.ErrInvalidURL)
+	}
+	if net.ParseIP(host) == nil {
+		host, err = idna.Lookup.ToASCII(strings.TrimSuffix(host, "."))
+		if err != nil {
+			return "", fmt.Errorf("%w: invalid host: %s", domain.ErrInvalidURL, err)
+		}
+	}
+	if port == defaultPorts[u.Scheme] {
+		port = ""
+	}
+	switch {
+	case port != "":
+		u.Host = net.JoinHostPort(host, port)
+	case strings.Contains(host, ":"):
+		u.Host = "[" + host + "]"
+	default:
+		u.Host = host
+	}
+
+	if !s.cfg.KeepFragment {
+		u.Fragment = ""
+		u.RawFragment = ""
+	}
+	normalized := u.String()
+	if len(normalized) > maxURLLen {
+		return "", fmt.Errorf("%w: longer than %d bytes", domain.ErrInvalidURL, maxURLLen)
+	}
+	return normalized, nil
+}
+
+func (s *linkService) schemeAllowed(scheme string) bool {
+	for _, v := range s.cfg.AllowedSchemes {
+		if strings.EqualFold(v, scheme) {
+			return true
+		}
+	}
+	return false
+}
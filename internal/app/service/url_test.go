@@ -0,0 +1,53 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_linkService_normalizeURL(t *testing.T) {
+	s := NewLinkService(nil, LinkConfig{})
+	tests := []struct {
+		name        string
+		url         string
+		expected    string
+		expectedErr bool
+	}{
+		{name: "unchanged", url: "https://practicum.yandex.ru/learn?a=1", expected: "https://practicum.yandex.ru/learn?a=1"},
+		{name: "spaces and case", url: "  HTTPS://Example.COM/Path  ", expected: "https://example.com/Path"},
+		{name: "default port", url: "http://example.com:80/a", expected: "http://example.com/a"},
+		{name: "other port", url: "https://example.com:8443/a", expected: "https://example.com:8443/a"},
+		{name: "fragment", url: "https://example.com/a#top", expected: "https://example.com/a"},
+		{name: "idn", url: "https://Пример.рф/путь", expected: "https://xn--e1afmkfd.xn--p1ai/%D0%BF%D1%83%D1%82%D1%8C"},
+		{name: "trailing dot", url: "https://example.com./", expected: "https://example.com/"},
+		{name: "ipv6", url: "https://[::1]:443/", expected: "https://[::1]/"},
+		{name: "empty", url: " \n", expectedErr: true},
+		{name: "javascript", url: "javascript:alert(1)", expectedErr: true},
+		{name: "no scheme", url: "example.com/a", expectedErr: true},
+		{name: "no host", url: "https:///a", expectedErr: true},
+		{name: "bad host", url: "https://exa mple.com/", expectedErr: true},
+		{name: "too long", url: "https://example.com/" + strings.Repeat("a", maxURLLen), expectedErr: true},
+		{name: "too long escaped", url: "https://example.com/" + strings.Repeat("я", maxURLLen/4), expectedErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			normalized, err := s.normalizeURL(tt.url)
+			if tt.expectedErr {
+				assert.ErrorIs(t, err, domain.ErrInvalidURL)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, normalized)
+		})
+	}
+
+	s = NewLinkService(nil, LinkConfig{AllowedSchemes: []string{"ftp"}, KeepFragment: true})
+	normalized, err := s.normalizeURL("FTP://example.com/a#b")
+	assert.NoError(t, err)
+	assert.Equal(t, "ftp://example.com/a#b", normalized)
+	_, err = s.normalizeURL("https://example.com/")
+	assert.ErrorIs(t, err, domain.ErrInvalidURL)
+}
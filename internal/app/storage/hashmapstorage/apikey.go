@@ -0,0 +1,80 @@
+package hashmapstorage
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/domain"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/metrics"
+)
+
+func apiKeyRecord(key domain.APIKey) logRecord {
+	return logRecord{Op: opAPIKey, APIKey: &key}
+}
+
+func (s *linkStorage) CreateAPIKey(ctx context.Context, key domain.APIKey) (domain.APIKey, error) {
+	defer metrics.ObserveStorage(backend, "CreateAPIKey", time.Now())
+	s.Lock()
+	defer s.Unlock()
+	key.ID = s.seqKeyID + 1
+	if err := s.appendRecords(apiKeyRecord(key)); err != nil {
+		return domain.APIKey{}, err
+	}
+	s.putAPIKey(key)
+	s.maybeCompact()
+	return key, nil
+}
+
+func (s *linkStorage) GetAPIKeys(ctx context.Context, userID int32) ([]domain.APIKey, error) {
+	defer metrics.ObserveStorage(backend, "GetAPIKeys", time.Now())
+	s.RLock()
+	defer s.RUnlock()
+	var keys []domain.APIKey
+	for _, v := range s.apiKeys {
+		if v.UserID == userID && v.RevokedAt == nil {
+			keys = append(keys, v)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+	return keys, nil
+}
+
+func (s *linkStorage) GetAPIKeyByHash(ctx context.Context, hash string) (domain.APIKey, error) {
+	defer metrics.ObserveStorage(backend, "GetAPIKeyByHash", time.Now())
+	s.RLock()
+	defer s.RUnlock()
+	for _, v := range s.apiKeys {
+		if v.Hash == hash {
+			return v, nil
+		}
+	}
+	return domain.APIKey{}, domain.ErrNotFound
+}
+
+// RevokeAPIKey revokes a key of userID. Keys of other users and revoked keys
+// are not found.
+func (s *linkStorage) RevokeAPIKey(ctx context.Context, id int64, userID int32, at time.Time) error {
+	defer metrics.ObserveStorage(backend, "RevokeAPIKey", time.Now())
+	s.Lock()
+	defer s.Unlock()
+	key, ok := s.apiKeys[id]
+	if !ok || key.UserID != userID || key.RevokedAt != nil {
+		return domain.ErrNotFound
+	}
+	key.RevokedAt = &at
+	if err := s.appendRecords(apiKeyRecord(key)); err != nil {
+		return err
+	}
+	s.putAPIKey(key)
+	s.maybeCompact()
+	return nil
+}
+
+func (s *linkStorage) putAPIKey(key domain.APIKey) {
+	if key.ID > s.seqKeyID {
+		s.seqKeyID = key.ID
+	}
+	s.addUser(key.UserID)
+	s.apiKeys[key.ID] = key
+}
@@ -0,0 +1,61 @@
+package hashmapstorage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_linkStorage_APIKeys(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "links.json")
+
+	storage := openStorage(t, path)
+	kept, err := storage.CreateAPIKey(ctx, domain.APIKey{UserID: 1, Name: "ci", Hash: "h1", Scopes: []string{domain.ScopeRead}})
+	require.NoError(t, err)
+	revoked, err := storage.CreateAPIKey(ctx, domain.APIKey{UserID: 1, Name: "old", Hash: "h2"})
+	require.NoError(t, err)
+	assert.Equal(t, kept.ID+1, revoked.ID)
+	assert.ErrorIs(t, storage.RevokeAPIKey(ctx, revoked.ID, 2, time.Now()), domain.ErrNotFound)
+	require.NoError(t, storage.RevokeAPIKey(ctx, revoked.ID, 1, time.Now()))
+	require.NoError(t, storage.Close())
+
+	storage = openStorage(t, path)
+	defer storage.Close()
+	keys, err := storage.GetAPIKeys(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	assert.Equal(t, kept.ID, keys[0].ID)
+
+	key, err := storage.GetAPIKeyByHash(ctx, "h1")
+	require.NoError(t, err)
+	assert.True(t, key.HasScope(domain.ScopeRead))
+	key, err = storage.GetAPIKeyByHash(ctx, "h2")
+	require.NoError(t, err)
+	assert.NotNil(t, key.RevokedAt)
+	_, err = storage.GetAPIKeyByHash(ctx, "h3")
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+}
+
+func Test_linkStorage_APIKeySeqAfterCompact(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "links.json")
+
+	storage := openStorage(t, path)
+	revoked, err := storage.CreateAPIKey(ctx, domain.APIKey{UserID: 1, Name: "old", Hash: "h1"})
+	require.NoError(t, err)
+	require.NoError(t, storage.RevokeAPIKey(ctx, revoked.ID, 1, time.Now()))
+	require.NoError(t, storage.compact())
+	require.NoError(t, storage.Close())
+
+	storage = openStorage(t, path)
+	defer storage.Close()
+	key, err := storage.CreateAPIKey(ctx, domain.APIKey{UserID: 1, Name: "new", Hash: "h2"})
+	require.NoError(t, err)
+	assert.Greater(t, key.ID, revoked.ID)
+}
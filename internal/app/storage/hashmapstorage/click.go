@@ -0,0 +1,190 @@
+package hashmapstorage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/domain"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/dto"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/metrics"
+)
+
+const dayLayout = "2006-01-02"
+
+type clickStorage struct {
+	sync.RWMutex
+	clickMap map[string][]domain.Click
+	record   bool
+	filePath string
+	file     *os.File
+	encoder  *json.Encoder
+}
+
+func NewClickStorage(filePath string) (*clickStorage, error) {
+	storage := &clickStorage{
+		clickMap: make(map[string][]domain.Click),
+		record:   filePath != "",
+		filePath: filePath,
+	}
+	if filePath != "" {
+		if err := storage.loadFromFile(); err != nil {
+			return &clickStorage{}, err
+		}
+	}
+	return storage, nil
+}
+
+func (s *clickStorage) CreateClicks(ctx context.Context, clicks []domain.Click) error {
+	defer metrics.ObserveStorage(backend, "CreateClicks", time.Now())
+	s.Lock()
+	defer s.Unlock()
+	if s.record {
+		for _, v := range clicks {
+			if err := s.encoder.Encode(&v); err != nil {
+				return err
+			}
+		}
+	}
+	for _, v := range clicks {
+		s.clickMap[v.Ident] = append(s.clickMap[v.Ident], v)
+	}
+	return nil
+}
+
+func (s *clickStorage) GetStatsByIdent(ctx context.Context, ident string, topReferrers int) (dto.LinkStatsRes, error) {
+	defer metrics.ObserveStorage(backend, "GetStatsByIdent", time.Now())
+	s.RLock()
+	defer s.RUnlock()
+	clicks := s.clickMap[ident]
+	perDay := make(map[string]int64)
+	perReferrer := make(map[string]int64)
+	for _, v := range clicks {
+		perDay[v.ClickedAt.UTC().Format(dayLayout)]++
+		if v.Referrer != "" {
+			perReferrer[v.Referrer]++
+		}
+	}
+
+	stats := dto.LinkStatsRes{
+		TotalClicks:  int64(len(clicks)),
+		ClicksPerDay: make([]dto.ClicksPerDay, 0, len(perDay)),
+		TopReferrers: make([]dto.ReferrerClicks, 0, len(perReferrer)),
+	}
+	for day, count := range perDay {
+		stats.ClicksPerDay = append(stats.ClicksPerDay, dto.ClicksPerDay{Day: day, Clicks: count})
+	}
+	sort.Slice(stats.ClicksPerDay, func(i, j int) bool {
+		return stats.ClicksPerDay[i].Day < stats.ClicksPerDay[j].Day
+	})
+	for referrer, count := range perReferrer {
+		stats.TopReferrers = append(stats.TopReferrers, dto.ReferrerClicks{Referrer: referrer, Clicks: count})
+	}
+	sort.Slice(stats.TopReferrers, func(i, j int) bool {
+		if stats.TopReferrers[i].Clicks != stats.TopReferrers[j].Clicks {
+			return stats.TopReferrers[i].Clicks > stats.TopReferrers[j].Clicks
+		}
+		return stats.TopReferrers[i].Referrer < stats.TopReferrers[j].Referrer
+	})
+	if len(stats.TopReferrers) > topReferrers {
+		stats.TopReferrers = stats.TopReferrers[:topReferrers]
+	}
+	return stats, nil
+}
+
+// DeleteByIdents drops the clicks of idents. The click file has no delete
+// records, so it is rewritten without them.
+func (s *clickStorage) DeleteByIdents(ctx context.Context, idents ...string) error {
+	defer metrics.ObserveStorage(backend, "DeleteClicks", time.Now())
+	s.Lock()
+	defer s.Unlock()
+	var removed bool
+	for _, v := range idents {
+		if _, ok := s.clickMap[v]; ok {
+			delete(s.clickMap, v)
+			removed = true
+		}
+	}
+	if !removed || !s.record {
+		return nil
+	}
+	return s.rewrite()
+}
+
+func (s *clickStorage) rewrite() error {
+	tmpPath := s.filePath + compactSuffix
+	tmp, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpPath)
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, clicks := range s.clickMap {
+		for _, v := range clicks {
+			if err := encoder.Encode(&v); err != nil {
+				tmp.Close()
+				return err
+			}
+		}
+	}
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, s.filePath); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(s.filePath, os.O_RDWR|os.O_APPEND, 0666)
+	if err != nil {
+		return err
+	}
+	s.file.Close()
+	s.file = file
+	s.encoder = json.NewEncoder(file)
+	return nil
+}
+
+func (s *clickStorage) loadFromFile() error {
+	var err error
+	s.file, err = os.OpenFile(s.filePath, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0666)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(s.file)
+	s.encoder = json.NewEncoder(s.file)
+
+	for {
+		var click domain.Click
+		err = decoder.Decode(&click)
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return err
+		}
+		s.clickMap[click.Ident] = append(s.clickMap[click.Ident], click)
+	}
+	return nil
+}
+
+func (s *clickStorage) Close() error {
+	if s.file == nil {
+		return nil
+	}
+	return s.file.Close()
+}
@@ -0,0 +1,119 @@
+package hashmapstorage
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/domain"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/metrics"
+)
+
+// finishedJobRetention is how long done and dead jobs are kept for status
+// requests. Older ones are dropped on compaction, so they do not come back
+// after a restart once DeleteFinishedJobs has dropped them.
+const finishedJobRetention = 7 * 24 * time.Hour
+
+func jobRecord(job domain.DeleteJob) logRecord {
+	return logRecord{Op: opJob, Job: &job}
+}
+
+func (s *linkStorage) CreateDeleteJob(ctx context.Context, job domain.DeleteJob) (domain.DeleteJob, error) {
+	defer metrics.ObserveStorage(backend, "CreateDeleteJob", time.Now())
+	s.Lock()
+	defer s.Unlock()
+	job.ID = s.seqJobID + 1
+	if err := s.appendRecords(jobRecord(job)); err != nil {
+		return domain.DeleteJob{}, err
+	}
+	s.putJob(job)
+	s.maybeCompact()
+	return job, nil
+}
+
+func (s *linkStorage) GetDeleteJob(ctx context.Context, id int64) (domain.DeleteJob, error) {
+	defer metrics.ObserveStorage(backend, "GetDeleteJob", time.Now())
+	s.RLock()
+	defer s.RUnlock()
+	job, ok := s.jobs[id]
+	if !ok {
+		return domain.DeleteJob{}, domain.ErrNotFound
+	}
+	return job, nil
+}
+
+// ClaimDeleteJobs postpones the claimed jobs in memory only: the file has a
+// single writer, and after a restart the jobs are due again as they should be.
+func (s *linkStorage) ClaimDeleteJobs(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]domain.DeleteJob, error) {
+	defer metrics.ObserveStorage(backend, "ClaimDeleteJobs", time.Now())
+	s.Lock()
+	defer s.Unlock()
+	var due []domain.DeleteJob
+	for _, job := range s.jobs {
+		if job.Status == domain.DeleteJobPending && !job.NextAttemptAt.After(now) {
+			due = append(due, job)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].ID < due[j].ID })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	for _, job := range due {
+		job.NextAttemptAt = now.Add(lease)
+		s.jobs[job.ID] = job
+	}
+	return due, nil
+}
+
+// DeleteFinishedJobs forgets done and dead jobs last updated before before.
+func (s *linkStorage) DeleteFinishedJobs(ctx context.Context, before time.Time) error {
+	defer metrics.ObserveStorage(backend, "DeleteFinishedJobs", time.Now())
+	s.Lock()
+	defer s.Unlock()
+	s.dropFinishedJobs(before)
+	return nil
+}
+
+func (s *linkStorage) CountPendingJobs(ctx context.Context) (int, error) {
+	defer metrics.ObserveStorage(backend, "CountPendingJobs", time.Now())
+	s.RLock()
+	defer s.RUnlock()
+	n := 0
+	for _, job := range s.jobs {
+		if job.Status == domain.DeleteJobPending {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (s *linkStorage) UpdateDeleteJob(ctx context.Context, job domain.DeleteJob) error {
+	defer metrics.ObserveStorage(backend, "UpdateDeleteJob", time.Now())
+	s.Lock()
+	defer s.Unlock()
+	if _, ok := s.jobs[job.ID]; !ok {
+		return domain.ErrNotFound
+	}
+	if err := s.appendRecords(jobRecord(job)); err != nil {
+		return err
+	}
+	s.putJob(job)
+	s.maybeCompact()
+	return nil
+}
+
+func (s *linkStorage) putJob(job domain.DeleteJob) {
+	if job.ID > s.seqJobID {
+		s.seqJobID = job.ID
+	}
+	s.jobs[job.ID] = job
+}
+
+// dropFinishedJobs forgets done and dead jobs last updated before before.
+func (s *linkStorage) dropFinishedJobs(before time.Time) {
+	for id, job := range s.jobs {
+		if job.Status != domain.DeleteJobPending && job.UpdatedAt.Before(before) {
+			delete(s.jobs, id)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package hashmapstorage
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/domain"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/dto"
+)
+
+// userIndex keeps a user's idents ordered by link ID, which is creation
+// order, and by ident, so a page is found by binary search on the cursor.
+type userIndex struct {
+	byID    []string
+	byIdent []string
+}
+
+func (s *linkStorage) indexLink(link domain.Link) {
+	idx, ok := s.index[link.UserID]
+	if !ok {
+		idx = &userIndex{}
+		s.index[link.UserID] = idx
+	}
+	i := sort.Search(len(idx.byID), func(i int) bool {
+		return s.linkMap[idx.byID[i]].ID > link.ID
+	})
+	idx.byID = insertAt(idx.byID, i, link.Ident)
+	i = sort.SearchStrings(idx.byIdent, link.Ident)
+	idx.byIdent = insertAt(idx.byIdent, i, link.Ident)
+}
+
+// unindexLink removes link from its user's index. It must run while the link
+// is still in linkMap, which the search by ID reads.
+func (s *linkStorage) unindexLink(link domain.Link) {
+	idx, ok := s.index[link.UserID]
+	if !ok {
+		return
+	}
+	i := sort.Search(len(idx.byID), func(i int) bool {
+		return s.linkMap[idx.byID[i]].ID >= link.ID
+	})
+	idx.byID = removeAt(idx.byID, i, link.Ident)
+	i = sort.SearchStrings(idx.byIdent, link.Ident)
+	idx.byIdent = removeAt(idx.byIdent, i, link.Ident)
+	if len(idx.byID) == 0 {
+		delete(s.index, link.UserID)
+	}
+}
+
+// indexURL records link as its user's live link to its URL. Deleted links
+// are not indexed.
+func (s *linkStorage) indexURL(link domain.Link) {
+	if link.DeletedFlag {
+		return
+	}
+	users, ok := s.urls[link.FulLink]
+	if !ok {
+		users = make(map[int32]string)
+		s.urls[link.FulLink] = users
+	}
+	users[link.UserID] = link.Ident
+}
+
+// unindexURL drops link from the URL index unless another link holds its
+// place there.
+func (s *linkStorage) unindexURL(link domain.Link) {
+	users, ok := s.urls[link.FulLink]
+	if !ok || users[link.UserID] != link.Ident {
+		return
+	}
+	delete(users, link.UserID)
+	if len(users) == 0 {
+		delete(s.urls, link.FulLink)
+	}
+}
+
+func (s *linkStorage) pageLinks(userID int32, query dto.LinkPageQuery) []domain.Link {
+	idx, ok := s.index[userID]
+	if !ok {
+		return nil
+	}
+
+	keys := idx.byIdent
+	cmp := func(i int) int { return strings.Compare(keys[i], query.AfterIdent) }
+	hasCursor := query.AfterIdent != ""
+	if query.SortBy != dto.SortIdent {
+		keys = idx.byID
+		cmp = func(i int) int {
+			id := s.linkMap[keys[i]].ID
+			switch {
+			case id < query.AfterID:
+				return -1
+			case id > query.AfterID:
+				return 1
+			}
+			return 0
+		}
+		hasCursor = query.AfterID != 0
+	}
+
+	i, step := 0, 1
+	if query.Desc {
+		i, step = len(keys)-1, -1
+	}
+	if hasCursor && query.Desc {
+		i = sort.Search(len(keys), func(i int) bool { return cmp(i) >= 0 }) - 1
+	} else if hasCursor {
+		i = sort.Search(len(keys), func(i int) bool { return cmp(i) > 0 })
+	}
+
+	search := strings.ToLower(query.Search)
+	var links []domain.Link
+	for ; i >= 0 && i < len(keys) && len(links) < query.Limit; i += step {
+		link := s.linkMap[keys[i]]
+		if link.DeletedFlag && !query.IncludeDeleted {
+			continue
+		}
+		if search != "" && !strings.Contains(strings.ToLower(link.FulLink), search) {
+			continue
+		}
+		links = append(links, link)
+	}
+	return links
+}
+
+func insertAt(keys []string, i int, key string) []string {
+	keys = append(keys, "")
+	copy(keys[i+1:], keys[i:])
+	keys[i] = key
+	return keys
+}
+
+func removeAt(keys []string, i int, key string) []string {
+	if i >= len(keys) || keys[i] != key {
+		return keys
+	}
+	return append(keys[:i], keys[i+1:]...)
+}
@@ -2,34 +2,59 @@ package hashmapstorage
 
 import (
 	"context"
-	"encoding/json"
-	"errors"
-	"io"
 	"os"
 	"sync"
+	"time"
 
 	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/domain"
 	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/dto"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/metrics"
 )
 
+const backend = "hashmap"
+
 type linkStorage struct {
 	sync.RWMutex
-	linkMap   map[string]domain.Link
-	record    bool
-	filePath  string
-	file      *os.File
-	decoder   *json.Decoder
-	encoder   *json.Encoder
-	seqUserID int32
+	linkMap          map[string]domain.Link
+	record           bool
+	filePath         string
+	file             *os.File
+	logSize          int64
+	compactAt        int64
+	compactThreshold int64
+	seqLinkID        int32
+	seqUserID        int32
+	userIDMark       int32
+	users            map[int32]domain.User
+	index            map[int32]*userIndex
+	urls             map[string]map[int32]string
+	history          map[string][]domain.LinkChange
+	jobs             map[int64]domain.DeleteJob
+	seqJobID         int64
+	apiKeys          map[int64]domain.APIKey
+	seqKeyID         int64
+	clicks           *clickStorage
+	loadedAt         time.Time
+	legacyDeletes    bool
 }
 
 func NewLinkStorage(linkMap map[string]domain.Link, filePath string) (*linkStorage, error) {
 
 	storage := &linkStorage{
-		linkMap:   linkMap,
-		record:    filePath != "",
-		filePath:  filePath,
-		seqUserID: 1,
+		linkMap:          make(map[string]domain.Link, len(linkMap)),
+		record:           filePath != "",
+		filePath:         filePath,
+		compactThreshold: defaultCompactThreshold,
+		seqUserID:        1,
+		users:            make(map[int32]domain.User),
+		index:            make(map[int32]*userIndex),
+		urls:             make(map[string]map[int32]string),
+		history:          make(map[string][]domain.LinkChange),
+		jobs:             make(map[int64]domain.DeleteJob),
+		apiKeys:          make(map[int64]domain.APIKey),
+	}
+	for _, v := range linkMap {
+		storage.putLink(v)
 	}
 	if filePath != "" {
 		if err := storage.loadFromFile(); err != nil {
@@ -40,85 +65,241 @@ func NewLinkStorage(linkMap map[string]domain.Link, filePath string) (*linkStora
 }
 
 func (s *linkStorage) GetOneByIdent(ctx context.Context, ident string) (domain.Link, error) {
+	defer metrics.ObserveStorage(backend, "GetOneByIdent", time.Now())
 	s.RLock()
 	defer s.RUnlock()
 	link, ok := s.linkMap[ident]
 	if !ok {
 		link = domain.Link{}
-		return link, errors.New("not found")
+		return link, domain.ErrNotFound
 	}
 	return link, nil
 }
 
-func (s *linkStorage) Create(ctx context.Context, ident, fulLink string, userID int32) (domain.Link, error) {
+func (s *linkStorage) Create(ctx context.Context, link domain.Link) (domain.Link, error) {
+	defer metrics.ObserveStorage(backend, "Create", time.Now())
 	s.Lock()
 	defer s.Unlock()
-	if s.seqUserID < userID {
-		s.seqUserID = userID
+	if _, ok := s.linkMap[link.Ident]; ok {
+		return domain.Link{}, domain.ErrAliasTaken
+	}
+	if existing, ok := s.findLive(link.FulLink, link.UserID); ok {
+		return existing, domain.ErrConflict
 	}
-	link := domain.Link{
-		Ident:   ident,
-		FulLink: fulLink,
-		UserID:  userID,
+	link.ID = s.nextLinkID()
+	now := time.Now()
+	link.CreatedAt = &now
+	if err := s.appendRecords(createRecord(link)); err != nil {
+		return domain.Link{}, err
 	}
-	if s.record {
-		if err := s.encoder.Encode(&link); err != nil {
-			return domain.Link{}, err
+	s.putLink(link)
+	s.maybeCompact()
+	return link, nil
+}
+
+// GetByOriginalURL returns the oldest live link to url of any user.
+func (s *linkStorage) GetByOriginalURL(ctx context.Context, url string) (domain.Link, error) {
+	defer metrics.ObserveStorage(backend, "GetByOriginalURL", time.Now())
+	s.RLock()
+	defer s.RUnlock()
+	var oldest domain.Link
+	found := false
+	for _, ident := range s.urls[url] {
+		link := s.linkMap[ident]
+		if !found || link.ID < oldest.ID {
+			oldest, found = link, true
 		}
 	}
-	s.linkMap[ident] = link
+	if !found {
+		return domain.Link{}, domain.ErrNotFound
+	}
+	return oldest, nil
+}
+
+// GetUserLinkByURL returns the live link of userID to url.
+func (s *linkStorage) GetUserLinkByURL(ctx context.Context, userID int32, url string) (domain.Link, error) {
+	defer metrics.ObserveStorage(backend, "GetUserLinkByURL", time.Now())
+	s.RLock()
+	defer s.RUnlock()
+	link, ok := s.findLive(url, userID)
+	if !ok {
+		return domain.Link{}, domain.ErrNotFound
+	}
 	return link, nil
 }
 
 func (s *linkStorage) CreateLinks(ctx context.Context, links []domain.Link, userID int32) error {
+	defer metrics.ObserveStorage(backend, "CreateLinks", time.Now())
 	s.Lock()
 	defer s.Unlock()
-	if s.seqUserID < userID {
-		s.seqUserID = userID
-	}
-	if s.record {
-		for _, v := range links {
-			if err := s.encoder.Encode(&v); err != nil {
-				return err
-			}
+	idents := make(map[string]bool, len(links))
+	urls := make(map[string]bool, len(links))
+	for _, v := range links {
+		if _, ok := s.linkMap[v.Ident]; ok || idents[v.Ident] {
+			return domain.ErrAliasTaken
+		}
+		idents[v.Ident] = true
+		if _, ok := s.findLive(v.FulLink, userID); ok || urls[v.FulLink] {
+			return domain.ErrConflict
 		}
+		urls[v.FulLink] = true
+	}
+	records := make([]logRecord, 0, len(links))
+	now := time.Now()
+	for i := range links {
+		links[i].ID = s.nextLinkID()
+		links[i].UserID = userID
+		links[i].CreatedAt = &now
+		records = append(records, createRecord(links[i]))
+	}
+	if err := s.appendRecords(records...); err != nil {
+		return err
 	}
 	for _, v := range links {
-		v.UserID = userID
-		s.linkMap[v.Ident] = v
+		s.putLink(v)
 	}
+	s.maybeCompact()
 	return nil
 }
 
-func (s *linkStorage) GetLinksByUserID(ctx context.Context, userID int32) ([]dto.LinkListByUserIDRes, error) {
+func (s *linkStorage) GetLinksByUserID(ctx context.Context, userID int32, query dto.LinkPageQuery) ([]domain.Link, error) {
+	defer metrics.ObserveStorage(backend, "GetLinksByUserID", time.Now())
 	s.RLock()
 	defer s.RUnlock()
-	var linkListByUserIDRes []dto.LinkListByUserIDRes
-	for _, v := range s.linkMap {
-		if v.UserID == userID && !v.DeletedFlag {
-			linkListByUserIDRes = append(linkListByUserIDRes, dto.LinkListByUserIDRes{
-				OriginalURL: v.FulLink,
-				ShortURL:    v.Ident,
-			})
+	return s.pageLinks(userID, query), nil
+}
+
+// DeleteByIdents marks the links among idents that belong to userID deleted.
+func (s *linkStorage) DeleteByIdents(ctx context.Context, userID int32, idents ...string) error {
+	defer metrics.ObserveStorage(backend, "DeleteByIdents", time.Now())
+	s.Lock()
+	defer s.Unlock()
+	var deleted []string
+	for _, v := range idents {
+		link, ok := s.linkMap[v]
+		if ok && link.UserID == userID && !link.DeletedFlag {
+			deleted = append(deleted, v)
+		}
+	}
+	return s.deleteIdents(deleted)
+}
+
+func (s *linkStorage) DeleteExpired(ctx context.Context, now time.Time) error {
+	defer metrics.ObserveStorage(backend, "DeleteExpired", time.Now())
+	s.Lock()
+	defer s.Unlock()
+	var expired []string
+	for k, link := range s.linkMap {
+		if !link.DeletedFlag && link.IsExpired(now) {
+			expired = append(expired, k)
 		}
 	}
-	return linkListByUserIDRes, nil
+	return s.deleteIdents(expired)
 }
 
-func (s *linkStorage) DeleteByIdents(ctx context.Context, idents ...string) error {
+// Restore undeletes the links among idents deleted after deletedAfter and
+// returns their idents. Links deleted earlier, and links whose user has since
+// shortened the same URL again, are left deleted.
+func (s *linkStorage) Restore(ctx context.Context, deletedAfter time.Time, idents ...string) ([]string, error) {
+	defer metrics.ObserveStorage(backend, "Restore", time.Now())
 	s.Lock()
 	defer s.Unlock()
+	var restored []string
+	type userURL struct {
+		userID int32
+		url    string
+	}
+	urls := make(map[userURL]bool, len(idents))
 	for _, v := range idents {
 		link, ok := s.linkMap[v]
-		if ok && !link.DeletedFlag {
-			link.DeletedFlag = true
-			s.linkMap[v] = link
+		if !ok || !link.DeletedFlag || link.DeletedAt == nil || !link.DeletedAt.After(deletedAfter) {
+			continue
+		}
+		key := userURL{userID: link.UserID, url: link.FulLink}
+		if _, live := s.findLive(link.FulLink, link.UserID); live || urls[key] {
+			continue
 		}
+		urls[key] = true
+		restored = append(restored, v)
 	}
-	return nil
+	if len(restored) == 0 {
+		return nil, nil
+	}
+	if err := s.appendRecords(logRecord{Op: opRestore, Idents: restored}); err != nil {
+		return nil, err
+	}
+	s.markRestored(restored)
+	s.maybeCompact()
+	return restored, nil
+}
+
+// SetClickStorage makes Purge drop the clicks of purged links from clicks.
+func (s *linkStorage) SetClickStorage(clicks *clickStorage) {
+	s.Lock()
+	defer s.Unlock()
+	s.clicks = clicks
+}
+
+// Purge removes links deleted at or before before together with their
+// history and clicks, and returns their idents. Clicks go first, so a failed
+// purge is retried as a whole.
+func (s *linkStorage) Purge(ctx context.Context, before time.Time) ([]string, error) {
+	defer metrics.ObserveStorage(backend, "Purge", time.Now())
+	s.Lock()
+	defer s.Unlock()
+	var purged []string
+	for k, link := range s.linkMap {
+		if link.DeletedFlag && link.DeletedAt != nil && !link.DeletedAt.After(before) {
+			purged = append(purged, k)
+		}
+	}
+	if len(purged) == 0 {
+		return nil, nil
+	}
+	if s.clicks != nil {
+		if err := s.clicks.DeleteByIdents(ctx, purged...); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.appendRecords(logRecord{Op: opPurge, Idents: purged}); err != nil {
+		return nil, err
+	}
+	s.removeLinks(purged)
+	s.maybeCompact()
+	return purged, nil
+}
+
+// ClaimLinks moves the links of fromUserID to toUserID and returns how many
+// moved. Live links to URLs toUserID already has stay where they are.
+func (s *linkStorage) ClaimLinks(ctx context.Context, fromUserID, toUserID int32) (int, error) {
+	defer metrics.ObserveStorage(backend, "ClaimLinks", time.Now())
+	s.Lock()
+	defer s.Unlock()
+	idx, ok := s.index[fromUserID]
+	if !ok {
+		return 0, nil
+	}
+	var claimed []string
+	for _, v := range idx.byID {
+		link := s.linkMap[v]
+		if _, live := s.findLive(link.FulLink, toUserID); live && !link.DeletedFlag {
+			continue
+		}
+		claimed = append(claimed, v)
+	}
+	if len(claimed) == 0 {
+		return 0, nil
+	}
+	if err := s.appendRecords(logRecord{Op: opClaim, Idents: claimed, UserID: toUserID}); err != nil {
+		return 0, err
+	}
+	s.moveLinks(claimed, toUserID)
+	s.maybeCompact()
+	return len(claimed), nil
 }
 
 func (s *linkStorage) GetByIdents(ctx context.Context, idents ...string) ([]domain.Link, error) {
+	defer metrics.ObserveStorage(backend, "GetByIdents", time.Now())
 	s.RLock()
 	defer s.RUnlock()
 	var links []domain.Link
@@ -131,42 +312,225 @@ func (s *linkStorage) GetByIdents(ctx context.Context, idents ...string) ([]doma
 	return links, nil
 }
 
-func (s *linkStorage) loadFromFile() error {
-	var err error
-	s.file, err = os.OpenFile(s.filePath, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0666)
-	if err != nil {
-		return err
+// Update changes the destination and expiry of a live link of link.UserID. A
+// link deleted or handed to another user since it was read is not found.
+func (s *linkStorage) Update(ctx context.Context, link domain.Link) (domain.Link, error) {
+	defer metrics.ObserveStorage(backend, "Update", time.Now())
+	s.Lock()
+	defer s.Unlock()
+	updated, ok := s.linkMap[link.Ident]
+	if !ok || updated.UserID != link.UserID || updated.DeletedFlag {
+		return domain.Link{}, domain.ErrNotFound
+	}
+	if existing, ok := s.findLive(link.FulLink, updated.UserID); ok && existing.Ident != link.Ident {
+		return domain.Link{}, domain.ErrConflict
 	}
-	s.decoder = json.NewDecoder(s.file)
-	s.encoder = json.NewEncoder(s.file)
+	updated.FulLink = link.FulLink
+	updated.ExpiresAt = link.ExpiresAt
 
-	var link domain.Link
-	for {
-		err = s.decoder.Decode(&link)
-		if err != nil {
-			if err == io.EOF {
-				break
-			}
-			return err
-		}
-		if s.seqUserID < link.UserID {
-			s.seqUserID = link.UserID
-		}
-		s.linkMap[link.Ident] = link
+	now := time.Now()
+	if err := s.appendRecords(logRecord{Op: opUpdate, Link: &updated, At: &now}); err != nil {
+		return domain.Link{}, err
 	}
-	return nil
+	s.applyUpdate(updated, now)
+	s.maybeCompact()
+	return updated, nil
+}
+
+// GetHistory returns the prior destinations of a link, newest first.
+func (s *linkStorage) GetHistory(ctx context.Context, ident string) ([]domain.LinkChange, error) {
+	defer metrics.ObserveStorage(backend, "GetHistory", time.Now())
+	s.RLock()
+	defer s.RUnlock()
+	history := s.history[ident]
+	result := make([]domain.LinkChange, 0, len(history))
+	for i := len(history) - 1; i >= 0; i-- {
+		result = append(result, history[i])
+	}
+	return result, nil
+}
+
+// NextIdentSeq shares the link ID sequence, which is restored from the log on
+// start, so numbers are not handed out twice across restarts. Compaction logs
+// the sequence, so purged links do not rewind it.
+func (s *linkStorage) NextIdentSeq(ctx context.Context) (int64, error) {
+	defer metrics.ObserveStorage(backend, "NextIdentSeq", time.Now())
+	s.Lock()
+	defer s.Unlock()
+	return int64(s.nextLinkID()), nil
 }
 
 func (s *linkStorage) Close() error {
+	s.Lock()
+	defer s.Unlock()
 	if s.file == nil {
 		return nil
 	}
 	return s.file.Close()
 }
 
+// CreateUser hands out the next user ID. IDs are reserved in the log a block
+// at a time, so they are not reused after a restart even though anonymous
+// users are not logged.
 func (s *linkStorage) CreateUser(ctx context.Context) (int32, error) {
+	defer metrics.ObserveStorage(backend, "CreateUser", time.Now())
 	s.Lock()
 	defer s.Unlock()
+	if s.seqUserID >= s.userIDMark {
+		mark := s.seqUserID + userIDBlock
+		if err := s.appendRecords(s.seqRecord(mark)); err != nil {
+			return 0, err
+		}
+		s.userIDMark = mark
+	}
 	s.seqUserID++
+	s.users[s.seqUserID] = domain.User{ID: s.seqUserID}
+	s.maybeCompact()
 	return s.seqUserID, nil
 }
+
+func (s *linkStorage) CountLinks(ctx context.Context) (int, error) {
+	defer metrics.ObserveStorage(backend, "CountLinks", time.Now())
+	s.RLock()
+	defer s.RUnlock()
+	var count int
+	for _, v := range s.linkMap {
+		if !v.DeletedFlag {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *linkStorage) CountUserLinks(ctx context.Context, userID int32) (int, error) {
+	defer metrics.ObserveStorage(backend, "CountUserLinks", time.Now())
+	s.RLock()
+	defer s.RUnlock()
+	var count int
+	if idx, ok := s.index[userID]; ok {
+		for _, ident := range idx.byID {
+			if !s.linkMap[ident].DeletedFlag {
+				count++
+			}
+		}
+	}
+	return count, nil
+}
+
+func (s *linkStorage) CountUsers(ctx context.Context) (int, error) {
+	defer metrics.ObserveStorage(backend, "CountUsers", time.Now())
+	s.RLock()
+	defer s.RUnlock()
+	return len(s.users), nil
+}
+
+func (s *linkStorage) addUser(userID int32) {
+	if userID <= 0 {
+		return
+	}
+	if s.seqUserID < userID {
+		s.seqUserID = userID
+	}
+	if _, ok := s.users[userID]; !ok {
+		s.users[userID] = domain.User{ID: userID}
+	}
+}
+
+func (s *linkStorage) deleteIdents(idents []string) error {
+	if len(idents) == 0 {
+		return nil
+	}
+	now := time.Now()
+	if err := s.appendRecords(logRecord{Op: opDelete, Idents: idents, At: &now}); err != nil {
+		return err
+	}
+	s.markDeleted(idents, &now)
+	s.maybeCompact()
+	return nil
+}
+
+func (s *linkStorage) putLink(link domain.Link) {
+	if link.ID == 0 {
+		link.ID = s.nextLinkID()
+	} else if link.ID > s.seqLinkID {
+		s.seqLinkID = link.ID
+	}
+	s.addUser(link.UserID)
+	old, exists := s.linkMap[link.Ident]
+	if exists {
+		s.unindexURL(old)
+	}
+	if exists && old.UserID != link.UserID {
+		s.unindexLink(old)
+		exists = false
+	}
+	s.linkMap[link.Ident] = link
+	if !exists {
+		s.indexLink(link)
+	}
+	s.indexURL(link)
+}
+
+func (s *linkStorage) applyUpdate(link domain.Link, at time.Time) {
+	if old, ok := s.linkMap[link.Ident]; ok && old.FulLink != link.FulLink {
+		s.history[link.Ident] = append(s.history[link.Ident], domain.LinkChange{OriginalURL: old.FulLink, ChangedAt: at})
+	}
+	s.putLink(link)
+}
+
+func (s *linkStorage) nextLinkID() int32 {
+	s.seqLinkID++
+	return s.seqLinkID
+}
+
+// markDeleted flags links as deleted at.
+func (s *linkStorage) markDeleted(idents []string, at *time.Time) {
+	for _, v := range idents {
+		if link, ok := s.linkMap[v]; ok {
+			s.unindexURL(link)
+			link.DeletedFlag = true
+			link.DeletedAt = at
+			s.linkMap[v] = link
+		}
+	}
+}
+
+func (s *linkStorage) markRestored(idents []string) {
+	for _, v := range idents {
+		if link, ok := s.linkMap[v]; ok {
+			link.DeletedFlag = false
+			link.DeletedAt = nil
+			s.linkMap[v] = link
+			s.indexURL(link)
+		}
+	}
+}
+
+func (s *linkStorage) removeLinks(idents []string) {
+	for _, v := range idents {
+		if link, ok := s.linkMap[v]; ok {
+			s.unindexURL(link)
+			s.unindexLink(link)
+			delete(s.linkMap, v)
+			delete(s.history, v)
+		}
+	}
+}
+
+func (s *linkStorage) moveLinks(idents []string, userID int32) {
+	for _, v := range idents {
+		if link, ok := s.linkMap[v]; ok {
+			link.UserID = userID
+			s.putLink(link)
+		}
+	}
+}
+
+// findLive returns the user's link to url that is not deleted.
+func (s *linkStorage) findLive(url string, userID int32) (domain.Link, bool) {
+	ident, ok := s.urls[url][userID]
+	if !ok {
+		return domain.Link{}, false
+	}
+	return s.linkMap[ident], true
+}
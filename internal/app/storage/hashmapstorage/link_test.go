@@ -0,0 +1,455 @@
+package hashmapstorage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/domain"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/dto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func openStorage(t *testing.T, path string) *linkStorage {
+	t.Helper()
+	storage, err := NewLinkStorage(make(map[string]domain.Link), path)
+	require.NoError(t, err)
+	return storage
+}
+
+func Test_linkStorage_ReplayLog(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "links.json")
+	past := time.Now().Add(-time.Hour)
+
+	storage := openStorage(t, path)
+	_, err := storage.Create(ctx, domain.Link{Ident: "a", FulLink: "https://a.example", UserID: 1})
+	require.NoError(t, err)
+	require.NoError(t, storage.CreateLinks(ctx, []domain.Link{
+		{Ident: "b", FulLink: "https://b.example"},
+		{Ident: "c", FulLink: "https://c.example", ExpiresAt: &past},
+	}, 2))
+	require.NoError(t, storage.DeleteByIdents(ctx, 1, "a"))
+	require.NoError(t, storage.DeleteExpired(ctx, time.Now()))
+	require.NoError(t, storage.Close())
+
+	storage = openStorage(t, path)
+	defer storage.Close()
+
+	links, err := storage.GetByIdents(ctx, "a", "b", "c")
+	require.NoError(t, err)
+	deleted := make(map[string]bool)
+	for _, v := range links {
+		deleted[v.Ident] = v.DeletedFlag
+	}
+	assert.Equal(t, map[string]bool{"a": true, "b": false, "c": true}, deleted)
+
+	count, err := storage.CountLinks(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	userID, err := storage.CreateUser(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), userID)
+}
+
+func Test_linkStorage_LegacyAndTruncatedRecords(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "links.json")
+	data := `{"uuid":1,"short_url":"old","original_url":"https://old.example","user_id":1,"is_deleted":false}
+{"op":"delete","idents":["old"]}
+{"op":"create","link":{"uuid":0,"short_url":"new","original_url":"https://new.exa`
+	require.NoError(t, os.WriteFile(path, []byte(data), 0666))
+
+	storage := openStorage(t, path)
+	link, err := storage.GetOneByIdent(ctx, "old")
+	require.NoError(t, err)
+	assert.True(t, link.DeletedFlag)
+	require.NotNil(t, link.DeletedAt)
+	deletedAt := *link.DeletedAt
+	purged, err := storage.Purge(ctx, deletedAt.Add(-time.Second))
+	require.NoError(t, err)
+	assert.Empty(t, purged)
+	_, err = storage.GetOneByIdent(ctx, "new")
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+
+	_, err = storage.Create(ctx, domain.Link{Ident: "next", FulLink: "https://next.example", UserID: 1})
+	require.NoError(t, err)
+	require.NoError(t, storage.Close())
+
+	storage = openStorage(t, path)
+	defer storage.Close()
+	_, err = storage.GetOneByIdent(ctx, "next")
+	assert.NoError(t, err)
+	link, err = storage.GetOneByIdent(ctx, "old")
+	require.NoError(t, err)
+	require.NotNil(t, link.DeletedAt)
+	assert.True(t, deletedAt.Equal(*link.DeletedAt))
+}
+
+func Test_linkStorage_Compact(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "links.json")
+
+	storage := openStorage(t, path)
+	storage.compactThreshold = 512
+	storage.setCompactAt()
+	for i := 0; i < 20; i++ {
+		ident := strings.Repeat("x", i+1)
+		_, err := storage.Create(ctx, domain.Link{Ident: ident, FulLink: "https://example.com", UserID: 1})
+		require.NoError(t, err)
+		require.NoError(t, storage.DeleteByIdents(ctx, 1, ident))
+	}
+	require.NoError(t, storage.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Less(t, strings.Count(string(data), `"op":"delete"`), 20)
+	_, err = os.Stat(path + compactSuffix)
+	assert.True(t, os.IsNotExist(err))
+
+	storage = openStorage(t, path)
+	defer storage.Close()
+	links, err := storage.GetByIdents(ctx, "x", strings.Repeat("x", 20))
+	require.NoError(t, err)
+	require.Len(t, links, 2)
+	for _, v := range links {
+		assert.True(t, v.DeletedFlag)
+	}
+}
+
+func Test_linkStorage_PerUserDedupe(t *testing.T) {
+	ctx := context.Background()
+	storage := openStorage(t, "")
+	url := "https://shared.example"
+
+	own, err := storage.Create(ctx, domain.Link{Ident: "a", FulLink: url, UserID: 1})
+	require.NoError(t, err)
+
+	link, err := storage.Create(ctx, domain.Link{Ident: "b", FulLink: url, UserID: 1})
+	assert.ErrorIs(t, err, domain.ErrConflict)
+	assert.Equal(t, own, link)
+
+	_, err = storage.Create(ctx, domain.Link{Ident: "c", FulLink: url, UserID: 2})
+	require.NoError(t, err)
+	err = storage.CreateLinks(ctx, []domain.Link{{Ident: "d", FulLink: url}}, 2)
+	assert.ErrorIs(t, err, domain.ErrConflict)
+
+	require.NoError(t, storage.DeleteByIdents(ctx, 2, "a"))
+	_, err = storage.Create(ctx, domain.Link{Ident: "e", FulLink: url, UserID: 1})
+	assert.ErrorIs(t, err, domain.ErrConflict)
+	require.NoError(t, storage.DeleteByIdents(ctx, 1, "a"))
+	_, err = storage.Create(ctx, domain.Link{Ident: "e", FulLink: url, UserID: 1})
+	assert.NoError(t, err)
+
+	link, err = storage.GetByOriginalURL(ctx, url)
+	require.NoError(t, err)
+	assert.False(t, link.DeletedFlag)
+}
+
+func Test_linkStorage_URLIndex(t *testing.T) {
+	ctx := context.Background()
+	storage := openStorage(t, "")
+	url := "https://indexed.example"
+
+	_, err := storage.Create(ctx, domain.Link{Ident: "a", FulLink: url, UserID: 1})
+	require.NoError(t, err)
+	_, err = storage.Update(ctx, domain.Link{Ident: "a", FulLink: "https://moved.example", UserID: 1})
+	require.NoError(t, err)
+	_, err = storage.GetByOriginalURL(ctx, url)
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+
+	_, err = storage.Update(ctx, domain.Link{Ident: "a", FulLink: url, UserID: 1})
+	require.NoError(t, err)
+	require.NoError(t, storage.DeleteByIdents(ctx, 1, "a"))
+	_, err = storage.GetByOriginalURL(ctx, url)
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+
+	restored, err := storage.Restore(ctx, time.Now().Add(-time.Hour), "a")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a"}, restored)
+	link, err := storage.GetByOriginalURL(ctx, url)
+	require.NoError(t, err)
+	assert.Equal(t, "a", link.Ident)
+
+	claimed, err := storage.ClaimLinks(ctx, 1, 2)
+	require.NoError(t, err)
+	assert.Equal(t, 1, claimed)
+	_, err = storage.Create(ctx, domain.Link{Ident: "b", FulLink: url, UserID: 2})
+	assert.ErrorIs(t, err, domain.ErrConflict)
+	_, err = storage.Create(ctx, domain.Link{Ident: "c", FulLink: url, UserID: 1})
+	assert.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		link, err = storage.GetByOriginalURL(ctx, url)
+		require.NoError(t, err)
+		assert.Equal(t, "a", link.Ident, "the oldest link wins")
+	}
+
+	require.NoError(t, storage.DeleteByIdents(ctx, 2, "a"))
+	require.NoError(t, storage.DeleteByIdents(ctx, 1, "a", "c"))
+	_, err = storage.Purge(ctx, time.Now())
+	require.NoError(t, err)
+	_, err = storage.GetByOriginalURL(ctx, url)
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+	assert.Empty(t, storage.urls)
+}
+
+func Test_linkStorage_GetLinksByUserID(t *testing.T) {
+	ctx := context.Background()
+	storage := openStorage(t, "")
+	for _, v := range []domain.Link{
+		{Ident: "c", FulLink: "https://example.com/c", UserID: 1},
+		{Ident: "a", FulLink: "https://EXAMPLE.com/a", UserID: 1},
+		{Ident: "d", FulLink: "https://other.com/d", UserID: 1},
+		{Ident: "b", FulLink: "https://example.com/b", UserID: 1},
+		{Ident: "e", FulLink: "https://example.com/e", UserID: 2},
+	} {
+		_, err := storage.Create(ctx, v)
+		require.NoError(t, err)
+	}
+	require.NoError(t, storage.DeleteByIdents(ctx, 1, "b"))
+
+	idents := func(query dto.LinkPageQuery) []string {
+		links, err := storage.GetLinksByUserID(ctx, 1, query)
+		require.NoError(t, err)
+		var result []string
+		for _, v := range links {
+			result = append(result, v.Ident)
+		}
+		return result
+	}
+
+	assert.Equal(t, []string{"c", "a", "d"}, idents(dto.LinkPageQuery{SortBy: dto.SortCreated, Limit: 10}))
+	assert.Equal(t, []string{"d", "a"}, idents(dto.LinkPageQuery{SortBy: dto.SortCreated, Desc: true, Limit: 2}))
+	assert.Equal(t, []string{"c"}, idents(dto.LinkPageQuery{SortBy: dto.SortCreated, Desc: true, AfterID: 2, Limit: 2}))
+	assert.Equal(t, []string{"c", "d"}, idents(dto.LinkPageQuery{SortBy: dto.SortIdent, AfterIdent: "a", Limit: 10}))
+	assert.Equal(t, []string{"c", "a"}, idents(dto.LinkPageQuery{SortBy: dto.SortIdent, Desc: true, Search: "example", Limit: 10}))
+}
+
+func Test_linkStorage_UpdateHistory(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "links.json")
+
+	storage := openStorage(t, path)
+	link, err := storage.Create(ctx, domain.Link{Ident: "a", FulLink: "https://one.example", UserID: 1})
+	require.NoError(t, err)
+	_, err = storage.Create(ctx, domain.Link{Ident: "b", FulLink: "https://taken.example", UserID: 1})
+	require.NoError(t, err)
+
+	link.FulLink = "https://taken.example"
+	_, err = storage.Update(ctx, link)
+	assert.ErrorIs(t, err, domain.ErrConflict)
+
+	for _, url := range []string{"https://two.example", "https://three.example"} {
+		link.FulLink = url
+		_, err = storage.Update(ctx, link)
+		require.NoError(t, err)
+	}
+	_, err = storage.Update(ctx, domain.Link{Ident: "missing", FulLink: "https://x.example"})
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+	_, err = storage.Update(ctx, domain.Link{Ident: "b", FulLink: "https://x.example", UserID: 2})
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+	require.NoError(t, storage.DeleteByIdents(ctx, 1, "b"))
+	_, err = storage.Update(ctx, domain.Link{Ident: "b", FulLink: "https://x.example", UserID: 1})
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+	require.NoError(t, storage.Close())
+
+	urls := func(storage *linkStorage) []string {
+		history, err := storage.GetHistory(ctx, "a")
+		require.NoError(t, err)
+		var result []string
+		for _, v := range history {
+			result = append(result, v.OriginalURL)
+		}
+		return result
+	}
+	want := []string{"https://two.example", "https://one.example"}
+
+	storage = openStorage(t, path)
+	got, err := storage.GetOneByIdent(ctx, "a")
+	require.NoError(t, err)
+	assert.Equal(t, "https://three.example", got.FulLink)
+	assert.Equal(t, want, urls(storage))
+
+	require.NoError(t, storage.compact())
+	require.NoError(t, storage.Close())
+	storage = openStorage(t, path)
+	defer storage.Close()
+	assert.Equal(t, want, urls(storage))
+}
+
+func Test_linkStorage_RestoreAndPurge(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "links.json")
+	url := "https://a.example"
+
+	storage := openStorage(t, path)
+	for _, v := range []domain.Link{
+		{Ident: "a", FulLink: url, UserID: 1},
+		{Ident: "b", FulLink: "https://b.example", UserID: 1},
+	} {
+		_, err := storage.Create(ctx, v)
+		require.NoError(t, err)
+	}
+	require.NoError(t, storage.DeleteByIdents(ctx, 1, "a", "b"))
+	_, err := storage.Create(ctx, domain.Link{Ident: "c", FulLink: url, UserID: 1})
+	require.NoError(t, err)
+
+	restored, err := storage.Restore(ctx, time.Now().Add(time.Hour), "b")
+	require.NoError(t, err)
+	assert.Empty(t, restored)
+	restored, err = storage.Restore(ctx, time.Now().Add(-time.Hour), "a", "b")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b"}, restored)
+	require.NoError(t, storage.Close())
+
+	storage = openStorage(t, path)
+	link, err := storage.GetOneByIdent(ctx, "b")
+	require.NoError(t, err)
+	assert.False(t, link.DeletedFlag)
+	assert.Nil(t, link.DeletedAt)
+	link, err = storage.GetOneByIdent(ctx, "a")
+	require.NoError(t, err)
+	assert.True(t, link.DeletedFlag)
+	require.NotNil(t, link.DeletedAt)
+
+	purged, err := storage.Purge(ctx, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a"}, purged)
+	require.NoError(t, storage.Close())
+
+	storage = openStorage(t, path)
+	defer storage.Close()
+	_, err = storage.GetOneByIdent(ctx, "a")
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+	links, err := storage.GetLinksByUserID(ctx, 1, dto.LinkPageQuery{SortBy: dto.SortIdent, Limit: 10})
+	require.NoError(t, err)
+	assert.Len(t, links, 2)
+}
+
+func Test_linkStorage_PurgeClicks(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	storage := openStorage(t, filepath.Join(dir, "links.json"))
+	defer storage.Close()
+	clicks, err := NewClickStorage(filepath.Join(dir, "clicks.json"))
+	require.NoError(t, err)
+	defer clicks.Close()
+	storage.SetClickStorage(clicks)
+
+	for _, v := range []string{"a", "b"} {
+		_, err := storage.Create(ctx, domain.Link{Ident: v, FulLink: "https://" + v + ".example", UserID: 1})
+		require.NoError(t, err)
+		require.NoError(t, clicks.CreateClicks(ctx, []domain.Click{{Ident: v, ClickedAt: time.Now()}}))
+	}
+	require.NoError(t, storage.DeleteByIdents(ctx, 1, "a"))
+	purged, err := storage.Purge(ctx, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a"}, purged)
+
+	stats, err := clicks.GetStatsByIdent(ctx, "a", 10)
+	require.NoError(t, err)
+	assert.Zero(t, stats.TotalClicks)
+	stats, err = clicks.GetStatsByIdent(ctx, "b", 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), stats.TotalClicks)
+}
+
+func Test_linkStorage_IdentSeqAfterPurge(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "links.json")
+
+	storage := openStorage(t, path)
+	link, err := storage.Create(ctx, domain.Link{Ident: "a", FulLink: "https://a.example", UserID: 1})
+	require.NoError(t, err)
+	require.NoError(t, storage.DeleteByIdents(ctx, 1, "a"))
+	_, err = storage.Purge(ctx, time.Now())
+	require.NoError(t, err)
+	require.NoError(t, storage.compact())
+	require.NoError(t, storage.Close())
+
+	storage = openStorage(t, path)
+	defer storage.Close()
+	seq, err := storage.NextIdentSeq(ctx)
+	require.NoError(t, err)
+	assert.Greater(t, seq, int64(link.ID))
+}
+
+func Test_linkStorage_DeleteJobs(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "links.json")
+	now := time.Now()
+
+	storage := openStorage(t, path)
+	first, err := storage.CreateDeleteJob(ctx, domain.DeleteJob{UserID: 1, Idents: []string{"a"}, Status: domain.DeleteJobPending, NextAttemptAt: now})
+	require.NoError(t, err)
+	second, err := storage.CreateDeleteJob(ctx, domain.DeleteJob{UserID: 1, Idents: []string{"b"}, Status: domain.DeleteJobPending, NextAttemptAt: now})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1, 2}, []int64{first.ID, second.ID})
+
+	claimed, err := storage.ClaimDeleteJobs(ctx, now, time.Minute, 10)
+	require.NoError(t, err)
+	require.Len(t, claimed, 2)
+	claimed, err = storage.ClaimDeleteJobs(ctx, now, time.Minute, 10)
+	require.NoError(t, err)
+	assert.Empty(t, claimed)
+
+	first.Status, first.Attempts, first.UpdatedAt = domain.DeleteJobDone, 1, now
+	require.NoError(t, storage.UpdateDeleteJob(ctx, first))
+	pending, err := storage.CountPendingJobs(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, pending)
+	require.NoError(t, storage.Close())
+
+	// A job claimed before a restart is due again.
+	storage = openStorage(t, path)
+	claimed, err = storage.ClaimDeleteJobs(ctx, now, time.Minute, 10)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	assert.Equal(t, second.ID, claimed[0].ID)
+
+	first.UpdatedAt = now.Add(-2 * finishedJobRetention)
+	require.NoError(t, storage.UpdateDeleteJob(ctx, first))
+	require.NoError(t, storage.DeleteFinishedJobs(ctx, now.Add(-finishedJobRetention)))
+	_, err = storage.GetDeleteJob(ctx, first.ID)
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+	require.NoError(t, storage.compact())
+	require.NoError(t, storage.Close())
+
+	storage = openStorage(t, path)
+	defer storage.Close()
+	_, err = storage.GetDeleteJob(ctx, first.ID)
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+	job, err := storage.GetDeleteJob(ctx, second.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b"}, job.Idents)
+	created, err := storage.CreateDeleteJob(ctx, domain.DeleteJob{UserID: 1, Idents: []string{"c"}, Status: domain.DeleteJobPending})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), created.ID)
+}
+
+func Test_linkStorage_DeleteJobSeqAfterPrune(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "links.json")
+	now := time.Now()
+
+	storage := openStorage(t, path)
+	job, err := storage.CreateDeleteJob(ctx, domain.DeleteJob{UserID: 1, Idents: []string{"a"}, Status: domain.DeleteJobPending, NextAttemptAt: now})
+	require.NoError(t, err)
+	job.Status, job.UpdatedAt = domain.DeleteJobDone, now.Add(-2*finishedJobRetention)
+	require.NoError(t, storage.UpdateDeleteJob(ctx, job))
+	require.NoError(t, storage.compact())
+	require.NoError(t, storage.Close())
+
+	storage = openStorage(t, path)
+	defer storage.Close()
+	_, err = storage.GetDeleteJob(ctx, job.ID)
+	require.ErrorIs(t, err, domain.ErrNotFound)
+	created, err := storage.CreateDeleteJob(ctx, domain.DeleteJob{UserID: 1, Idents: []string{"b"}, Status: domain.DeleteJobPending, NextAttemptAt: now})
+	require.NoError(t, err)
+	assert.Greater(t, created.ID, job.ID)
+}
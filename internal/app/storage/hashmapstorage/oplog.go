@@ -0,0 +1,321 @@
+package hashmapstorage
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/domain"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/logger"
+)
+
+// Operation log record kinds. Lines without an op were written before the
+// file became an operation log and hold a bare link; they replay as creates.
+const (
+	opCreate  = "create"
+	opDelete  = "delete"
+	opUpdate  = "update"
+	opRestore = "restore"
+	opPurge   = "purge"
+	opJob     = "job"
+	opUser    = "user"
+	opClaim   = "claim"
+	opAPIKey  = "apikey"
+	opSeq     = "seq"
+)
+
+// userIDBlock is how many user IDs a seq record reserves. Anonymous users are
+// not logged, so the reserved mark keeps their IDs from being handed out again
+// after a restart.
+const userIDBlock int32 = 100
+
+// defaultCompactThreshold is the log size after which the file is rewritten
+// to a snapshot of live state.
+const defaultCompactThreshold int64 = 4 << 20
+
+const compactSuffix = ".compact"
+
+// logRecord is a line of the log. Update and delete records carry the time of
+// the change; create records written by compaction carry the link's history.
+// Job records hold the whole state of a delete job, the last one wins. User
+// records hold registered users and users with a link quota; claim records
+// move links to UserID. API key records hold the whole state of a key like job
+// records. Seq records hold the highest user ID handed out or reserved and the
+// highest link, key and job IDs, so purged links, revoked keys and pruned
+// jobs do not rewind the sequences; the largest ones win.
+type logRecord struct {
+	Op      string              `json:"op"`
+	Link    *domain.Link        `json:"link,omitempty"`
+	Idents  []string            `json:"idents,omitempty"`
+	At      *time.Time          `json:"at,omitempty"`
+	History []domain.LinkChange `json:"history,omitempty"`
+	Job     *domain.DeleteJob   `json:"job,omitempty"`
+	User    *domain.User        `json:"user,omitempty"`
+	UserID  int32               `json:"user_id,omitempty"`
+	APIKey  *domain.APIKey      `json:"api_key,omitempty"`
+	LinkID  int32               `json:"link_id,omitempty"`
+	KeyID   int64               `json:"key_id,omitempty"`
+	JobID   int64               `json:"job_id,omitempty"`
+}
+
+func createRecord(link domain.Link) logRecord {
+	return logRecord{Op: opCreate, Link: &link}
+}
+
+func (s *linkStorage) seqRecord(userID int32) logRecord {
+	return logRecord{Op: opSeq, UserID: userID, LinkID: s.seqLinkID, KeyID: s.seqKeyID, JobID: s.seqJobID}
+}
+
+func (s *linkStorage) loadFromFile() error {
+	var err error
+	s.file, err = os.OpenFile(s.filePath, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0666)
+	if err != nil {
+		return err
+	}
+
+	s.loadedAt = time.Now()
+	decoder := json.NewDecoder(s.file)
+	for {
+		var raw json.RawMessage
+		err = decoder.Decode(&raw)
+		if err == io.EOF {
+			break
+		}
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			// The last record was cut short by a crash mid-write: drop it so
+			// new records are not appended to a broken line.
+			if err := s.file.Truncate(decoder.InputOffset()); err != nil {
+				return err
+			}
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if err := s.replay(raw); err != nil {
+			return err
+		}
+	}
+
+	info, err := s.file.Stat()
+	if err != nil {
+		return err
+	}
+	s.logSize = info.Size()
+	s.setCompactAt()
+	if s.seqUserID < s.userIDMark {
+		s.seqUserID = s.userIDMark
+	}
+	if s.legacyDeletes {
+		// Persist the deletion times given to legacy records, so their
+		// retention does not restart on every load.
+		return s.compact()
+	}
+	return nil
+}
+
+func (s *linkStorage) replay(raw json.RawMessage) error {
+	var rec logRecord
+	if err := json.Unmarshal(raw, &rec); err != nil {
+		return err
+	}
+	switch rec.Op {
+	case "":
+		var link domain.Link
+		if err := json.Unmarshal(raw, &link); err != nil {
+			return err
+		}
+		if link.DeletedFlag && link.DeletedAt == nil {
+			link.DeletedAt = s.legacyDeletedAt()
+		}
+		s.putLink(link)
+	case opCreate:
+		if rec.Link == nil {
+			return fmt.Errorf("file storage: %s record without link", rec.Op)
+		}
+		s.putLink(*rec.Link)
+		if len(rec.History) > 0 {
+			s.history[rec.Link.Ident] = rec.History
+		}
+	case opUpdate:
+		if rec.Link == nil || rec.At == nil {
+			return fmt.Errorf("file storage: %s record without link or time", rec.Op)
+		}
+		s.applyUpdate(*rec.Link, *rec.At)
+	case opDelete:
+		if rec.At == nil {
+			rec.At = s.legacyDeletedAt()
+		}
+		s.markDeleted(rec.Idents, rec.At)
+	case opRestore:
+		s.markRestored(rec.Idents)
+	case opPurge:
+		s.removeLinks(rec.Idents)
+	case opJob:
+		if rec.Job == nil {
+			return fmt.Errorf("file storage: %s record without job", rec.Op)
+		}
+		s.putJob(*rec.Job)
+	case opUser:
+		if rec.User == nil {
+			return fmt.Errorf("file storage: %s record without user", rec.Op)
+		}
+		s.putUser(*rec.User)
+	case opClaim:
+		s.moveLinks(rec.Idents, rec.UserID)
+	case opAPIKey:
+		if rec.APIKey == nil {
+			return fmt.Errorf("file storage: %s record without api key", rec.Op)
+		}
+		s.putAPIKey(*rec.APIKey)
+	case opSeq:
+		if rec.UserID > s.userIDMark {
+			s.userIDMark = rec.UserID
+		}
+		if rec.LinkID > s.seqLinkID {
+			s.seqLinkID = rec.LinkID
+		}
+		if rec.KeyID > s.seqKeyID {
+			s.seqKeyID = rec.KeyID
+		}
+		if rec.JobID > s.seqJobID {
+			s.seqJobID = rec.JobID
+		}
+	default:
+		return fmt.Errorf("file storage: unknown op %q", rec.Op)
+	}
+	return nil
+}
+
+// legacyDeletedAt stands in for the deletion time of links deleted before it
+// was logged: their retention starts when they are loaded.
+func (s *linkStorage) legacyDeletedAt() *time.Time {
+	s.legacyDeletes = true
+	at := s.loadedAt
+	return &at
+}
+
+// appendRecords writes records to the log with a single write and syncs the
+// file, so a batch is either fully on disk or cut short at its tail.
+func (s *linkStorage) appendRecords(records ...logRecord) error {
+	if !s.record || len(records) == 0 {
+		return nil
+	}
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, rec := range records {
+		if err := encoder.Encode(rec); err != nil {
+			return err
+		}
+	}
+	n, err := s.file.Write(buf.Bytes())
+	s.logSize += int64(n)
+	if err != nil {
+		return err
+	}
+	return s.file.Sync()
+}
+
+// maybeCompact rewrites the log once it passes the compaction threshold. The
+// rewrite goes to a temporary file that replaces the log only when complete,
+// so a failed compaction leaves the log intact and is retried on the next
+// write.
+func (s *linkStorage) maybeCompact() {
+	if !s.record || s.logSize < s.compactAt {
+		return
+	}
+	if err := s.compact(); err != nil {
+		logger.Log().Error("cannot compact file storage: " + err.Error())
+	}
+}
+
+func (s *linkStorage) compact() error {
+	tmpPath := s.filePath + compactSuffix
+	tmp, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpPath)
+
+	s.dropFinishedJobs(time.Now().Add(-finishedJobRetention))
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	if s.userIDMark < s.seqUserID {
+		s.userIDMark = s.seqUserID
+	}
+	if err := encoder.Encode(s.seqRecord(s.userIDMark)); err != nil {
+		tmp.Close()
+		return err
+	}
+	for _, link := range s.linkMap {
+		rec := createRecord(link)
+		rec.History = s.history[link.Ident]
+		if err := encoder.Encode(rec); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	for _, job := range s.jobs {
+		if err := encoder.Encode(jobRecord(job)); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	for id, key := range s.apiKeys {
+		if key.RevokedAt != nil {
+			delete(s.apiKeys, id)
+			continue
+		}
+		if err := encoder.Encode(apiKeyRecord(key)); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	for _, user := range s.users {
+		if !user.IsRegistered() && user.LinkQuota == nil {
+			continue
+		}
+		if err := encoder.Encode(userRecord(user)); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, s.filePath); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(s.filePath, os.O_RDWR|os.O_APPEND, 0666)
+	if err != nil {
+		return err
+	}
+	s.file.Close()
+	s.file = file
+	s.logSize = int64(buf.Len())
+	s.setCompactAt()
+	return nil
+}
+
+// setCompactAt schedules the next compaction once the log reaches the
+// threshold and has at least doubled since the last snapshot, so a large live
+// state does not trigger a rewrite on every write.
+func (s *linkStorage) setCompactAt() {
+	s.compactAt = s.compactThreshold
+	if 2*s.logSize > s.compactAt {
+		s.compactAt = 2 * s.logSize
+	}
+}
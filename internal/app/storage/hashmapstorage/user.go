@@ -0,0 +1,89 @@
+package hashmapstorage
+
+import (
+	"context"
+	"time"
+
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/domain"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/metrics"
+)
+
+func userRecord(user domain.User) logRecord {
+	return logRecord{Op: opUser, User: &user}
+}
+
+// GetUser finds users known from their links or registration. Anonymous users
+// without links are kept in memory only and are forgotten on restart; their
+// IDs are not handed out again.
+func (s *linkStorage) GetUser(ctx context.Context, userID int32) (domain.User, error) {
+	defer metrics.ObserveStorage(backend, "GetUser", time.Now())
+	s.RLock()
+	defer s.RUnlock()
+	user, ok := s.users[userID]
+	if !ok {
+		return domain.User{}, domain.ErrNotFound
+	}
+	return user, nil
+}
+
+func (s *linkStorage) GetUserByLogin(ctx context.Context, login string) (domain.User, error) {
+	defer metrics.ObserveStorage(backend, "GetUserByLogin", time.Now())
+	s.RLock()
+	defer s.RUnlock()
+	if user, ok := s.findLogin(login); ok {
+		return user, nil
+	}
+	return domain.User{}, domain.ErrNotFound
+}
+
+// SetCredentials registers an anonymous user. A login already in use is a
+// conflict.
+func (s *linkStorage) SetCredentials(ctx context.Context, user domain.User) error {
+	defer metrics.ObserveStorage(backend, "SetCredentials", time.Now())
+	s.Lock()
+	defer s.Unlock()
+	if _, ok := s.findLogin(user.Login); ok {
+		return domain.ErrConflict
+	}
+	if s.users[user.ID].IsRegistered() {
+		return domain.ErrNotFound
+	}
+	if err := s.appendRecords(userRecord(user)); err != nil {
+		return err
+	}
+	s.putUser(user)
+	s.maybeCompact()
+	return nil
+}
+
+// SetLinkQuota overrides the link quota of a user; nil restores the default.
+func (s *linkStorage) SetLinkQuota(ctx context.Context, userID int32, quota *int) error {
+	defer metrics.ObserveStorage(backend, "SetLinkQuota", time.Now())
+	s.Lock()
+	defer s.Unlock()
+	user, ok := s.users[userID]
+	if !ok {
+		return domain.ErrNotFound
+	}
+	user.LinkQuota = quota
+	if err := s.appendRecords(userRecord(user)); err != nil {
+		return err
+	}
+	s.putUser(user)
+	s.maybeCompact()
+	return nil
+}
+
+func (s *linkStorage) putUser(user domain.User) {
+	s.addUser(user.ID)
+	s.users[user.ID] = user
+}
+
+func (s *linkStorage) findLogin(login string) (domain.User, bool) {
+	for _, v := range s.users {
+		if v.IsRegistered() && v.Login == login {
+			return v, true
+		}
+	}
+	return domain.User{}, false
+}
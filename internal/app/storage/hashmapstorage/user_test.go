@@ -0,0 +1,117 @@
+package hashmapstorage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/domain"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/dto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_linkStorage_CredentialsAndClaim(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "links.json")
+
+	storage := openStorage(t, path)
+	userID, err := storage.CreateUser(ctx)
+	require.NoError(t, err)
+	require.NoError(t, storage.SetCredentials(ctx, domain.User{ID: userID, Login: "staff", PasswordHash: "hash"}))
+	otherID, err := storage.CreateUser(ctx)
+	require.NoError(t, err)
+	assert.ErrorIs(t, storage.SetCredentials(ctx, domain.User{ID: otherID, Login: "staff", PasswordHash: "hash"}), domain.ErrConflict)
+
+	anonID, err := storage.CreateUser(ctx)
+	require.NoError(t, err)
+	for _, v := range []domain.Link{
+		{Ident: "owned", FulLink: "https://a.example", UserID: userID},
+		{Ident: "dup", FulLink: "https://a.example", UserID: anonID},
+		{Ident: "new", FulLink: "https://b.example", UserID: anonID},
+	} {
+		_, err := storage.Create(ctx, v)
+		require.NoError(t, err)
+	}
+	claimed, err := storage.ClaimLinks(ctx, anonID, userID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, claimed)
+	require.NoError(t, storage.Close())
+
+	storage = openStorage(t, path)
+	user, err := storage.GetUserByLogin(ctx, "staff")
+	require.NoError(t, err)
+	assert.Equal(t, domain.User{ID: userID, Login: "staff", PasswordHash: "hash"}, user)
+	_, err = storage.GetUserByLogin(ctx, "")
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+
+	links, err := storage.GetLinksByUserID(ctx, userID, dto.LinkPageQuery{SortBy: dto.SortIdent, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, links, 2)
+	assert.Equal(t, []string{"new", "owned"}, []string{links[0].Ident, links[1].Ident})
+	links, err = storage.GetLinksByUserID(ctx, anonID, dto.LinkPageQuery{SortBy: dto.SortIdent, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, links, 1)
+	assert.Equal(t, "dup", links[0].Ident)
+
+	require.NoError(t, storage.compact())
+	require.NoError(t, storage.Close())
+	storage = openStorage(t, path)
+	defer storage.Close()
+	_, err = storage.GetUserByLogin(ctx, "staff")
+	assert.NoError(t, err)
+}
+
+func Test_linkStorage_LinkQuota(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "links.json")
+
+	storage := openStorage(t, path)
+	userID, err := storage.CreateUser(ctx)
+	require.NoError(t, err)
+	quota := 5
+	require.NoError(t, storage.SetLinkQuota(ctx, userID, &quota))
+	assert.ErrorIs(t, storage.SetLinkQuota(ctx, userID+1, &quota), domain.ErrNotFound)
+	for _, v := range []string{"a", "b"} {
+		_, err := storage.Create(ctx, domain.Link{Ident: v, FulLink: "https://" + v + ".example", UserID: userID})
+		require.NoError(t, err)
+	}
+	require.NoError(t, storage.DeleteByIdents(ctx, userID, "a"))
+	count, err := storage.CountUserLinks(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	require.NoError(t, storage.compact())
+	require.NoError(t, storage.Close())
+	storage = openStorage(t, path)
+	defer storage.Close()
+	user, err := storage.GetUser(ctx, userID)
+	require.NoError(t, err)
+	require.NotNil(t, user.LinkQuota)
+	assert.Equal(t, 5, *user.LinkQuota)
+}
+
+func Test_linkStorage_UserIDsAfterRestart(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "links.json")
+
+	storage := openStorage(t, path)
+	first, err := storage.CreateUser(ctx)
+	require.NoError(t, err)
+	require.NoError(t, storage.Close())
+
+	storage = openStorage(t, path)
+	second, err := storage.CreateUser(ctx)
+	require.NoError(t, err)
+	assert.Greater(t, second, first)
+	require.NoError(t, storage.compact())
+	require.NoError(t, storage.Close())
+
+	storage = openStorage(t, path)
+	defer storage.Close()
+	third, err := storage.CreateUser(ctx)
+	require.NoError(t, err)
+	assert.Greater(t, third, second)
+	_, err = storage.GetUser(ctx, second)
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+}
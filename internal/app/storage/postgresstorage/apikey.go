@@ -0,0 +1,100 @@
+package postgresstorage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/domain"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/metrics"
+	"github.com/jmoiron/sqlx"
+)
+
+// apiKeyRow is an API key as stored: the scopes are a JSON array.
+type apiKeyRow struct {
+	domain.APIKey
+	ScopesJSON string `db:"scopes"`
+}
+
+func (r apiKeyRow) key() (domain.APIKey, error) {
+	key := r.APIKey
+	if err := json.Unmarshal([]byte(r.ScopesJSON), &key.Scopes); err != nil {
+		return domain.APIKey{}, fmt.Errorf("api key %d: %w", key.ID, err)
+	}
+	return key, nil
+}
+
+type apiKeyStorage struct {
+	db *sqlx.DB
+}
+
+func NewAPIKeyStorage(db *sqlx.DB) (*apiKeyStorage, error) {
+	s := &apiKeyStorage{db: db}
+	return s, nil
+}
+
+func (s *apiKeyStorage) CreateAPIKey(ctx context.Context, key domain.APIKey) (domain.APIKey, error) {
+	defer metrics.ObserveStorage(backend, "CreateAPIKey", time.Now())
+	scopes, err := json.Marshal(key.Scopes)
+	if err != nil {
+		return domain.APIKey{}, err
+	}
+	query := fmt.Sprintf("INSERT INTO %s (%s, %s, %s, %s, %s, %s, %s) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id;",
+		apiKeyTable, userIDStor, keyName, keyPrefix, keyHash, keyScopes, expiresAt, createdAt)
+	err = s.db.GetContext(ctx, &key.ID, query, key.UserID, key.Name, key.Prefix, key.Hash, string(scopes), key.ExpiresAt, key.CreatedAt)
+	return key, err
+}
+
+func (s *apiKeyStorage) GetAPIKeys(ctx context.Context, userID int32) ([]domain.APIKey, error) {
+	defer metrics.ObserveStorage(backend, "GetAPIKeys", time.Now())
+	var rows []apiKeyRow
+	query := fmt.Sprintf("SELECT * FROM %s WHERE %s = $1 AND %s IS NULL ORDER BY id;", apiKeyTable, userIDStor, revokedAt)
+	if err := s.db.SelectContext(ctx, &rows, query, userID); err != nil {
+		return nil, err
+	}
+	keys := make([]domain.APIKey, 0, len(rows))
+	for _, v := range rows {
+		key, err := v.key()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func (s *apiKeyStorage) GetAPIKeyByHash(ctx context.Context, hash string) (domain.APIKey, error) {
+	defer metrics.ObserveStorage(backend, "GetAPIKeyByHash", time.Now())
+	var row apiKeyRow
+	query := fmt.Sprintf("SELECT * FROM %s WHERE %s = $1;", apiKeyTable, keyHash)
+	err := s.db.GetContext(ctx, &row, query, hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.APIKey{}, domain.ErrNotFound
+	}
+	if err != nil {
+		return domain.APIKey{}, err
+	}
+	return row.key()
+}
+
+// RevokeAPIKey revokes a key of userID. Keys of other users and revoked keys
+// are not found.
+func (s *apiKeyStorage) RevokeAPIKey(ctx context.Context, id int64, userID int32, at time.Time) error {
+	defer metrics.ObserveStorage(backend, "RevokeAPIKey", time.Now())
+	query := fmt.Sprintf("UPDATE %s SET %s = $3 WHERE id = $1 AND %s = $2 AND %s IS NULL;", apiKeyTable, revokedAt, userIDStor, revokedAt)
+	result, err := s.db.ExecContext(ctx, query, id, userID, at)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package postgresstorage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/domain"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/dto"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/metrics"
+	"github.com/jmoiron/sqlx"
+)
+
+type clickStorage struct {
+	db *sqlx.DB
+}
+
+func NewClickStorage(db *sqlx.DB) (*clickStorage, error) {
+	s := &clickStorage{db: db}
+	return s, nil
+}
+
+func (s *clickStorage) CreateClicks(ctx context.Context, clicks []domain.Click) error {
+	defer metrics.ObserveStorage(backend, "CreateClicks", time.Now())
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := fmt.Sprintf("INSERT INTO %s (%s, %s, %s, %s, %s) VALUES($1, $2, $3, $4, $5);",
+		clickTable, shortURL, clickedAt, referrer, userAgent, clientIP)
+	stm, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	for _, v := range clicks {
+		_, err := stm.ExecContext(ctx, v.Ident, v.ClickedAt, v.Referrer, v.UserAgent, v.ClientIP)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *clickStorage) GetStatsByIdent(ctx context.Context, ident string, topReferrers int) (dto.LinkStatsRes, error) {
+	defer metrics.ObserveStorage(backend, "GetStatsByIdent", time.Now())
+	var stats dto.LinkStatsRes
+	query := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s = $1;", clickTable, shortURL)
+	if err := s.db.GetContext(ctx, &stats.TotalClicks, query, ident); err != nil {
+		return stats, err
+	}
+
+	stats.ClicksPerDay = make([]dto.ClicksPerDay, 0)
+	query = fmt.Sprintf("SELECT to_char(%s AT TIME ZONE 'UTC', 'YYYY-MM-DD') AS day, COUNT(*) AS clicks FROM %s WHERE %s = $1 GROUP BY day ORDER BY day;",
+		clickedAt, clickTable, shortURL)
+	if err := s.db.SelectContext(ctx, &stats.ClicksPerDay, query, ident); err != nil {
+		return stats, err
+	}
+
+	stats.TopReferrers = make([]dto.ReferrerClicks, 0)
+	query = fmt.Sprintf("SELECT %s, COUNT(*) AS clicks FROM %s WHERE %s = $1 AND %s <> '' GROUP BY %s ORDER BY clicks DESC, %s LIMIT $2;",
+		referrer, clickTable, shortURL, referrer, referrer, referrer)
+	err := s.db.SelectContext(ctx, &stats.TopReferrers, query, ident, topReferrers)
+	return stats, err
+}
+
+func (s *clickStorage) Close() error {
+	return nil
+}
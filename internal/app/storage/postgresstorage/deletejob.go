@@ -0,0 +1,119 @@
+package postgresstorage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/domain"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/metrics"
+	"github.com/jmoiron/sqlx"
+)
+
+// deleteJobRow is a delete job as stored: the idents are a JSON array.
+type deleteJobRow struct {
+	domain.DeleteJob
+	IdentsJSON string `db:"idents"`
+}
+
+func (r deleteJobRow) job() (domain.DeleteJob, error) {
+	job := r.DeleteJob
+	if err := json.Unmarshal([]byte(r.IdentsJSON), &job.Idents); err != nil {
+		return domain.DeleteJob{}, fmt.Errorf("delete job %d: %w", job.ID, err)
+	}
+	return job, nil
+}
+
+type deleteJobStorage struct {
+	db *sqlx.DB
+}
+
+func NewDeleteJobStorage(db *sqlx.DB) (*deleteJobStorage, error) {
+	s := &deleteJobStorage{db: db}
+	return s, nil
+}
+
+func (s *deleteJobStorage) CreateDeleteJob(ctx context.Context, job domain.DeleteJob) (domain.DeleteJob, error) {
+	defer metrics.ObserveStorage(backend, "CreateDeleteJob", time.Now())
+	idents, err := json.Marshal(job.Idents)
+	if err != nil {
+		return domain.DeleteJob{}, err
+	}
+	query := fmt.Sprintf("INSERT INTO %s (%s, %s, %s, %s, %s, %s) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id;",
+		deleteJobTable, userIDStor, jobIdents, jobStatus, nextAttemptAt, createdAt, updatedAt)
+	err = s.db.GetContext(ctx, &job.ID, query, job.UserID, string(idents), job.Status, job.NextAttemptAt, job.CreatedAt, job.UpdatedAt)
+	return job, err
+}
+
+func (s *deleteJobStorage) GetDeleteJob(ctx context.Context, id int64) (domain.DeleteJob, error) {
+	defer metrics.ObserveStorage(backend, "GetDeleteJob", time.Now())
+	var row deleteJobRow
+	query := fmt.Sprintf("SELECT * FROM %s WHERE id = $1;", deleteJobTable)
+	err := s.db.GetContext(ctx, &row, query, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.DeleteJob{}, domain.ErrNotFound
+	}
+	if err != nil {
+		return domain.DeleteJob{}, err
+	}
+	return row.job()
+}
+
+// ClaimDeleteJobs skips jobs locked by concurrent claims, so instances sharing
+// the database do not take the same job.
+func (s *deleteJobStorage) ClaimDeleteJobs(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]domain.DeleteJob, error) {
+	defer metrics.ObserveStorage(backend, "ClaimDeleteJobs", time.Now())
+	var rows []deleteJobRow
+	query := fmt.Sprintf(`UPDATE %[1]s SET %[2]s = $2 WHERE id IN (
+			SELECT id FROM %[1]s WHERE %[3]s = $3 AND %[2]s <= $1 ORDER BY id LIMIT $4 FOR UPDATE SKIP LOCKED
+		) RETURNING *;`, deleteJobTable, nextAttemptAt, jobStatus)
+	if err := s.db.SelectContext(ctx, &rows, query, now, now.Add(lease), domain.DeleteJobPending, limit); err != nil {
+		return nil, err
+	}
+	jobs := make([]domain.DeleteJob, 0, len(rows))
+	for _, row := range rows {
+		job, err := row.job()
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+// DeleteFinishedJobs removes done and dead jobs last updated before before.
+func (s *deleteJobStorage) DeleteFinishedJobs(ctx context.Context, before time.Time) error {
+	defer metrics.ObserveStorage(backend, "DeleteFinishedJobs", time.Now())
+	query := fmt.Sprintf("DELETE FROM %s WHERE %s <> $1 AND %s < $2;", deleteJobTable, jobStatus, updatedAt)
+	_, err := s.db.ExecContext(ctx, query, domain.DeleteJobPending, before)
+	return err
+}
+
+func (s *deleteJobStorage) CountPendingJobs(ctx context.Context) (int, error) {
+	defer metrics.ObserveStorage(backend, "CountPendingJobs", time.Now())
+	var n int
+	query := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s = $1;", deleteJobTable, jobStatus)
+	err := s.db.GetContext(ctx, &n, query, domain.DeleteJobPending)
+	return n, err
+}
+
+func (s *deleteJobStorage) UpdateDeleteJob(ctx context.Context, job domain.DeleteJob) error {
+	defer metrics.ObserveStorage(backend, "UpdateDeleteJob", time.Now())
+	query := fmt.Sprintf("UPDATE %s SET %s = $2, %s = $3, %s = $4, %s = $5, %s = $6 WHERE id = $1;",
+		deleteJobTable, jobStatus, jobAttempts, lastError, nextAttemptAt, updatedAt)
+	res, err := s.db.ExecContext(ctx, query, job.ID, job.Status, job.Attempts, job.LastError, job.NextAttemptAt, job.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
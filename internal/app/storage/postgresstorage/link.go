@@ -2,12 +2,15 @@ package postgresstorage
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/domain"
 	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/dto"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/metrics"
 	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jmoiron/sqlx"
@@ -23,50 +26,79 @@ func NewLinkStorage(db *sqlx.DB) (*linkStorage, error) {
 }
 
 func (s *linkStorage) GetOneByIdent(ctx context.Context, ident string) (domain.Link, error) {
+	defer metrics.ObserveStorage(backend, "GetOneByIdent", time.Now())
 	var link domain.Link
 	query := fmt.Sprintf("SELECT * FROM %s WHERE %s = $1;", linkTable, shortURL)
 	err := s.db.GetContext(ctx, &link, query, ident)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = domain.ErrNotFound
+	}
 	return link, err
 }
 
-func (s *linkStorage) Create(ctx context.Context, ident, fulLink string, userID int32) (domain.Link, error) {
+func (s *linkStorage) Create(ctx context.Context, newLink domain.Link) (domain.Link, error) {
+	defer metrics.ObserveStorage(backend, "Create", time.Now())
 	var link domain.Link
 
-	query := fmt.Sprintf("INSERT INTO %s (%s, %s, %s) VALUES($1, $2, $3) RETURNING id, %s, %s, %s;",
-		linkTable, shortURL, originalURL, userIDStor, shortURL, originalURL, userIDStor)
-	err := s.db.GetContext(ctx, &link, query, ident, fulLink, userID)
+	query := fmt.Sprintf("INSERT INTO %s (%s, %s, %s, %s) VALUES($1, $2, $3, $4) RETURNING id, %s, %s, %s, %s, %s;",
+		linkTable, shortURL, originalURL, userIDStor, expiresAt, shortURL, originalURL, userIDStor, expiresAt, createdAt)
+	err := s.db.GetContext(ctx, &link, query, newLink.Ident, newLink.FulLink, newLink.UserID, newLink.ExpiresAt)
 
-	if err != nil {
-		var pgErr *pgconn.PgError
-		if errors.As(err, &pgErr) && pgerrcode.IsIntegrityConstraintViolation(pgErr.Code) {
-			err = ErrConflict
-		}
+	if err == nil {
+		return link, nil
+	}
+	if err = conflictErr(err); !errors.Is(err, domain.ErrConflict) {
+		return link, err
 	}
 
-	query = fmt.Sprintf("SELECT id, %s, %s FROM %s WHERE %s = $1;", shortURL, originalURL, linkTable, originalURL)
-	if err := s.db.GetContext(ctx, &link, query, fulLink); err != nil {
+	query = fmt.Sprintf("SELECT * FROM %s WHERE %s = $1 AND %s = $2 AND %s = false;", linkTable, originalURL, userIDStor, isDeleted)
+	if err := s.db.GetContext(ctx, &link, query, newLink.FulLink, newLink.UserID); err != nil {
 		return link, err
 	}
 	return link, err
 }
 
+func (s *linkStorage) GetByOriginalURL(ctx context.Context, url string) (domain.Link, error) {
+	defer metrics.ObserveStorage(backend, "GetByOriginalURL", time.Now())
+	var link domain.Link
+	query := fmt.Sprintf("SELECT * FROM %s WHERE %s = $1 AND %s = false ORDER BY id LIMIT 1;", linkTable, originalURL, isDeleted)
+	err := s.db.GetContext(ctx, &link, query, url)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = domain.ErrNotFound
+	}
+	return link, err
+}
+
+// GetUserLinkByURL returns the live link of userID to url.
+func (s *linkStorage) GetUserLinkByURL(ctx context.Context, userID int32, url string) (domain.Link, error) {
+	defer metrics.ObserveStorage(backend, "GetUserLinkByURL", time.Now())
+	var link domain.Link
+	query := fmt.Sprintf("SELECT * FROM %s WHERE %s = $1 AND %s = $2 AND %s = false;", linkTable, originalURL, userIDStor, isDeleted)
+	err := s.db.GetContext(ctx, &link, query, url, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = domain.ErrNotFound
+	}
+	return link, err
+}
+
 func (s *linkStorage) CreateLinks(ctx context.Context, links []domain.Link, userID int32) error {
+	defer metrics.ObserveStorage(backend, "CreateLinks", time.Now())
 	tx, err := s.db.Begin()
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
-	query := fmt.Sprintf("INSERT INTO %s (%s, %s, %s) VALUES($1, $2, $3);",
-		linkTable, shortURL, originalURL, userIDStor)
-	stm, err := s.db.PrepareContext(ctx, query)
+	query := fmt.Sprintf("INSERT INTO %s (%s, %s, %s, %s) VALUES($1, $2, $3, $4);",
+		linkTable, shortURL, originalURL, userIDStor, expiresAt)
+	stm, err := tx.PrepareContext(ctx, query)
 	if err != nil {
 		return err
 	}
 	for _, v := range links {
-		_, err := stm.ExecContext(ctx, v.Ident, v.FulLink, userID)
+		_, err := stm.ExecContext(ctx, v.Ident, v.FulLink, userID, v.ExpiresAt)
 		if err != nil {
-			return err
+			return conflictErr(err)
 		}
 	}
 	if err := tx.Commit(); err != nil {
@@ -75,27 +107,118 @@ func (s *linkStorage) CreateLinks(ctx context.Context, links []domain.Link, user
 	return nil
 }
 
-func (s *linkStorage) GetLinksByUserID(ctx context.Context, userID int32) ([]dto.LinkListByUserIDRes, error) {
-	var linkListByUserIDRes []dto.LinkListByUserIDRes
-	query := fmt.Sprintf("SELECT %s, %s FROM %s WHERE %s = $1 AND %s = $2;", shortURL, originalURL, linkTable, userIDStor, isDeleted)
-	err := s.db.SelectContext(ctx, &linkListByUserIDRes, query, userID, false)
-	return linkListByUserIDRes, err
+func (s *linkStorage) GetLinksByUserID(ctx context.Context, userID int32, page dto.LinkPageQuery) ([]domain.Link, error) {
+	defer metrics.ObserveStorage(backend, "GetLinksByUserID", time.Now())
+	conds := []string{fmt.Sprintf("%s = $1", userIDStor)}
+	if !page.IncludeDeleted {
+		conds = append(conds, fmt.Sprintf("%s = false", isDeleted))
+	}
+	args := []any{userID}
+	if page.Search != "" {
+		args = append(args, page.Search)
+		conds = append(conds, fmt.Sprintf("strpos(lower(%s), lower($%d)) > 0", originalURL, len(args)))
+	}
+
+	sortColumn, after := "id", any(page.AfterID)
+	hasCursor := page.AfterID != 0
+	if page.SortBy == dto.SortIdent {
+		sortColumn, after = shortURL, page.AfterIdent
+		hasCursor = page.AfterIdent != ""
+	}
+	direction, cmp := "ASC", ">"
+	if page.Desc {
+		direction, cmp = "DESC", "<"
+	}
+	if hasCursor {
+		args = append(args, after)
+		conds = append(conds, fmt.Sprintf("%s %s $%d", sortColumn, cmp, len(args)))
+	}
+	args = append(args, page.Limit)
+
+	var links []domain.Link
+	query := fmt.Sprintf("SELECT * FROM %s WHERE %s ORDER BY %s %s LIMIT $%d;",
+		linkTable, strings.Join(conds, " AND "), sortColumn, direction, len(args))
+	err := s.db.SelectContext(ctx, &links, query, args...)
+	return links, err
 }
 
-func (s *linkStorage) DeleteByIdents(ctx context.Context, idents ...string) error {
+// DeleteByIdents marks the links among idents that belong to userID deleted.
+func (s *linkStorage) DeleteByIdents(ctx context.Context, userID int32, idents ...string) error {
+	defer metrics.ObserveStorage(backend, "DeleteByIdents", time.Now())
 	var values []string
-	var args []any
+	args := []any{userID}
 	for i, v := range idents {
-		params := fmt.Sprintf("$%d", i+1)
+		params := fmt.Sprintf("$%d", i+2)
 		values = append(values, params)
 		args = append(args, v)
 	}
-	query := fmt.Sprintf("UPDATE %s SET %s = true WHERE %s IN (", linkTable, isDeleted, shortURL) + strings.Join(values, ",") + ");"
+	query := fmt.Sprintf("UPDATE %s SET %s = true, %s = now() WHERE %s = false AND %s = $1 AND %s IN (", linkTable, isDeleted, deletedAt, isDeleted, userIDStor, shortURL) + strings.Join(values, ",") + ");"
 	_, err := s.db.ExecContext(ctx, query, args...)
 	return err
 }
 
+func (s *linkStorage) DeleteExpired(ctx context.Context, now time.Time) error {
+	defer metrics.ObserveStorage(backend, "DeleteExpired", time.Now())
+	query := fmt.Sprintf("UPDATE %s SET %s = true, %s = now() WHERE %s <= $1 AND %s = false;", linkTable, isDeleted, deletedAt, expiresAt, isDeleted)
+	_, err := s.db.ExecContext(ctx, query, now)
+	return err
+}
+
+// Restore undeletes the links among idents deleted after deletedAfter and
+// returns their idents. Links deleted earlier, and links whose user has since
+// shortened the same URL again, are left deleted.
+func (s *linkStorage) Restore(ctx context.Context, deletedAfter time.Time, idents ...string) ([]string, error) {
+	defer metrics.ObserveStorage(backend, "Restore", time.Now())
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Links are restored one by one so each sees the links restored before
+	// it and two deleted links to the same URL are not both brought back.
+	query := fmt.Sprintf(`UPDATE %[1]s l SET %[2]s = false, %[3]s = NULL
+		WHERE l.%[4]s = $1 AND l.%[2]s = true AND l.%[3]s > $2 AND NOT EXISTS (
+			SELECT 1 FROM %[1]s o WHERE o.%[5]s = l.%[5]s AND o.%[6]s = l.%[6]s AND o.%[2]s = false
+		) RETURNING l.%[4]s;`, linkTable, isDeleted, deletedAt, shortURL, userIDStor, originalURL)
+	stm, err := tx.PreparexContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	var restored []string
+	for _, v := range idents {
+		var ident string
+		err := stm.GetContext(ctx, &ident, v, deletedAfter)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		restored = append(restored, ident)
+	}
+	return restored, tx.Commit()
+}
+
+// Purge removes links deleted at or before before together with their
+// history and clicks, and returns their idents.
+func (s *linkStorage) Purge(ctx context.Context, before time.Time) ([]string, error) {
+	defer metrics.ObserveStorage(backend, "Purge", time.Now())
+	var purged []string
+	query := fmt.Sprintf(`WITH purged AS (
+			DELETE FROM %[1]s WHERE %[2]s = true AND %[3]s <= $1 RETURNING %[4]s
+		), history AS (
+			DELETE FROM %[5]s WHERE %[4]s IN (SELECT %[4]s FROM purged)
+		), clicks AS (
+			DELETE FROM %[6]s WHERE %[4]s IN (SELECT %[4]s FROM purged)
+		)
+		SELECT %[4]s FROM purged;`, linkTable, isDeleted, deletedAt, shortURL, historyTable, clickTable)
+	err := s.db.SelectContext(ctx, &purged, query, before)
+	return purged, err
+}
+
 func (s *linkStorage) GetByIdents(ctx context.Context, idents ...string) ([]domain.Link, error) {
+	defer metrics.ObserveStorage(backend, "GetByIdents", time.Now())
 	var values []string
 	var args []any
 	for i, v := range idents {
@@ -109,6 +232,98 @@ func (s *linkStorage) GetByIdents(ctx context.Context, idents ...string) ([]doma
 	return links, err
 }
 
+func (s *linkStorage) CountLinks(ctx context.Context) (int, error) {
+	defer metrics.ObserveStorage(backend, "CountLinks", time.Now())
+	var count int
+	query := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s = false;", linkTable, isDeleted)
+	err := s.db.GetContext(ctx, &count, query)
+	return count, err
+}
+
+func (s *linkStorage) CountUserLinks(ctx context.Context, userID int32) (int, error) {
+	defer metrics.ObserveStorage(backend, "CountUserLinks", time.Now())
+	var count int
+	query := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s = $1 AND %s = false;", linkTable, userIDStor, isDeleted)
+	err := s.db.GetContext(ctx, &count, query, userID)
+	return count, err
+}
+
+// Update changes the destination and expiry of a live link of link.UserID,
+// recording the replaced destination in the link's history.
+func (s *linkStorage) Update(ctx context.Context, link domain.Link) (domain.Link, error) {
+	defer metrics.ObserveStorage(backend, "Update", time.Now())
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return domain.Link{}, err
+	}
+	defer tx.Rollback()
+
+	var old domain.Link
+	query := fmt.Sprintf("SELECT * FROM %s WHERE %s = $1 AND %s = $2 AND %s = false FOR UPDATE;", linkTable, shortURL, userIDStor, isDeleted)
+	err = tx.GetContext(ctx, &old, query, link.Ident, link.UserID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.Link{}, domain.ErrNotFound
+	}
+	if err != nil {
+		return domain.Link{}, err
+	}
+	if old.FulLink != link.FulLink {
+		query = fmt.Sprintf("INSERT INTO %s (%s, %s, %s) VALUES($1, $2, $3);", historyTable, shortURL, originalURL, changedAt)
+		if _, err := tx.ExecContext(ctx, query, old.Ident, old.FulLink, time.Now()); err != nil {
+			return domain.Link{}, err
+		}
+	}
+
+	var updated domain.Link
+	query = fmt.Sprintf("UPDATE %s SET %s = $2, %s = $3 WHERE %s = $1 RETURNING *;", linkTable, originalURL, expiresAt, shortURL)
+	if err := tx.GetContext(ctx, &updated, query, link.Ident, link.FulLink, link.ExpiresAt); err != nil {
+		return domain.Link{}, conflictErr(err)
+	}
+	return updated, tx.Commit()
+}
+
+func (s *linkStorage) GetHistory(ctx context.Context, ident string) ([]domain.LinkChange, error) {
+	defer metrics.ObserveStorage(backend, "GetHistory", time.Now())
+	var history []domain.LinkChange
+	query := fmt.Sprintf("SELECT %s, %s FROM %s WHERE %s = $1 ORDER BY id DESC;", originalURL, changedAt, historyTable, shortURL)
+	err := s.db.SelectContext(ctx, &history, query, ident)
+	return history, err
+}
+
+func (s *linkStorage) NextIdentSeq(ctx context.Context) (int64, error) {
+	defer metrics.ObserveStorage(backend, "NextIdentSeq", time.Now())
+	var n int64
+	query := fmt.Sprintf("SELECT nextval('%s');", identSeq)
+	err := s.db.GetContext(ctx, &n, query)
+	return n, err
+}
+
 func (s *linkStorage) Close() error {
 	return s.db.Close()
 }
+
+// ClaimLinks moves the links of fromUserID to toUserID and returns how many
+// moved. Live links to URLs toUserID already has stay where they are.
+func (s *linkStorage) ClaimLinks(ctx context.Context, fromUserID, toUserID int32) (int, error) {
+	defer metrics.ObserveStorage(backend, "ClaimLinks", time.Now())
+	query := fmt.Sprintf(`UPDATE %[1]s l SET %[2]s = $2 WHERE l.%[2]s = $1 AND (l.%[3]s OR NOT EXISTS (
+			SELECT 1 FROM %[1]s o WHERE o.%[2]s = $2 AND o.%[4]s = l.%[4]s AND o.%[3]s = false
+		));`, linkTable, userIDStor, isDeleted, originalURL)
+	result, err := s.db.ExecContext(ctx, query, fromUserID, toUserID)
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+func conflictErr(err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || !pgerrcode.IsIntegrityConstraintViolation(pgErr.Code) {
+		return err
+	}
+	if pgErr.ConstraintName == shortURLKey {
+		return domain.ErrAliasTaken
+	}
+	return domain.ErrConflict
+}
@@ -0,0 +1,255 @@
+package postgresstorage
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	schemaVersionTable = "schema_version"
+	// migrationLockID keys the advisory lock serializing migration runners.
+	migrationLockID = 7710421
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+var ErrSchemaOutdated = errors.New("database schema has pending migrations")
+
+type Migration struct {
+	Version int
+	Name    string
+	up      string
+	down    string
+}
+
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+}
+
+// Migrations returns the embedded migrations ordered by version. Files are
+// named <version>_<name>.up.sql and <version>_<name>.down.sql.
+func Migrations() ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		base, direction, ok := cutDirection(entry.Name())
+		if !ok {
+			return nil, fmt.Errorf("migration %s: expected .up.sql or .down.sql suffix", entry.Name())
+		}
+		versionStr, name, ok := strings.Cut(base, "_")
+		version, err := strconv.Atoi(versionStr)
+		if !ok || err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s: expected <version>_<name> prefix", entry.Name())
+		}
+		data, err := migrationFiles.ReadFile(path.Join("migrations", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		}
+		if m.Name != name {
+			return nil, fmt.Errorf("migration %d: conflicting names %q and %q", version, m.Name, name)
+		}
+		if direction == "up" {
+			m.up = string(data)
+		} else {
+			m.down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.up == "" || m.down == "" {
+			return nil, fmt.Errorf("migration %d: both up and down files are required", m.Version)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			return nil, fmt.Errorf("migration %d: versions must be sequential starting at 1", m.Version)
+		}
+	}
+	return migrations, nil
+}
+
+// MigrateUp applies every pending migration and returns the applied ones.
+func MigrateUp(ctx context.Context, db *sqlx.DB) ([]Migration, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	if err := initSchemaVersion(ctx, db); err != nil {
+		return nil, err
+	}
+	var applied []Migration
+	for _, m := range migrations {
+		ok, err := runMigration(ctx, db, m, true)
+		if err != nil {
+			return applied, fmt.Errorf("migration %d_%s up: %w", m.Version, m.Name, err)
+		}
+		if ok {
+			applied = append(applied, m)
+		}
+	}
+	return applied, nil
+}
+
+// MigrateDown reverts the latest applied migration. It returns false when no
+// migration is applied.
+func MigrateDown(ctx context.Context, db *sqlx.DB) (Migration, bool, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return Migration{}, false, err
+	}
+	if err := initSchemaVersion(ctx, db); err != nil {
+		return Migration{}, false, err
+	}
+	version, err := currentVersion(ctx, db)
+	if err != nil || version == 0 {
+		return Migration{}, false, err
+	}
+	if version > len(migrations) {
+		return Migration{}, false, fmt.Errorf("schema version %d is newer than this binary", version)
+	}
+	m := migrations[version-1]
+	ok, err := runMigration(ctx, db, m, false)
+	if err != nil {
+		return Migration{}, false, fmt.Errorf("migration %d_%s down: %w", m.Version, m.Name, err)
+	}
+	return m, ok, nil
+}
+
+// MigrationsStatus lists the embedded migrations with their apply time,
+// which is nil for pending ones.
+func MigrationsStatus(ctx context.Context, db *sqlx.DB) ([]MigrationStatus, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	if err := initSchemaVersion(ctx, db); err != nil {
+		return nil, err
+	}
+	var rows []struct {
+		Version   int       `db:"version"`
+		AppliedAt time.Time `db:"applied_at"`
+	}
+	query := fmt.Sprintf("SELECT version, applied_at FROM %s", schemaVersionTable)
+	if err := db.SelectContext(ctx, &rows, query); err != nil {
+		return nil, err
+	}
+	appliedAt := make(map[int]time.Time, len(rows))
+	for _, row := range rows {
+		appliedAt[row.Version] = row.AppliedAt
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		status := MigrationStatus{Version: m.Version, Name: m.Name}
+		if t, ok := appliedAt[m.Version]; ok {
+			status.AppliedAt = &t
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// CheckMigrations returns ErrSchemaOutdated when migrations are pending.
+func CheckMigrations(ctx context.Context, db *sqlx.DB) error {
+	statuses, err := MigrationsStatus(ctx, db)
+	if err != nil {
+		return err
+	}
+	for _, s := range statuses {
+		if s.AppliedAt == nil {
+			return fmt.Errorf("%w: %d_%s", ErrSchemaOutdated, s.Version, s.Name)
+		}
+	}
+	return nil
+}
+
+func initSchemaVersion(ctx context.Context, db *sqlx.DB) error {
+	query := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (version INT PRIMARY KEY, name TEXT NOT NULL, applied_at TIMESTAMPTZ NOT NULL DEFAULT now());", schemaVersionTable)
+	_, err := db.ExecContext(ctx, query)
+	return err
+}
+
+func currentVersion(ctx context.Context, db *sqlx.DB) (int, error) {
+	var version int
+	query := fmt.Sprintf("SELECT COALESCE(MAX(version), 0) FROM %s", schemaVersionTable)
+	err := db.GetContext(ctx, &version, query)
+	return version, err
+}
+
+// runMigration applies or reverts m in a single transaction holding the
+// migration lock, so concurrent runners apply each migration once. It
+// returns false when there was nothing to do.
+func runMigration(ctx context.Context, db *sqlx.DB, m Migration, up bool) (bool, error) {
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", migrationLockID); err != nil {
+		return false, err
+	}
+	var applied bool
+	query := fmt.Sprintf("SELECT EXISTS (SELECT 1 FROM %s WHERE version = $1)", schemaVersionTable)
+	if err := tx.GetContext(ctx, &applied, query, m.Version); err != nil {
+		return false, err
+	}
+	if applied == up {
+		return false, nil
+	}
+
+	if up {
+		if _, err := tx.ExecContext(ctx, m.up); err != nil {
+			return false, err
+		}
+		query = fmt.Sprintf("INSERT INTO %s (version, name) VALUES ($1, $2)", schemaVersionTable)
+		_, err = tx.ExecContext(ctx, query, m.Version, m.Name)
+	} else {
+		if _, err := tx.ExecContext(ctx, m.down); err != nil {
+			return false, err
+		}
+		query = fmt.Sprintf("DELETE FROM %s WHERE version = $1", schemaVersionTable)
+		_, err = tx.ExecContext(ctx, query, m.Version)
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+func cutDirection(file string) (string, string, bool) {
+	if base, ok := strings.CutSuffix(file, ".up.sql"); ok {
+		return base, "up", true
+	}
+	if base, ok := strings.CutSuffix(file, ".down.sql"); ok {
+		return base, "down", true
+	}
+	return "", "", false
+}
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS ys_link;
+DROP TABLE IF EXISTS ys_user;
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS ys_user (
+    id SERIAL PRIMARY KEY,
+    create_date DATE
+);
+
+CREATE TABLE IF NOT EXISTS ys_link (
+    id SERIAL PRIMARY KEY,
+    short_url VARCHAR(255) NOT NULL UNIQUE,
+    original_url VARCHAR(255) NOT NULL UNIQUE,
+    user_id INT REFERENCES ys_user (id) ON DELETE CASCADE NOT NULL,
+    is_deleted BOOLEAN DEFAULT false
+);
@@ -0,0 +1 @@
+ALTER TABLE ys_link ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ;
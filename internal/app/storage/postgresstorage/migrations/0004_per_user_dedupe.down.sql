@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS ys_link_original_url_idx;
+DROP INDEX IF EXISTS ys_link_user_id_original_url_key;
+
+ALTER TABLE ys_link ADD CONSTRAINT ys_link_original_url_key UNIQUE (original_url);
@@ -0,0 +1,6 @@
+ALTER TABLE ys_link DROP CONSTRAINT IF EXISTS ys_link_original_url_key;
+
+CREATE UNIQUE INDEX IF NOT EXISTS ys_link_user_id_original_url_key
+    ON ys_link (user_id, original_url) WHERE is_deleted = false;
+
+CREATE INDEX IF NOT EXISTS ys_link_original_url_idx ON ys_link (original_url);
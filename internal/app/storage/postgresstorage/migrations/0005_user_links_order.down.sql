@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS ys_link_user_id_short_url_idx;
+DROP INDEX IF EXISTS ys_link_user_id_id_idx;
@@ -0,0 +1,3 @@
+CREATE INDEX IF NOT EXISTS ys_link_user_id_id_idx ON ys_link (user_id, id);
+
+CREATE INDEX IF NOT EXISTS ys_link_user_id_short_url_idx ON ys_link (user_id, short_url);
@@ -0,0 +1 @@
+DROP SEQUENCE IF EXISTS ys_ident_seq;
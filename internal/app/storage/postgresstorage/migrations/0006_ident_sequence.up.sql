@@ -0,0 +1 @@
+CREATE SEQUENCE IF NOT EXISTS ys_ident_seq;
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS ys_link_history (
+    id SERIAL PRIMARY KEY,
+    short_url VARCHAR(255) NOT NULL,
+    original_url VARCHAR(255) NOT NULL,
+    changed_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS ys_link_history_short_url_idx ON ys_link_history (short_url);
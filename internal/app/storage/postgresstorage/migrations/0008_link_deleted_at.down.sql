@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS ys_link_deleted_at_idx;
+ALTER TABLE ys_link DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,6 @@
+ALTER TABLE ys_link ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+-- Links deleted before the column existed start their retention now.
+UPDATE ys_link SET deleted_at = now() WHERE is_deleted = true AND deleted_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS ys_link_deleted_at_idx ON ys_link (deleted_at) WHERE is_deleted = true;
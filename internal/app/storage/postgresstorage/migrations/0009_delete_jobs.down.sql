@@ -0,0 +1 @@
+DROP TABLE IF EXISTS ys_delete_job;
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS ys_delete_job (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INT REFERENCES ys_user (id) ON DELETE CASCADE NOT NULL,
+    idents TEXT NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending',
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS ys_delete_job_due_idx ON ys_delete_job (next_attempt_at) WHERE status = 'pending';
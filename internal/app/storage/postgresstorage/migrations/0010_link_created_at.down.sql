@@ -0,0 +1 @@
+ALTER TABLE ys_link DROP COLUMN IF EXISTS created_at;
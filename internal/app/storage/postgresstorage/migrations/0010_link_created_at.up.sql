@@ -0,0 +1 @@
+ALTER TABLE ys_link ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ DEFAULT now();
@@ -0,0 +1,2 @@
+ALTER TABLE ys_user DROP COLUMN IF EXISTS password_hash;
+ALTER TABLE ys_user DROP COLUMN IF EXISTS login;
@@ -0,0 +1,2 @@
+ALTER TABLE ys_user ADD COLUMN IF NOT EXISTS login VARCHAR(64) UNIQUE;
+ALTER TABLE ys_user ADD COLUMN IF NOT EXISTS password_hash TEXT;
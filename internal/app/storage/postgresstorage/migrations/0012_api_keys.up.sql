@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS ys_api_key (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INT REFERENCES ys_user (id) ON DELETE CASCADE NOT NULL,
+    name VARCHAR(64) NOT NULL,
+    prefix VARCHAR(16) NOT NULL,
+    key_hash CHAR(64) NOT NULL UNIQUE,
+    scopes TEXT NOT NULL,
+    expires_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS ys_api_key_user_id_idx ON ys_api_key (user_id);
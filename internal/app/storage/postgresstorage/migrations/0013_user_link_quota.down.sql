@@ -0,0 +1 @@
+ALTER TABLE ys_user DROP COLUMN IF EXISTS link_quota;
@@ -0,0 +1 @@
+ALTER TABLE ys_user ADD COLUMN IF NOT EXISTS link_quota INTEGER;
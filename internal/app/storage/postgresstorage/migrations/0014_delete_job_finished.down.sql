@@ -0,0 +1 @@
+DROP INDEX IF EXISTS ys_delete_job_finished_idx;
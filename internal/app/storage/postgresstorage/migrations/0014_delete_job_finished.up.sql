@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS ys_delete_job_finished_idx ON ys_delete_job (updated_at) WHERE status <> 'pending';
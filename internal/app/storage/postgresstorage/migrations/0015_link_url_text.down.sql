@@ -0,0 +1,2 @@
+ALTER TABLE ys_link_history ALTER COLUMN original_url TYPE VARCHAR(255);
+ALTER TABLE ys_link ALTER COLUMN original_url TYPE VARCHAR(255);
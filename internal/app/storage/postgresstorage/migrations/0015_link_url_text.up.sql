@@ -0,0 +1,2 @@
+ALTER TABLE ys_link ALTER COLUMN original_url TYPE TEXT;
+ALTER TABLE ys_link_history ALTER COLUMN original_url TYPE TEXT;
@@ -1,24 +1,50 @@
 package postgresstorage
 
 import (
-	"errors"
-	"fmt"
-
 	_ "github.com/jackc/pgx/v5/stdlib"
 	"github.com/jmoiron/sqlx"
 )
 
 const (
-	linkTable   = "ys_link"
-	shortURL    = "short_url"
-	originalURL = "original_url"
-	userTable   = "ys_user"
-	userIDStor  = "user_id"
-	createDate  = "create_date"
-	isDeleted   = "is_deleted"
-)
+	backend      = "postgres"
+	linkTable    = "ys_link"
+	shortURL     = "short_url"
+	originalURL  = "original_url"
+	userTable    = "ys_user"
+	userIDStor   = "user_id"
+	createDate   = "create_date"
+	login        = "login"
+	passwordHash = "password_hash"
+	linkQuota    = "link_quota"
+	isDeleted    = "is_deleted"
+	expiresAt    = "expires_at"
+	deletedAt    = "deleted_at"
+	clickTable   = "ys_click"
+	clickedAt    = "clicked_at"
+	referrer     = "referrer"
+	userAgent    = "user_agent"
+	clientIP     = "client_ip"
+	shortURLKey  = linkTable + "_" + shortURL + "_key"
+	identSeq     = "ys_ident_seq"
+	historyTable = "ys_link_history"
+	changedAt    = "changed_at"
 
-var ErrConflict = errors.New("data conflict")
+	deleteJobTable = "ys_delete_job"
+	jobIdents      = "idents"
+	jobStatus      = "status"
+	jobAttempts    = "attempts"
+	lastError      = "last_error"
+	nextAttemptAt  = "next_attempt_at"
+	createdAt      = "created_at"
+	updatedAt      = "updated_at"
+
+	apiKeyTable = "ys_api_key"
+	keyName     = "name"
+	keyPrefix   = "prefix"
+	keyHash     = "key_hash"
+	keyScopes   = "scopes"
+	revokedAt   = "revoked_at"
+)
 
 func NewPostgresDB(cfg string) (*sqlx.DB, error) {
 	db, err := sqlx.Open("pgx", cfg)
@@ -28,19 +54,5 @@ func NewPostgresDB(cfg string) (*sqlx.DB, error) {
 	if err := db.Ping(); err != nil {
 		return nil, err
 	}
-	if err := initTable(db); err != nil {
-		return nil, err
-	}
 	return db, nil
 }
-
-func initTable(db *sqlx.DB) error {
-	query := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (id SERIAL PRIMARY KEY, %s DATE);", userTable, createDate)
-	_, err := db.Exec(query)
-	if err != nil {
-		return err
-	}
-	query = fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (id SERIAL PRIMARY KEY, %s VARCHAR(255) NOT NULL UNIQUE, %s VARCHAR(255) NOT NULL UNIQUE, %s INT REFERENCES %s (id) ON DELETE CASCADE NOT NULL, %s BOOLEAN DEFAULT false);", linkTable, shortURL, originalURL, userIDStor, userTable, isDeleted)
-	_, err = db.Exec(query)
-	return err
-}
@@ -2,10 +2,13 @@ package postgresstorage
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/domain"
+	"github.com/Aleksey-Andris/go-yandex-shortener/internal/app/metrics"
 	"github.com/jmoiron/sqlx"
 )
 
@@ -19,6 +22,7 @@ func NewUserStorage(db *sqlx.DB) (*userStorage, error) {
 }
 
 func (s *userStorage) CreateUser(ctx context.Context) (int32, error) {
+	defer metrics.ObserveStorage(backend, "CreateUser", time.Now())
 	tx, err := s.db.Begin()
 	if err != nil {
 		return -1, err
@@ -35,3 +39,71 @@ func (s *userStorage) CreateUser(ctx context.Context) (int32, error) {
 	}
 	return user.ID, err
 }
+
+func (s *userStorage) CountUsers(ctx context.Context) (int, error) {
+	defer metrics.ObserveStorage(backend, "CountUsers", time.Now())
+	var count int
+	query := fmt.Sprintf("SELECT COUNT(*) FROM %s;", userTable)
+	err := s.db.GetContext(ctx, &count, query)
+	return count, err
+}
+
+func (s *userStorage) GetUser(ctx context.Context, userID int32) (domain.User, error) {
+	defer metrics.ObserveStorage(backend, "GetUser", time.Now())
+	var user domain.User
+	query := fmt.Sprintf("SELECT id, COALESCE(%s, '') AS %s, COALESCE(%s, '') AS %s, %s FROM %s WHERE id = $1;",
+		login, login, passwordHash, passwordHash, linkQuota, userTable)
+	err := s.db.GetContext(ctx, &user, query, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = domain.ErrNotFound
+	}
+	return user, err
+}
+
+func (s *userStorage) GetUserByLogin(ctx context.Context, userLogin string) (domain.User, error) {
+	defer metrics.ObserveStorage(backend, "GetUserByLogin", time.Now())
+	var user domain.User
+	query := fmt.Sprintf("SELECT id, %s, %s, %s FROM %s WHERE %s = $1;", login, passwordHash, linkQuota, userTable, login)
+	err := s.db.GetContext(ctx, &user, query, userLogin)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = domain.ErrNotFound
+	}
+	return user, err
+}
+
+// SetCredentials registers an anonymous user. A login already in use is a
+// conflict.
+func (s *userStorage) SetCredentials(ctx context.Context, user domain.User) error {
+	defer metrics.ObserveStorage(backend, "SetCredentials", time.Now())
+	query := fmt.Sprintf("UPDATE %s SET %s = $2, %s = $3 WHERE id = $1 AND %s IS NULL;", userTable, login, passwordHash, login)
+	result, err := s.db.ExecContext(ctx, query, user.ID, user.Login, user.PasswordHash)
+	if err != nil {
+		return conflictErr(err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+// SetLinkQuota overrides the link quota of a user; nil restores the default.
+func (s *userStorage) SetLinkQuota(ctx context.Context, userID int32, quota *int) error {
+	defer metrics.ObserveStorage(backend, "SetLinkQuota", time.Now())
+	query := fmt.Sprintf("UPDATE %s SET %s = $2 WHERE id = $1;", userTable, linkQuota)
+	result, err := s.db.ExecContext(ctx, query, userID, quota)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
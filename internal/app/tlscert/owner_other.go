@@ -0,0 +1,10 @@
+//go:build !unix
+
+package tlscert
+
+import "os"
+
+// ownedByCurrentUser cannot tell file owners apart outside Unix.
+func ownedByCurrentUser(info os.FileInfo) bool {
+	return true
+}
@@ -0,0 +1,129 @@
+package tlscert
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	certValidity = 365 * 24 * time.Hour
+	organization = "go-yandex-shortener"
+)
+
+// EnsureSelfSigned generates a self-signed certificate and key for hosts
+// unless both files already exist. Existing files must belong to the current
+// user and be writable by no one else, and the key must be private, so files
+// planted by another user are refused rather than served.
+func EnsureSelfSigned(certPath, keyPath string, hosts ...string) error {
+	certInfo, certErr := os.Stat(certPath)
+	keyInfo, keyErr := os.Stat(keyPath)
+	if !errors.Is(certErr, os.ErrNotExist) && certErr != nil {
+		return certErr
+	}
+	if !errors.Is(keyErr, os.ErrNotExist) && keyErr != nil {
+		return keyErr
+	}
+	if certErr == nil {
+		if err := checkFile(certPath, certInfo, 0022); err != nil {
+			return err
+		}
+	}
+	if keyErr == nil {
+		if err := checkFile(keyPath, keyInfo, 0077); err != nil {
+			return err
+		}
+	}
+	if certErr == nil && keyErr == nil {
+		return nil
+	}
+	return GenerateSelfSigned(certPath, keyPath, hosts...)
+}
+
+// checkFile refuses a file of another user or with any of the denied
+// permission bits.
+func checkFile(path string, info os.FileInfo, denied os.FileMode) error {
+	if !ownedByCurrentUser(info) {
+		return fmt.Errorf("%s belongs to another user", path)
+	}
+	if info.Mode().Perm()&denied != 0 {
+		return fmt.Errorf("%s has unsafe permissions %s", path, info.Mode().Perm())
+	}
+	return nil
+}
+
+func GenerateSelfSigned(certPath, keyPath string, hosts ...string) error {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{organization}},
+		NotBefore:             now,
+		NotAfter:              now.Add(certValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else if h != "" {
+			template.DNSNames = append(template.DNSNames, h)
+		}
+	}
+
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, &privateKey.PublicKey, privateKey)
+	if err != nil {
+		return err
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return err
+	}
+
+	if err := writePEM(certPath, "CERTIFICATE", certDER, 0644); err != nil {
+		return err
+	}
+	return writePEM(keyPath, "PRIVATE KEY", keyDER, 0600)
+}
+
+// writePEM writes to a new file that replaces path once complete, so the
+// file gets perm even when path exists with other permissions.
+func writePEM(path, blockType string, der []byte, perm os.FileMode) error {
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	if err := file.Chmod(perm); err != nil {
+		file.Close()
+		return err
+	}
+	if err := pem.Encode(file, &pem.Block{Type: blockType, Bytes: der}); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
@@ -0,0 +1,47 @@
+package tlscert
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_EnsureSelfSigned(t *testing.T) {
+	dir := t.TempDir()
+	certPath := filepath.Join(dir, "cert.pem")
+	keyPath := filepath.Join(dir, "key.pem")
+
+	require.NoError(t, EnsureSelfSigned(certPath, keyPath, "localhost", "127.0.0.1"))
+	pair, err := tls.LoadX509KeyPair(certPath, keyPath)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	require.NoError(t, err)
+	assert.Equal(t, []string{"localhost"}, cert.DNSNames)
+	assert.Len(t, cert.IPAddresses, 1)
+
+	before, err := os.ReadFile(certPath)
+	require.NoError(t, err)
+	require.NoError(t, EnsureSelfSigned(certPath, keyPath, "localhost"))
+	after, err := os.ReadFile(certPath)
+	require.NoError(t, err)
+	assert.Equal(t, before, after)
+}
+
+func Test_EnsureSelfSigned_UnsafeKey(t *testing.T) {
+	dir := t.TempDir()
+	certPath := filepath.Join(dir, "cert.pem")
+	keyPath := filepath.Join(dir, "key.pem")
+
+	require.NoError(t, EnsureSelfSigned(certPath, keyPath, "localhost"))
+	info, err := os.Stat(keyPath)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	require.NoError(t, os.Chmod(keyPath, 0644))
+	assert.Error(t, EnsureSelfSigned(certPath, keyPath, "localhost"))
+}